@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics"
+)
+
+// newDiagnoseNodeCommand returns the diagnose-node command, which discovers
+// a node offline and prints every step of the discovery.
+func newDiagnoseNodeCommand() *cobra.Command {
+	var cloudConfig string
+
+	cmd := &cobra.Command{
+		Use:   "diagnose-node NODE",
+		Short: "Discover a node by name, UUID or IP and explain the outcome",
+		Long: `Discover the VM of a node by name, UUID or IP address with a cloud config, as
+the cloud controller manager does, without a cluster. The iCenter and
+datacenter the VM was found in, every NIC with the decision of the internal
+and external CIDR and network name rules, the NodeAddresses, the instance type
+and the zone and region tags are printed. The command exits non-zero if the
+node was not discovered.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if cloudConfig == "" {
+				fmt.Fprintln(os.Stderr, "error: --cloud-config is required")
+				os.Exit(2)
+			}
+			if err := ics.DiagnoseNode(context.Background(), cloudConfig, args[0], os.Stdout); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&cloudConfig, "cloud-config", "", "The path to the cloud config file.")
+	return withDefaultHelp(cmd)
+}
@@ -84,11 +84,25 @@ func main() {
 			fmt.Printf("%s %s\n", AppName, version)
 			os.Exit(0)
 		}
+		// Watch the cloud config for changes of the iCenters
+		if cloudConfig := cmd.Flags().Lookup("cloud-config"); cloudConfig != nil {
+			ics.CloudConfigFile = cloudConfig.Value.String()
+		}
 		innerRun(cmd, args)
 	}
+	command.AddCommand(newValidateConfigCommand(), newDiagnoseNodeCommand())
 
 	if err := command.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// withDefaultHelp sets the cobra usage and help of a subcommand, rather than
+// those of the cloud-controller-manager listing its own flags.
+func withDefaultHelp(cmd *cobra.Command) *cobra.Command {
+	defaults := &cobra.Command{}
+	cmd.SetUsageFunc(defaults.UsageFunc())
+	cmd.SetHelpFunc(defaults.HelpFunc())
+	return cmd
+}
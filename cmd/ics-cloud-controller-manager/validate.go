@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics"
+)
+
+// newValidateConfigCommand returns the validate-config command, which checks
+// a cloud config against the iCenters it configures and exits non-zero if
+// any check fails.
+func newValidateConfigCommand() *cobra.Command {
+	var cloudConfig string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "validate-config",
+		Short: "Validate a cloud config against its iCenters",
+		Long: `Validate a cloud config: parse it, resolve the credentials from the config,
+a secret or the secrets directory, connect to every iCenter and check that the
+configured datacenters, zone and region tag categories and internal and
+external VM networks exist. A PASS or FAIL report is printed per iCenter, and
+the command exits non-zero if any check failed.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if cloudConfig == "" {
+				fmt.Fprintln(os.Stderr, "error: --cloud-config is required")
+				os.Exit(2)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := ics.ValidateConfig(ctx, cloudConfig, os.Stdout); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&cloudConfig, "cloud-config", "", "The path to the cloud config file to validate.")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the iCenters to be validated.")
+	return withDefaultHelp(cmd)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package ics
 
 import (
+	"context"
 	"io"
 	"runtime"
 
@@ -25,9 +26,11 @@ import (
 
 	cloudprovider "k8s.io/cloud-provider"
 
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/loadbalancer"
 	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/server"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
 	k8s "github.com/inspur-ics/cloud-provider-ics/pkg/common/kubernetes"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 )
 
 const (
@@ -50,6 +53,7 @@ func init() {
 
 // Creates new Controller node interface and returns
 func newICS(cfg *CPIConfig, finalize ...bool) (*ICS, error) {
+	metrics.Register()
 	ics, err := buildICSFromConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -72,6 +76,15 @@ func (ics *ICS) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 		connMgr := cm.NewConnectionManager(&ics.cfg.Config, ics.informMgr, client)
 		ics.connectionManager = connMgr
 		ics.nodeManager.connectionManager = connMgr
+		if ics.nodeLabeler != nil {
+			ics.nodeLabeler.client = client
+		}
+		if ics.maintenance != nil {
+			ics.maintenance.client = client
+		}
+		if ics.loadBalancer != nil {
+			ics.loadBalancer.SetAllocationStore(loadbalancer.NewConfigMapStore(client, &ics.cfg.LoadBalancer))
+		}
 
 		ics.informMgr.AddNodeListener(ics.nodeAdded, ics.nodeDeleted, nil)
 
@@ -80,6 +93,45 @@ func (ics *ICS) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 		//if running secrets, init them
 		connMgr.InitializeSecretLister()
 
+		//report the iCenters that fail verification, without blocking
+		//startup unless verify-fail-fast is set
+		if ics.cfg.Global.VerifyFailFast {
+			if err := verifyConnections(connMgr, ics.cfg.Labels.Zone, ics.cfg.Labels.Region); err != nil {
+				klog.Fatalf("iCenter verification failed with verify-fail-fast: %v", err)
+			}
+		} else {
+			go verifyConnections(connMgr, ics.cfg.Labels.Zone, ics.cfg.Labels.Region)
+		}
+
+		//keep the VM inventory used to discover nodes fresh
+		connMgr.RunInventoryRefresh(stop)
+
+		//reload the iCenters when the cloud config changes
+		if CloudConfigFile != "" {
+			newConfigReloader(ics, CloudConfigFile).Run(stop)
+		}
+
+		//poll the VMs of the nodes to keep the node caches up to date
+		newVMWatcher(ics.nodeManager).Run(stop)
+
+		//sync node labels from the tags of their VMs
+		if ics.nodeLabeler != nil {
+			ics.nodeLabeler.Run(stop)
+		}
+
+		//taint the nodes whose host is in maintenance
+		if ics.maintenance != nil {
+			ics.maintenance.Run(stop)
+		}
+
+		//check the connections to the iCenters for the readiness probes
+		readiness := &connectionReadiness{connectionManager: connMgr}
+		connMgr.RunConnectionChecks(stop, func() {
+			ics.server.SetServing(readiness.ready())
+		})
+		klog.V(1).Info("Starting the Health Server")
+		server.NewHealthServer(ics.cfg.Global.HealthBinding, readiness).Start()
+
 		if !ics.cfg.Global.APIDisable {
 			klog.V(1).Info("Starting the API Server")
 			ics.server.Start()
@@ -94,8 +146,12 @@ func (ics *ICS) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 // LoadBalancer returns a balancer interface. Also returns true if the
 // interface is supported, false otherwise.
 func (ics *ICS) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	klog.Warning("The inCloud Sphere cloud provider does not support load balancers")
-	return nil, false
+	if ics.loadBalancer == nil {
+		klog.Warning("Load balancers are disabled, no backend is configured in the LoadBalancer section")
+		return nil, false
+	}
+	klog.V(6).Info("Calling the LoadBalancer interface on inCloud Sphere cloud provider")
+	return ics.loadBalancer, true
 }
 
 // Instances returns an instances interface. Also returns true if the
@@ -150,8 +206,40 @@ func buildICSFromConfig(cfg *CPIConfig) (*ICS, error) {
 		nodeManager: nm,
 		instances:   newInstances(nm),
 		zones:       newZones(nm, cfg.Labels.Zone, cfg.Labels.Region),
-		server:      server.NewServer(cfg.Global.APIBinding, nm),
 	}
+
+	apiServer, err := server.NewServer(cfg.Global.APIBinding, nm, server.SecurityConfig{
+		CertFile:          cfg.Global.APITLSCertFile,
+		KeyFile:           cfg.Global.APITLSKeyFile,
+		ClientCAFile:      cfg.Global.APIClientCAFile,
+		TokenFile:         cfg.Global.APITokenFile,
+		DisableReflection: cfg.Global.APIDisableReflection,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ics.server = apiServer
+
+	labeler, err := newNodeLabeler(nm, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ics.nodeLabeler = labeler
+
+	maintenance, err := newMaintenanceController(nm, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ics.maintenance = maintenance
+
+	if cfg.LoadBalancer.Backend != "" {
+		lb, err := loadbalancer.NewLoadBalancer(&cfg.LoadBalancer)
+		if err != nil {
+			return nil, err
+		}
+		ics.loadBalancer = lb
+	}
+
 	return &ics, nil
 }
 
@@ -169,6 +257,14 @@ func (ics *ICS) nodeAdded(obj interface{}) {
 	klog.Infof("ICS CPI the node Added :%+v", node)
 
 	ics.nodeManager.RegisterNode(node)
+
+	if ics.nodeLabeler != nil {
+		go func() {
+			if err := ics.nodeLabeler.syncNode(context.Background(), node.Status.NodeInfo.SystemUUID, node.Name); err != nil {
+				klog.Warningf("Failed to sync the labels of node %s: %v", node.Name, err)
+			}
+		}()
+	}
 }
 
 // Notification handler when node is removed from k8s cluster.
@@ -20,8 +20,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
-	"gopkg.in/gcfg.v1"
+	"k8s.io/klog"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 )
 
 // FromCPIEnv initializes the provided configuratoin object with values
@@ -47,10 +51,65 @@ func (cfg *CPIConfig) FromCPIEnv() error {
 		cfg.Nodes.ExternalVMNetworkName = env
 	}
 
+	if env := os.Getenv("ICS_LOADBALANCER_BACKEND"); env != "" {
+		cfg.LoadBalancer.Backend = env
+	}
+	if env := os.Getenv("ICS_LOADBALANCER_IP_POOL"); env != "" {
+		cfg.LoadBalancer.IPPool = env
+	}
+	if env := os.Getenv("ICS_LOADBALANCER_HAPROXY_CONFIG_DIR"); env != "" {
+		cfg.LoadBalancer.HAProxyConfigDir = env
+	}
+	if env := os.Getenv("ICS_LOADBALANCER_ALLOCATIONS_NAMESPACE"); env != "" {
+		cfg.LoadBalancer.AllocationsNamespace = env
+	}
+	if env := os.Getenv("ICS_LOADBALANCER_ALLOCATIONS_CONFIGMAP"); env != "" {
+		cfg.LoadBalancer.AllocationsConfigMap = env
+	}
+
+	if env := os.Getenv("ICS_NODELABELS_PREFIX"); env != "" {
+		cfg.NodeLabels.Prefix = env
+	}
+	if env := os.Getenv("ICS_NODELABELS_CATEGORIES"); env != "" {
+		cfg.NodeLabels.Categories = strings.Split(env, ",")
+	}
+
+	if env := os.Getenv("ICS_MAINTENANCE_TAINT_EFFECT"); env != "" {
+		cfg.Maintenance.TaintEffect = env
+	}
+	if env := os.Getenv("ICS_MAINTENANCE_HOST_STATES"); env != "" {
+		cfg.Maintenance.HostStates = strings.Split(env, ",")
+	}
+	if env := os.Getenv("ICS_MAINTENANCE_HA_REQUIRED"); env != "" {
+		HARequired, err := strconv.ParseBool(env)
+		if err != nil {
+			klog.Errorf("Failed to parse ICS_MAINTENANCE_HA_REQUIRED: %s", err)
+		} else {
+			cfg.Maintenance.HARequired = HARequired
+		}
+	}
+	if env := os.Getenv("ICS_MAINTENANCE_CORDON"); env != "" {
+		Cordon, err := strconv.ParseBool(env)
+		if err != nil {
+			klog.Errorf("Failed to parse ICS_MAINTENANCE_CORDON: %s", err)
+		} else {
+			cfg.Maintenance.Cordon = Cordon
+		}
+	}
+	if env := os.Getenv("ICS_MAINTENANCE_DRAIN"); env != "" {
+		Drain, err := strconv.ParseBool(env)
+		if err != nil {
+			klog.Errorf("Failed to parse ICS_MAINTENANCE_DRAIN: %s", err)
+		} else {
+			cfg.Maintenance.Drain = Drain
+		}
+	}
+
 	return nil
 }
 
-// ReadCPIConfig parses inCloud Sphere cloud config file and stores it into CPIConfig.
+// ReadCPIConfig parses inCloud Sphere cloud config file, in gcfg INI, YAML or
+// JSON, and stores it into CPIConfig.
 // Environment variables are also checked
 func ReadCPIConfig(config io.Reader) (*CPIConfig, error) {
 	if config == nil {
@@ -58,7 +117,7 @@ func ReadCPIConfig(config io.Reader) (*CPIConfig, error) {
 	}
 
 	cfg := &CPIConfig{}
-	if err := gcfg.FatalOnly(gcfg.ReadInto(cfg, config)); err != nil {
+	if err := icfg.ReadConfigInto(cfg, config); err != nil {
 		return nil, err
 	}
 
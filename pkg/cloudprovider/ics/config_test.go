@@ -48,6 +48,43 @@ internal-vm-network-name = "Internal K8s Traffic"
 external-vm-network-name = "External/Outbound Traffic"
 `
 
+const loadBalancerConfig = `
+[Global]
+server = 0.0.0.0
+port = 443
+user = user
+password = password
+insecure-flag = true
+datacenters = us-west
+
+[LoadBalancer]
+backend = haproxy
+ip-pool = "10.0.0.100-10.0.0.150, 10.0.1.0/28"
+haproxy-config-dir = /etc/haproxy/conf.d
+`
+
+const yamlConfig = `
+global:
+  server: 0.0.0.0
+  port: 443
+  user: user
+  password: password
+  insecure-flag: true
+  datacenters: us-west
+nodes:
+  internal-network-subnet-cidr: 192.0.2.0/24
+loadBalancer:
+  backend: haproxy
+  ip-pool: 10.0.0.100-10.0.0.150, 10.0.1.0/28
+nodeLabels:
+  category:
+  - hardware
+  - compliance:pci
+maintenance:
+  taint-effect: NoSchedule
+  cordon: true
+`
+
 func TestReadConfigSubnetCidr(t *testing.T) {
 	_, err := ReadCPIConfig(nil)
 	if err == nil {
@@ -87,3 +124,43 @@ func TestReadConfigNetworkName(t *testing.T) {
 		t.Errorf("incorrect internal vm network name: %s", cfg.Nodes.ExternalVMNetworkName)
 	}
 }
+
+func TestReadConfigLoadBalancer(t *testing.T) {
+	cfg, err := ReadCPIConfig(strings.NewReader(loadBalancerConfig))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	if cfg.LoadBalancer.Backend != "haproxy" {
+		t.Errorf("incorrect load balancer backend: %s", cfg.LoadBalancer.Backend)
+	}
+	if cfg.LoadBalancer.IPPool != "10.0.0.100-10.0.0.150, 10.0.1.0/28" {
+		t.Errorf("incorrect load balancer ip pool: %s", cfg.LoadBalancer.IPPool)
+	}
+	if cfg.LoadBalancer.HAProxyConfigDir != "/etc/haproxy/conf.d" {
+		t.Errorf("incorrect haproxy config dir: %s", cfg.LoadBalancer.HAProxyConfigDir)
+	}
+}
+
+func TestReadConfigYAML(t *testing.T) {
+	cfg, err := ReadCPIConfig(strings.NewReader(yamlConfig))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	if icsConfig := cfg.ICSCenter["0.0.0.0"]; icsConfig == nil || !icsConfig.InsecureFlag || icsConfig.ICenterPort != "443" {
+		t.Errorf("incorrect iCenter config: %+v", cfg.ICSCenter)
+	}
+	if cfg.Nodes.InternalNetworkSubnetCIDR != "192.0.2.0/24" {
+		t.Errorf("incorrect internal network subnet cidr: %s", cfg.Nodes.InternalNetworkSubnetCIDR)
+	}
+	if cfg.LoadBalancer.Backend != "haproxy" || cfg.LoadBalancer.IPPool != "10.0.0.100-10.0.0.150, 10.0.1.0/28" {
+		t.Errorf("incorrect load balancer config: %+v", cfg.LoadBalancer)
+	}
+	if strings.Join(cfg.NodeLabels.Categories, ",") != "hardware,compliance:pci" {
+		t.Errorf("incorrect node label categories: %v", cfg.NodeLabels.Categories)
+	}
+	if cfg.Maintenance.TaintEffect != "NoSchedule" || !cfg.Maintenance.Cordon {
+		t.Errorf("incorrect maintenance config: %+v", cfg.Maintenance)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// ConfigReloadInterval is how often the cloud config file is checked for
+// changes.
+const ConfigReloadInterval = 30 * time.Second
+
+// CloudConfigFile is the path of the cloud config file, set from the
+// --cloud-config flag. The iCenters are reloaded when it changes, unless it
+// is empty.
+var CloudConfigFile string
+
+// configReloader reconciles the iCenters with the cloud config file when its
+// content changes. Only the iCenters, with their credentials and datacenters,
+// are reloaded, the other settings take effect on restart.
+type configReloader struct {
+	ics  *ICS
+	file string
+	// The content last read, valid or not
+	content []byte
+}
+
+// newConfigReloader returns a configReloader of file, its current content
+// being the configuration the ICS was built from.
+func newConfigReloader(ics *ICS, file string) *configReloader {
+	r := &configReloader{ics: ics, file: file}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		klog.Errorf("Failed to read the cloud config %s: %v", file, err)
+	}
+	r.content = content
+	return r
+}
+
+// Run checks the cloud config for changes every ConfigReloadInterval until
+// stopCh is closed. It does not block.
+func (r *configReloader) Run(stopCh <-chan struct{}) {
+	go wait.Until(r.reloadIfChanged, ConfigReloadInterval, stopCh)
+}
+
+// reloadIfChanged reloads the cloud config if its content changed. An
+// invalid config is reported once and the previous one kept.
+func (r *configReloader) reloadIfChanged() {
+	content, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		klog.Errorf("Failed to read the cloud config %s: %v", r.file, err)
+		return
+	}
+	if bytes.Equal(content, r.content) {
+		return
+	}
+	r.content = content
+
+	cfg, err := ReadCPIConfig(bytes.NewReader(content))
+	if err != nil {
+		klog.Errorf("Failed to reload the cloud config %s, keeping the previous one: %v", r.file, err)
+		return
+	}
+	klog.Infof("Reloading the iCenters of the cloud config %s", r.file)
+	r.ics.reloadConfig(cfg)
+}
+
+// reloadConfig reconciles the iCenters with cfg, keeping the cached nodes
+// that are still valid.
+func (ics *ICS) reloadConfig(cfg *CPIConfig) {
+	connMgr := ics.connectionManager
+	result := connMgr.Reload(&cfg.Config)
+	if !result.Changed() {
+		return
+	}
+	connMgr.InitializeSecretLister()
+
+	pruned := ics.nodeManager.pruneNodes(result)
+	if len(pruned) > 0 {
+		klog.Infof("Dropped %d cached nodes invalidated by the reload", len(pruned))
+		go ics.nodeManager.rediscoverNodes(pruned)
+	}
+}
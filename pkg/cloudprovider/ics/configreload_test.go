@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+func TestConfigReloader(t *testing.T) {
+	simCfg, model, cleanup := configFromSim(true)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "ics-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ics.conf")
+	tenantRef := simCfg.Global.ICenterIP
+	writeConfig := func(extra string) {
+		config := fmt.Sprintf(`
+[Global]
+user = %s
+password = %s
+insecure-flag = true
+
+[ICSCenter "%s"]
+port = %s
+%s
+`, simCfg.Global.User, simCfg.Global.Password, tenantRef, simCfg.Global.ICenterPort, extra)
+		if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("datacenters = DC0,DC1")
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ReadCPIConfig(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connMgr := cm.NewConnectionManager(&cfg.Config, nil, nil)
+	defer connMgr.Logout()
+	nm := newNodeManager(cfg, connMgr)
+	ics := &ICS{cfg: cfg, connectionManager: connMgr, nodeManager: nm}
+	reloader := newConfigReloader(ics, file)
+
+	if err := connMgr.Connect(context.Background(), connMgr.Instances()[tenantRef]); err != nil {
+		t.Fatalf("Failed to Connect to inCloud Sphere: %s", err)
+	}
+	vms := model.VMs()
+	for _, vm := range []string{vms[0].Name, vms[len(vms)-1].Name} {
+		if err := nm.DiscoverNode(vm, cm.FindVMByName); err != nil {
+			t.Fatalf("Failed DiscoverNode: %s", err)
+		}
+	}
+	dc0Node := nm.nodeNameMap[vms[0].Name]
+	if dc0Node == nil || dc0Node.dataCenter.Name != "DC0" || nm.nodeNameMap[vms[len(vms)-1].Name] == nil {
+		t.Fatalf("expected nodes in DC0 and DC1, got %v", nm.nodeNameMap)
+	}
+
+	// Unchanged
+	reloader.reloadIfChanged()
+	if len(nm.nodeNameMap) != 2 {
+		t.Errorf("nodes %v, expected both nodes to be kept", nm.nodeNameMap)
+	}
+
+	// Only the nodes of the datacenter no longer configured are dropped.
+	writeConfig("datacenters = DC0")
+	reloader.reloadIfChanged()
+	if instance := connMgr.Instances()[tenantRef]; instance.Cfg.Datacenters != "DC0" {
+		t.Errorf("datacenters %s, expected DC0", instance.Cfg.Datacenters)
+	}
+	if len(nm.nodeNameMap) != 1 || nm.nodeNameMap[vms[0].Name] != dc0Node {
+		t.Errorf("nodes %v, expected only the node in DC0 to be kept", nm.nodeNameMap)
+	}
+
+	// An invalid config is not applied.
+	writeConfig("thumbprint = invalid")
+	reloader.reloadIfChanged()
+	if instance := connMgr.Instances()[tenantRef]; instance.Cfg.Thumbprint != "" {
+		t.Errorf("thumbprint %s, expected the invalid config to be ignored", instance.Cfg.Thumbprint)
+	}
+
+	// The nodes of a reconnected iCenter are dropped.
+	writeConfig("datacenters = DC0\nno-proxy = .example.com")
+	reloader.reloadIfChanged()
+	if instance := connMgr.Instances()[tenantRef]; !strings.Contains(instance.Cfg.NoProxy, "example.com") {
+		t.Errorf("no-proxy %s, expected the new config", instance.Cfg.NoProxy)
+	}
+	if len(nm.nodeNameMap) != 0 {
+		t.Errorf("nodes %v, expected the nodes of the reconnected iCenter to be dropped", nm.nodeNameMap)
+	}
+}
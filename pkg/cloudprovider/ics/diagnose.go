@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	v1 "k8s.io/api/core/v1"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// NICDiagnosis is how the address rules of the cloud config treated a NIC of
+// the VM of a node.
+type NICDiagnosis struct {
+	Network  string
+	MAC      string
+	IP       string
+	Decision string
+}
+
+// NodeDiagnosis is the outcome of discovering a node, with the details the
+// cloud controller manager only logs.
+type NodeDiagnosis struct {
+	NodeID   string
+	SearchBy cm.FindVM
+	// The iCenter and datacenter of the VM found
+	TenantRef  string
+	ICenter    string
+	Datacenter string
+	VMName     string
+	UUID       string
+	// Every NIC of the VM, in order
+	NICs          []NICDiagnosis
+	NodeAddresses []v1.NodeAddress
+	InstanceType  string
+	// The zone and region tags of the host of the VM, if the tag categories
+	// are configured.
+	Zone   string
+	Region string
+	// Notes on the configuration that explain the addresses
+	Notes []string
+}
+
+// recordNIC records the decision of the address rules on the NIC, if d is not
+// nil.
+func (d *NodeDiagnosis) recordNIC(nic types.Nic, decision string) {
+	if d == nil {
+		return
+	}
+	d.NICs = append(d.NICs, NICDiagnosis{Network: nic.Name, MAC: nic.Mac, IP: nic.IP, Decision: decision})
+}
+
+// Print writes the diagnosis, up to the step that failed with err, if any.
+func (d *NodeDiagnosis) Print(out io.Writer, err error) {
+	fmt.Fprintf(out, "Node:           %s (searched %s)\n", d.NodeID, d.SearchBy)
+	if d.ICenter == "" {
+		fmt.Fprintf(out, "FAIL  discovery failed: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "iCenter:        %s (tenantRef %s)\n", d.ICenter, d.TenantRef)
+	fmt.Fprintf(out, "Datacenter:     %s\n", d.Datacenter)
+	fmt.Fprintf(out, "VM:             %s (UUID %s)\n", d.VMName, d.UUID)
+	fmt.Fprintln(out, "NICs:")
+	if len(d.NICs) == 0 {
+		fmt.Fprintln(out, "  none")
+	}
+	for _, nic := range d.NICs {
+		fmt.Fprintf(out, "  %s network=%q ip=%s: %s\n", nic.MAC, nic.Network, nic.IP, nic.Decision)
+	}
+	fmt.Fprintln(out, "NodeAddresses:")
+	for _, address := range d.NodeAddresses {
+		fmt.Fprintf(out, "  %s: %s\n", address.Type, address.Address)
+	}
+	fmt.Fprintf(out, "Instance type:  %s\n", d.InstanceType)
+	if d.Zone != "" || d.Region != "" {
+		fmt.Fprintf(out, "Zone:           %s\n", d.Zone)
+		fmt.Fprintf(out, "Region:         %s\n", d.Region)
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(out, "Note: %s\n", note)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  %v\n", err)
+	} else {
+		fmt.Fprintln(out, "PASS")
+	}
+}
+
+// DiagnoseNode discovers the node with the given name, UUID or IP address
+// with the cloud config file, as the cloud controller manager does, and
+// writes how it was discovered to out: the iCenter and datacenter of its VM,
+// the decision of the address rules on every NIC, the NodeAddresses, the
+// instance type and the zone and region.
+func DiagnoseNode(ctx context.Context, file string, nodeID string, out io.Writer) error {
+	cfg, err := readCPIConfigFile(file)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  cloud config %s: %v\n", file, err)
+		return err
+	}
+
+	connMgr, err := newOfflineConnectionManager(cfg)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  secrets of the cloud config %s: %v\n", file, err)
+		return err
+	}
+	defer connMgr.Logout()
+
+	credentialErrs := connMgr.ResolveCredentials()
+	tenantRefs := make([]string, 0, len(credentialErrs))
+	for tenantRef := range credentialErrs {
+		tenantRefs = append(tenantRefs, tenantRef)
+	}
+	sort.Strings(tenantRefs)
+	for _, tenantRef := range tenantRefs {
+		fmt.Fprintf(out, "WARN  no credentials for iCenter %s: %v\n", tenantRef, credentialErrs[tenantRef])
+	}
+
+	nm := newNodeManager(cfg, connMgr)
+	diag, err := nm.diagnoseNode(ctx, nodeID)
+	diag.Print(out, err)
+	return err
+}
+
+// diagnoseNode discovers the node like DiscoverNode, then looks up the zone
+// and region of its host if the tag categories are configured.
+func (nm *NodeManager) diagnoseNode(ctx context.Context, nodeID string) (*NodeDiagnosis, error) {
+	diag := &NodeDiagnosis{NodeID: nodeID, SearchBy: nodeIDSearch(nodeID), Notes: nm.addressNotes()}
+	node, err := nm.discoverNode(nodeID, diag.SearchBy, diag)
+	if err != nil {
+		return diag, err
+	}
+	diag.TenantRef = node.tenantRef
+	diag.ICenter = node.icsServer
+	diag.Datacenter = node.dataCenter.Name
+	diag.VMName = node.vm.Name
+	diag.UUID = node.UUID
+	diag.NodeAddresses = node.NodeAddresses
+	diag.InstanceType = node.NodeType
+	if len(node.NodeAddresses) < 2 {
+		diag.Notes = append(diag.Notes, "no IP address was found, only the hostname")
+	}
+
+	zone, err := nm.nodeZone(ctx, node)
+	if err == ErrZoneLabelsNotConfigured {
+		diag.Notes = append(diag.Notes, "zones are disabled, the zone and region tag categories are not configured")
+		return diag, nil
+	}
+	if err != nil {
+		return diag, fmt.Errorf("failed to get the zone and region: %v", err)
+	}
+	diag.Zone = zone.Zone
+	diag.Region = zone.Region
+	return diag, nil
+}
+
+// addressNotes explains the address rules of the cloud config that do not
+// apply as they may seem to.
+func (nm *NodeManager) addressNotes() []string {
+	var notes []string
+	nodes := nm.cpiCfg.Nodes
+	if (nodes.InternalNetworkSubnetCIDR == "") != (nodes.ExternalNetworkSubnetCIDR == "") {
+		notes = append(notes, "internal-network-subnet-cidr and external-network-subnet-cidr are ignored unless both are set")
+	}
+	if (nodes.InternalVMNetworkName == "") != (nodes.ExternalVMNetworkName == "") {
+		notes = append(notes, "with a single VM network name set, the NICs of other networks are not skipped")
+	}
+	return notes
+}
+
+// nodeIDSearch returns how to search for the VM of the node ID: by IP
+// address, UUID, or name, which falls back to the IP address.
+func nodeIDSearch(nodeID string) cm.FindVM {
+	switch {
+	case net.ParseIP(nodeID) != nil:
+		return cm.FindVMByIP
+	case uuidPattern.MatchString(nodeID):
+		return cm.FindVMByUUID
+	}
+	return cm.FindVMByName
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icssim"
+)
+
+func TestDiagnoseNode(t *testing.T) {
+	simCfg, model, cleanup := configFromSim(true)
+	defer cleanup()
+	vm := model.VMs()[0]
+	ip := vm.Nics[0].IP
+	regionID := model.AddTag(types.Tag{Name: "region-a", Description: simCfg.Labels.Region})
+	zoneID := model.AddTag(types.Tag{Name: "zone-a", Description: simCfg.Labels.Zone})
+	for _, tagID := range []string{regionID, zoneID} {
+		if err := model.AttachTag(tagID, vm.HostID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "ics-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name        string
+		nodeID      string
+		nodes       string
+		labels      string
+		expectErr   bool
+		expectLines []string
+	}{
+		{
+			name:   "by name with the internal network name",
+			nodeID: vm.Name,
+			nodes:  "internal-vm-network-name = " + icssim.DefaultNetworkName,
+			labels: "zone = k8s-zone\nregion = k8s-region",
+			expectLines: []string{
+				"searched byName",
+				"iCenter:        " + simCfg.Global.ICenterIP,
+				"Datacenter:     DC0",
+				fmt.Sprintf("%s network=%q ip=%s: internal IP %s by internal-vm-network-name", vm.Nics[0].Mac, icssim.DefaultNetworkName, ip, ip),
+				"InternalIP: " + ip,
+				"Hostname: " + vm.Name,
+				"Instance type:  ics-vm.cpu-2.mem-4gb.os-",
+				"Zone:           zone-a",
+				"Region:         region-a",
+				"Note: with a single VM network name set",
+				"PASS",
+			},
+		},
+		{
+			name:   "by UUID without rules",
+			nodeID: strings.ToUpper(vm.UUID),
+			expectLines: []string{
+				"searched byUUID",
+				fmt.Sprintf("internal and external IP %s, matched by no address rule", ip),
+				"ExternalIP: " + ip,
+				"Note: zones are disabled",
+				"PASS",
+			},
+		},
+		{
+			name:   "by IP outside of the subnets",
+			nodeID: ip,
+			nodes:  "internal-network-subnet-cidr = 192.168.0.0/24\nexternal-network-subnet-cidr = 172.16.0.0/16",
+			expectLines: []string{
+				"searched byIP",
+				ip + " skipped, in neither internal-network-subnet-cidr nor external-network-subnet-cidr",
+				"Note: no IP address was found, only the hostname",
+			},
+		},
+		{
+			name:   "network names not matching",
+			nodeID: vm.Name,
+			nodes:  "internal-vm-network-name = Private\nexternal-vm-network-name = Public",
+			expectLines: []string{
+				`skipped, network matches neither internal-vm-network-name "Private" nor external-vm-network-name "Public"`,
+			},
+		},
+		{
+			// The host in DC1 has no tags
+			name:        "zone tags missing",
+			nodeID:      model.VMs()[len(model.VMs())-1].Name,
+			labels:      "zone = k8s-zone\nregion = k8s-region",
+			expectErr:   true,
+			expectLines: []string{"FAIL  failed to get the zone and region"},
+		},
+		{
+			name:        "not found",
+			nodeID:      "missing-vm",
+			expectErr:   true,
+			expectLines: []string{"FAIL  discovery failed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fmt.Sprintf(`
+[Global]
+user = %s
+password = %s
+insecure-flag = true
+
+[ICSCenter "%s"]
+port = %s
+datacenters = %s
+
+[Labels]
+%s
+
+[Nodes]
+%s
+`, simCfg.Global.User, simCfg.Global.Password, simCfg.Global.ICenterIP, simCfg.Global.ICenterPort, simCfg.Global.Datacenters, test.labels, test.nodes)
+			file := filepath.Join(dir, "ics.conf")
+			if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			err := DiagnoseNode(context.Background(), file, test.nodeID, &out)
+			if test.expectErr != (err != nil) {
+				t.Errorf("expected error %t, got %v\n%s", test.expectErr, err, out.String())
+			}
+			for _, line := range test.expectLines {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("expected %q in the diagnosis:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNodeIDSearch(t *testing.T) {
+	for nodeID, expected := range map[string]cm.FindVM{
+		"k8s-node-1":                           cm.FindVMByName,
+		"10.0.0.1":                             cm.FindVMByIP,
+		"fd00::1":                              cm.FindVMByIP,
+		"4237A1B2-5C3D-4E5F-8A9B-0C1D2E3F4A5B": cm.FindVMByUUID,
+	} {
+		if searchBy := nodeIDSearch(nodeID); searchBy != expected {
+			t.Errorf("nodeIDSearch(%s) = %s, expected %s", nodeID, searchBy, expected)
+		}
+	}
+}
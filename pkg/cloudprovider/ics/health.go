@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+
+	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/server"
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+// connectionReadiness reports an iCenter as ready when the last connection
+// to it succeeded.
+type connectionReadiness struct {
+	connectionManager *cm.ConnectionManager
+}
+
+// Readiness implements server.HealthChecker
+func (r *connectionReadiness) Readiness() map[string]server.Check {
+	readiness := make(map[string]server.Check)
+	for _, status := range r.connectionManager.ConnectionStatuses() {
+		check := server.Check{Err: status.Ready()}
+		if status.Endpoint != "" {
+			check.Detail = "endpoint " + status.Endpoint
+		}
+		readiness["icenter/"+status.TenantRef] = check
+	}
+	return readiness
+}
+
+// ready returns true if every iCenter is ready.
+func (r *connectionReadiness) ready() bool {
+	for name, check := range r.Readiness() {
+		if check.Err != nil {
+			klog.V(3).Infof("%s is not ready: %v", name, check.Err)
+			return false
+		}
+	}
+	return true
+}
+
+// verifyConnections verifies the configuration of every iCenter, logging
+// those that fail, and returns their errors. Unless the caller fails on them
+// with verify-fail-fast, the provider starts degraded rather than not at all:
+// the failing iCenters stay configured, and the requests to them keep
+// retrying until they recover.
+func verifyConnections(connMgr *cm.ConnectionManager, zoneLabel string, regionLabel string) error {
+	report := connMgr.VerifyAll(context.Background(), zoneLabel, regionLabel)
+	failed := report.Failed()
+	if len(failed) == 0 {
+		klog.V(1).Infof("Verified the configuration of %d iCenters", len(report))
+		return nil
+	}
+	for _, result := range failed {
+		klog.Warningf("iCenter %s failed verification: %v "+
+			"(endpoint=%s reachable=%t tls=%t credentials=%t missing datacenters=%v missing tag categories=%v)",
+			result.TenantRef, result.Err, result.Endpoint, result.Reachable, result.TLS, result.CredentialsValid,
+			result.MissingDatacenters, result.MissingTagCategories)
+	}
+	return report.Err()
+}
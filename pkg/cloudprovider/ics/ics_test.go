@@ -24,27 +24,36 @@ import (
 
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icssim"
 )
 
-// configFromSim starts a vcsim instance and returns config for use against the vcsim instance.
-// The vcsim instance is configured with an empty tls.Config.
-func configFromSim(multiDc bool) (*icfg.Config, func()) {
+// configFromSim starts an icssim instance with the default inventory and returns config for use
+// against it. The icssim instance is configured with an empty tls.Config.
+func configFromSim(multiDc bool) (*icfg.Config, *icssim.Model, func()) {
 	return configFromSimWithTLS(new(tls.Config), true, multiDc)
 }
 
-// configFromSimWithTLS starts a vcsim instance and returns config for use against the vcsim instance.
-// The vcsim instance is configured with a tls.Config. The returned client
+// configFromSimWithTLS starts an icssim instance and returns config for use against it.
+// The icssim instance is configured with a tls.Config. The returned client
 // config can be configured to allow/decline insecure connections.
-func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc bool) (*icfg.Config, func()) {
+func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc bool) (*icfg.Config, *icssim.Model, func()) {
 	cfg := &icfg.Config{}
-	var HostName = "127.0.0.1"
+	model := icssim.VPX()
+	if multiDc {
+		model.Datacenter = 2
+	}
+	model.Create()
+
+	s := icssim.NewUnstartedServer(model)
+	s.TLS = tlsConfig
+	s.StartTLS()
 
 	cfg.Global.InsecureFlag = insecureAllowed
 
-	cfg.Global.ICenterIP = HostName
-	cfg.Global.ICenterPort = "443"
-	cfg.Global.User = "admin"
-	cfg.Global.Password = "123456"
+	cfg.Global.ICenterIP = s.Host()
+	cfg.Global.ICenterPort = s.Port()
+	cfg.Global.User = model.Username
+	cfg.Global.Password = model.Password
 
 	if multiDc {
 		cfg.Global.Datacenters = "DC0,DC1"
@@ -52,28 +61,30 @@ func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc b
 		cfg.Global.Datacenters = "DC0"
 	}
 	cfg.ICSCenter = make(map[string]*icfg.ICSCenterConfig)
-	cfg.ICSCenter[HostName] = &icfg.ICSCenterConfig{
-		User:         cfg.Global.User,
-		Password:     cfg.Global.Password,
-		TenantRef:    cfg.Global.ICenterIP,
-		ICenterIP:    cfg.Global.ICenterIP,
-		ICenterPort:  cfg.Global.ICenterPort,
-		InsecureFlag: cfg.Global.InsecureFlag,
-		Datacenters:  cfg.Global.Datacenters,
+	cfg.ICSCenter[s.Host()] = &icfg.ICSCenterConfig{
+		User:             cfg.Global.User,
+		Password:         cfg.Global.Password,
+		TenantRef:        cfg.Global.ICenterIP,
+		ICenterIP:        cfg.Global.ICenterIP,
+		ICenterPort:      cfg.Global.ICenterPort,
+		InsecureFlag:     cfg.Global.InsecureFlag,
+		Datacenters:      cfg.Global.Datacenters,
+		IPFamilyPriority: []string{icfg.DefaultIPFamily},
 	}
 
 	// Configure region and zone categories
 	cfg.Labels.Region = "k8s-region"
 	cfg.Labels.Zone = "k8s-zone"
 
-	return cfg, func() {}
+	return cfg, model, s.Close
 }
 
 // configFromEnvOrSim returns config from configFromEnv if set, otherwise returns configFromSim.
 func configFromEnvOrSim(multiDc bool) (*icfg.Config, func()) {
 	cfg := &icfg.Config{}
 	if err := cfg.FromEnv(); err != nil {
-		return configFromSim(multiDc)
+		cfg, _, cleanup := configFromSim(multiDc)
+		return cfg, cleanup
 	}
 	return cfg, func() {}
 }
@@ -120,6 +131,25 @@ func TestICSLogin(t *testing.T) {
 	icsInstance.Conn.Logout(ctx)
 }
 
+func TestVerifyConnections(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	if err := verifyConnections(connMgr, "", ""); err != nil {
+		t.Errorf("verifyConnections err=%v", err)
+	}
+
+	cfg.ICSCenter[cfg.Global.ICenterIP].Datacenters = "DC0,missing"
+	failing := cm.NewConnectionManager(cfg, nil, nil)
+	defer failing.Logout()
+	err := verifyConnections(failing, "", "")
+	if err == nil || !strings.Contains(err.Error(), cfg.Global.ICenterIP) {
+		t.Errorf("verifyConnections should fail for the iCenter with a missing datacenter, got %v", err)
+	}
+}
+
 func TestSecretICSConfig(t *testing.T) {
 	var ics *ICS
 	var (
@@ -288,13 +318,13 @@ func TestSecretICSConfig(t *testing.T) {
 			user = user
 			password = password
 			datacenters = us-west
-			thumbprint = "thumbprint:global"
+			thumbprint = "AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB:AB"
 			`,
 			expectedUsername: username,
 			expectedPassword: password,
 			expectedError:    nil,
 			expectedThumbprints: map[string]string{
-				"global": "thumbprint:global",
+				"global": "ABABABABABABABABABABABABABABABABABABABAB",
 			},
 		},
 		{
@@ -304,17 +334,17 @@ func TestSecretICSConfig(t *testing.T) {
 			password = password
 			datacenters = us-west
 			[ICSCenter "0.0.0.0"]
-			thumbprint = thumbprint:0
+			thumbprint = 00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00
 			[ICSCenter "no_thumbprint"]
 			[ICSCenter "1.1.1.1"]
-			thumbprint = thumbprint:1
+			thumbprint = 11:11:11:11:11:11:11:11:11:11:11:11:11:11:11:11:11:11:11:11
 			`,
 			expectedUsername: username,
 			expectedPassword: password,
 			expectedError:    nil,
 			expectedThumbprints: map[string]string{
-				"0.0.0.0": "thumbprint:0",
-				"1.1.1.1": "thumbprint:1",
+				"0.0.0.0": "0000000000000000000000000000000000000000",
+				"1.1.1.1": "1111111111111111111111111111111111111111",
 			},
 		},
 		{
@@ -373,5 +403,11 @@ func TestSecretICSConfig(t *testing.T) {
 				}
 			}
 		}
+		for tenantRef, vsInstance := range ics.connectionManager.ICSInstanceMap {
+			if vsInstance.Cfg.Thumbprint != testcase.expectedThumbprints[tenantRef] {
+				t.Fatalf("Expected thumbprint %q doesn't match actual thumbprint %q of %s in config %s",
+					testcase.expectedThumbprints[tenantRef], vsInstance.Cfg.Thumbprint, tenantRef, testcase.conf)
+			}
+		}
 	}
 }
@@ -47,23 +47,14 @@ func newInstances(nodeManager *NodeManager) cloudprovider.Instances {
 func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName) ([]v1.NodeAddress, error) {
 	klog.V(4).Info("instances.NodeAddresses() called with ", string(nodeName))
 
-	// Check if node has been discovered already
-	if node, ok := i.nodeManager.nodeNameMap[string(nodeName)]; ok {
-		klog.V(2).Info("instances.NodeAddresses() CACHED with ", string(nodeName))
-		return node.NodeAddresses, nil
+	node, err := i.nodeManager.lookupNodeInfo(string(nodeName), cm.FindVMByName)
+	if err != nil {
+		klog.V(4).Info("instances.NodeAddresses() NOT FOUND with ", string(nodeName))
+		return []v1.NodeAddress{}, ErrNodeNotFound
 	}
 
-	if err := i.nodeManager.DiscoverNode(string(nodeName), cm.FindVMByName); err == nil {
-		if i.nodeManager.nodeNameMap[string(nodeName)] == nil {
-			klog.Errorf("DiscoverNode succeeded, but CACHE missed for node=%s. If this is a Linux VM, hostnames are case sensitive. Make sure they match.", string(nodeName))
-			return []v1.NodeAddress{}, ErrNodeNotFound
-		}
-		klog.V(2).Info("instances.NodeAddresses() FOUND with ", string(nodeName))
-		return i.nodeManager.nodeNameMap[string(nodeName)].NodeAddresses, nil
-	}
-
-	klog.V(4).Info("instances.NodeAddresses() NOT FOUND with ", string(nodeName))
-	return []v1.NodeAddress{}, ErrNodeNotFound
+	klog.V(2).Info("instances.NodeAddresses() FOUND with ", string(nodeName))
+	return node.NodeAddresses, nil
 }
 
 // NodeAddressesByProviderID returns all the valid addresses of the instance
@@ -72,20 +63,15 @@ func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName)
 func (i *instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
 	klog.V(4).Info("instances.NodeAddressesByProviderID() called with ", providerID)
 
-	// Check if node has been discovered already
 	uid := GetUUIDFromProviderID(providerID)
-	if node, ok := i.nodeManager.nodeUUIDMap[uid]; ok {
-		klog.V(2).Info("instances.NodeAddressesByProviderID() CACHED with ", uid)
-		return node.NodeAddresses, nil
-	}
-
-	if err := i.nodeManager.DiscoverNode(uid, cm.FindVMByUUID); err == nil {
-		klog.V(2).Info("instances.NodeAddressesByProviderID() FOUND with ", uid)
-		return i.nodeManager.nodeUUIDMap[uid].NodeAddresses, nil
+	node, err := i.nodeManager.lookupNodeInfo(uid, cm.FindVMByUUID)
+	if err != nil {
+		klog.V(4).Info("instances.NodeAddressesByProviderID() NOT FOUND with ", uid)
+		return []v1.NodeAddress{}, ErrNodeNotFound
 	}
 
-	klog.V(4).Info("instances.NodeAddressesByProviderID() NOT FOUND with ", uid)
-	return []v1.NodeAddress{}, ErrNodeNotFound
+	klog.V(2).Info("instances.NodeAddressesByProviderID() FOUND with ", uid)
+	return node.NodeAddresses, nil
 }
 
 // ExternalID returns the cloud provider ID of the instance identified by
@@ -103,36 +89,36 @@ func (i *instances) ExternalID(ctx context.Context, nodeName types.NodeName) (st
 func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (string, error) {
 	klog.V(4).Info("instances.InstanceID() called with ", nodeName)
 
-	// Check if node has been discovered already
-	if node, ok := i.nodeManager.nodeNameMap[string(nodeName)]; ok {
-		klog.V(2).Info("instances.InstanceID() CACHED with ", string(nodeName))
-		return node.UUID, nil
+	node, err := i.nodeManager.lookupNodeInfo(string(nodeName), cm.FindVMByName)
+	if err != nil {
+		klog.V(4).Info("instances.InstanceID() NOT FOUND with ", string(nodeName))
+		return "", ErrNodeNotFound
 	}
 
-	if err := i.nodeManager.DiscoverNode(string(nodeName), cm.FindVMByName); err == nil {
-		if i.nodeManager.nodeNameMap[string(nodeName)] == nil {
-			klog.Errorf("DiscoverNode succeeded, but CACHE missed for node=%s. If this is a Linux VM, hostnames are case sensitive. Make sure they match.", string(nodeName))
-			return "", ErrNodeNotFound
-		}
-		klog.V(2).Infof("instances.InstanceID() FOUND with %s", string(nodeName))
-		return i.nodeManager.nodeNameMap[string(nodeName)].UUID, nil
-	}
-
-	klog.V(4).Info("instances.InstanceID() NOT FOUND with ", string(nodeName))
-	return "", ErrNodeNotFound
+	klog.V(2).Infof("instances.InstanceID() FOUND with %s", string(nodeName))
+	return node.UUID, nil
 }
 
 // InstanceType returns the type of the instance identified by name.
 func (i *instances) InstanceType(ctx context.Context, name types.NodeName) (string, error) {
 	klog.V(4).Info("instances.InstanceType() called")
-	return i.nodeManager.nodeNameMap[string(name)].NodeType, nil
+
+	node, err := i.nodeManager.lookupNodeInfo(string(name), cm.FindVMByName)
+	if err != nil {
+		return "", ErrNodeNotFound
+	}
+	return node.NodeType, nil
 }
 
 // InstanceTypeByProviderID returns the type of the instance identified by providerID.
 func (i *instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
 	klog.V(4).Info("instances.InstanceTypeByProviderID() called")
-	uid := GetUUIDFromProviderID(providerID)
-	return i.nodeManager.nodeUUIDMap[uid].NodeType, nil
+
+	node, err := i.nodeManager.lookupNodeInfo(GetUUIDFromProviderID(providerID), cm.FindVMByUUID)
+	if err != nil {
+		return "", ErrNodeNotFound
+	}
+	return node.NodeType, nil
 }
 
 // AddSSHKeyToAllInstances is not implemented; it always returns an error.
@@ -152,39 +138,29 @@ func (i *instances) CurrentNodeName(ctx context.Context, hostname string) (types
 func (i *instances) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
 	klog.V(4).Info("instances.InstanceExistsByProviderID() called with ", providerID)
 
-	// Check if node has been discovered already
 	uid := GetUUIDFromProviderID(providerID)
-	if _, ok := i.nodeManager.nodeUUIDMap[uid]; ok {
-		klog.V(2).Info("instances.InstanceExistsByProviderID() CACHED with ", uid)
-		return true, nil
+	if _, err := i.nodeManager.lookupNodeInfo(uid, cm.FindVMByUUID); err != nil {
+		klog.V(4).Info("instances.InstanceExistsByProviderID() NOT FOUND with ", uid)
+		return false, nil
 	}
 
-	if err := i.nodeManager.DiscoverNode(uid, cm.FindVMByUUID); err == nil {
-		klog.V(2).Info("instances.InstanceExistsByProviderID() EXISTS with ", uid)
-		return true, err
-	}
-
-	klog.V(4).Info("instances.InstanceExistsByProviderID() NOT FOUND with ", uid)
-	return false, nil
+	klog.V(2).Info("instances.InstanceExistsByProviderID() EXISTS with ", uid)
+	return true, nil
 }
 
 // InstanceShutdownByProviderID returns true if the instance is in safe state to detach volumes
 func (i *instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
 	klog.V(4).Info("instances.InstanceShutdownByProviderID() called")
 
-	// Check if node has been discovered already
 	uid := GetUUIDFromProviderID(providerID)
-	if _, ok := i.nodeManager.nodeUUIDMap[uid]; !ok {
-		// IF the uuid is not cached, we end up here
-		klog.V(2).Info("instances.InstanceShutdownByProviderID() NOT CACHED")
-		if err := i.nodeManager.DiscoverNode(uid, cm.FindVMByUUID); err != nil {
-			klog.V(4).Info("instances.InstanceShutdownByProviderID() NOT FOUND with ", uid)
-			// if we can't discover, return false with an error in tow
-			return false, err
-		}
-		klog.V(2).Infof("instances.InstanceShutdownByProviderID() EXISTS with %q", uid)
+	node, err := i.nodeManager.lookupNodeInfo(uid, cm.FindVMByUUID)
+	if err != nil {
+		klog.V(4).Info("instances.InstanceShutdownByProviderID() NOT FOUND with ", uid)
+		// if we can't discover, return false with an error in tow
+		return false, err
 	}
-	active, err := i.nodeManager.nodeUUIDMap[uid].vm.IsActive(ctx)
+
+	active, err := node.vm.IsActive(ctx)
 	klog.V(2).Infof("VM=%s IsActive=%t", uid, active)
 	return !active, err
 }
@@ -63,7 +63,7 @@ func (nm *MyNodeManager) RegisterNode(node *v1.Node) {
 }
 
 func TestInstance(t *testing.T) {
-	cfg, ok := configFromEnvOrSim(true)
+	cfg, model, ok := configFromSim(true)
 	defer ok()
 
 	//context
@@ -76,12 +76,9 @@ func TestInstance(t *testing.T) {
 	nm := newMyNodeManager(connMgr)
 	instances := newInstances(&nm.NodeManager)
 
-	//vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
-	//name := vm.Name
-	//vm.Guest.HostName = name
-	//UUID := strings.ToUpper(vm.Config.Uuid)
-	name := "vm-001"
-	UUID := "7b33262f-c649-470f-8aea-9d5bc77701b1"
+	vm := model.VMs()[0]
+	name := vm.Name
+	UUID := vm.UUID
 
 	node := &v1.Node{
 		ObjectMeta: metav1.ObjectMeta{
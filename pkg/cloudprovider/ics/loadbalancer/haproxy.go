@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+const (
+	// haproxyConfigExt is the extension of the per load balancer files.
+	haproxyConfigExt = ".cfg"
+
+	// haproxyVIPComment prefixes the line recording the VIP of a file.
+	haproxyVIPComment = "# vip: "
+
+	// allocationRetries is how many times an allocation is retried after a
+	// conflicting update of the store.
+	allocationRetries = 5
+)
+
+// haproxyBackend allocates VIPs from an IPPool and writes one HAProxy
+// configuration file per load balancer into a directory shared with the
+// HAProxy instances serving the VIPs.
+//
+// The allocations are kept in an AllocationStore, not in the files, so a VIP
+// is never handed out twice across restarts and leader failovers. The files
+// of the new leader are rewritten as the service controller ensures every
+// load balancer on start.
+//
+// The backend neither configures the VIPs on an interface nor reloads
+// HAProxy: the HAProxy instances must include the directory, e.g. with
+// "-f /etc/haproxy/conf.d", reload when a file changes, and run alongside
+// something holding the VIPs of the ip-pool, such as keepalived, or bind
+// them with net.ipv4.ip_nonlocal_bind set.
+type haproxyBackend struct {
+	sync.Mutex
+
+	dir string
+	// pool holds the ranges of the ip-pool, its allocations are rebuilt
+	// from the store on every change.
+	pool  *IPPool
+	store AllocationStore
+}
+
+func newHAProxyBackend(cfg *Config) (*haproxyBackend, error) {
+	if cfg.HAProxyConfigDir == "" {
+		return nil, fmt.Errorf("haproxy-config-dir is required by the %s load balancer backend", BackendHAProxy)
+	}
+	pool, err := NewIPPool(cfg.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.HAProxyConfigDir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &haproxyBackend{dir: cfg.HAProxyConfigDir, pool: pool, store: newMemoryStore()}, nil
+}
+
+// SetAllocationStore replaces the store of the VIP allocations.
+func (b *haproxyBackend) SetAllocationStore(store AllocationStore) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.store = store
+}
+
+func (b *haproxyBackend) path(name string) string {
+	return filepath.Join(b.dir, name+haproxyConfigExt)
+}
+
+// Get returns the VIP of the named load balancer.
+func (b *haproxyBackend) Get(ctx context.Context, name string) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	allocations, _, err := b.store.Load(ctx)
+	if err != nil {
+		return "", err
+	}
+	return allocations[name], nil
+}
+
+// Ensure allocates the VIP of the load balancer, if needed, and rewrites its
+// configuration file. A VIP allocated by this call is released if the file
+// cannot be written.
+func (b *haproxyBackend) Ensure(ctx context.Context, spec *Spec) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	vip, allocated, err := b.allocate(ctx, spec.Name, spec.IP)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(b.path(spec.Name), renderHAProxyConfig(spec, vip)); err != nil {
+		if allocated {
+			if releaseErr := b.release(ctx, spec.Name); releaseErr != nil {
+				klog.Errorf("Failed to release VIP %s of load balancer %s: %v", vip, spec.Name, releaseErr)
+			}
+		}
+		return "", err
+	}
+	return vip, nil
+}
+
+// Delete removes the configuration file of the load balancer and releases
+// its VIP.
+func (b *haproxyBackend) Delete(ctx context.Context, name string) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := os.Remove(b.path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return b.release(ctx, name)
+}
+
+// allocate returns the VIP of the load balancer, saving a new allocation in
+// the store. allocated is true if the load balancer had no VIP before.
+// Callers must hold the lock.
+func (b *haproxyBackend) allocate(ctx context.Context, name string, requested string) (vip string, allocated bool, err error) {
+	err = b.update(ctx, func(allocations map[string]string) (bool, error) {
+		previous := allocations[name]
+		vip, err = b.pool.withAllocations(allocations).Allocate(name, requested)
+		if err != nil {
+			return false, err
+		}
+		allocated = previous == ""
+		allocations[name] = vip
+		return vip != previous, nil
+	})
+	return vip, allocated, err
+}
+
+// release frees the VIP of the load balancer. Callers must hold the lock.
+func (b *haproxyBackend) release(ctx context.Context, name string) error {
+	return b.update(ctx, func(allocations map[string]string) (bool, error) {
+		if _, ok := allocations[name]; !ok {
+			return false, nil
+		}
+		delete(allocations, name)
+		return true, nil
+	})
+}
+
+// update applies change to the allocations and saves them if change reports
+// they changed, retrying with fresh allocations on conflicting updates.
+func (b *haproxyBackend) update(ctx context.Context, change func(map[string]string) (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		allocations, version, err := b.store.Load(ctx)
+		if err != nil {
+			return err
+		}
+		changed, err := change(allocations)
+		if err != nil || !changed {
+			return err
+		}
+		err = b.store.Save(ctx, allocations, version)
+		if err != ErrAllocationConflict || attempt == allocationRetries {
+			return err
+		}
+		klog.V(3).Infof("VIP allocations changed concurrently, retrying")
+	}
+}
+
+// renderHAProxyConfig returns a frontend/backend pair per port of the load
+// balancer, balancing TCP connections across the node ports.
+func renderHAProxyConfig(spec *Spec, vip string) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# Generated by the ICS cloud provider, do not edit.\n")
+	fmt.Fprintf(&buf, "%s%s\n", haproxyVIPComment, vip)
+	fmt.Fprintf(&buf, "# service: %s\n", spec.Service)
+
+	for _, port := range spec.Ports {
+		section := fmt.Sprintf("%s-%d", spec.Name, port.Port)
+
+		fmt.Fprintf(&buf, "\nfrontend %s\n", section)
+		fmt.Fprintf(&buf, "    bind %s:%d\n", vip, port.Port)
+		fmt.Fprintf(&buf, "    mode tcp\n")
+		fmt.Fprintf(&buf, "    default_backend %s\n", section)
+
+		fmt.Fprintf(&buf, "\nbackend %s\n", section)
+		fmt.Fprintf(&buf, "    mode tcp\n")
+		fmt.Fprintf(&buf, "    balance roundrobin\n")
+		for i, node := range spec.Nodes {
+			fmt.Fprintf(&buf, "    server node%d %s:%d check\n", i, node, port.NodePort)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// writeFileAtomic replaces the file so that readers never see a partial
+// configuration.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
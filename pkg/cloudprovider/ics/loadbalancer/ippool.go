@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+// IPPool hands out IPv4 VIPs from a set of address ranges. Each VIP is owned
+// by at most one load balancer.
+type IPPool struct {
+	sync.Mutex
+
+	ranges []ipRange
+	// Maps an allocated address to its owner.
+	owners map[uint32]string
+}
+
+// ipRange is an inclusive range of IPv4 addresses.
+type ipRange struct {
+	first uint32
+	last  uint32
+}
+
+// NewIPPool parses a comma separated list of addresses, ranges and CIDRs.
+func NewIPPool(pool string) (*IPPool, error) {
+	p := &IPPool{owners: make(map[uint32]string)}
+
+	for _, entry := range strings.Split(pool, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		r, err := parseIPRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		p.ranges = append(p.ranges, r)
+	}
+
+	if len(p.ranges) == 0 {
+		return nil, ErrEmptyIPPool
+	}
+	return p, nil
+}
+
+func parseIPRange(entry string) (ipRange, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil || ipNet.IP.To4() == nil {
+			return ipRange{}, fmt.Errorf("invalid IPv4 CIDR %q in ip-pool", entry)
+		}
+		ones, bits := ipNet.Mask.Size()
+		first := ipToUint32(ipNet.IP)
+		last := first | (1<<uint(bits-ones) - 1)
+		// The network and broadcast addresses are not usable.
+		if bits-ones > 1 {
+			first++
+			last--
+		}
+		return ipRange{first, last}, nil
+	}
+
+	bounds := strings.SplitN(entry, "-", 2)
+	first := net.ParseIP(strings.TrimSpace(bounds[0])).To4()
+	last := first
+	if len(bounds) == 2 {
+		last = net.ParseIP(strings.TrimSpace(bounds[1])).To4()
+	}
+	if first == nil || last == nil || ipToUint32(first) > ipToUint32(last) {
+		return ipRange{}, fmt.Errorf("invalid IPv4 range %q in ip-pool", entry)
+	}
+	return ipRange{ipToUint32(first), ipToUint32(last)}, nil
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// withAllocations returns a pool of the same ranges holding the allocations,
+// by owner. Allocations outside of the ranges or of an address already
+// allocated are ignored.
+func (p *IPPool) withAllocations(allocations map[string]string) *IPPool {
+	pool := &IPPool{ranges: p.ranges, owners: make(map[uint32]string, len(allocations))}
+	for owner, ip := range allocations {
+		if err := pool.Reserve(owner, ip); err != nil {
+			klog.Warningf("Ignoring the VIP allocation of %s: %v", owner, err)
+		}
+	}
+	return pool
+}
+
+func (p *IPPool) contains(n uint32) bool {
+	for _, r := range p.ranges {
+		if n >= r.first && n <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+// Allocate returns the VIP of owner, allocating one if owner has none. A
+// non-empty requested address must be in the pool and free.
+func (p *IPPool) Allocate(owner string, requested string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	current, hasCurrent := p.lookup(owner)
+
+	if requested != "" {
+		ip := net.ParseIP(requested).To4()
+		if ip == nil {
+			return "", fmt.Errorf("requested load balancer IP %q is not an IPv4 address", requested)
+		}
+		n := ipToUint32(ip)
+		if !p.contains(n) {
+			return "", fmt.Errorf("requested load balancer IP %s is not in the ip-pool", requested)
+		}
+		if other, ok := p.owners[n]; ok && other != owner {
+			return "", fmt.Errorf("requested load balancer IP %s is already in use", requested)
+		}
+		if hasCurrent && current != n {
+			delete(p.owners, current)
+		}
+		p.owners[n] = owner
+		return ip.String(), nil
+	}
+
+	if hasCurrent {
+		return uint32ToIP(current).String(), nil
+	}
+
+	for _, r := range p.ranges {
+		for n := r.first; ; n++ {
+			if _, ok := p.owners[n]; !ok {
+				p.owners[n] = owner
+				return uint32ToIP(n).String(), nil
+			}
+			if n == r.last {
+				break
+			}
+		}
+	}
+	return "", ErrIPPoolExhausted
+}
+
+// Reserve marks ip as owned by owner, e.g. when restoring the allocations of
+// load balancers that already exist. Addresses outside the pool are ignored.
+func (p *IPPool) Reserve(owner string, ip string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		return fmt.Errorf("invalid IPv4 address %q", ip)
+	}
+	n := ipToUint32(parsed)
+	if !p.contains(n) {
+		return nil
+	}
+	if other, ok := p.owners[n]; ok && other != owner {
+		return fmt.Errorf("load balancer IP %s is owned by both %s and %s", ip, other, owner)
+	}
+	p.owners[n] = owner
+	return nil
+}
+
+// Release frees the VIP of owner, if any.
+func (p *IPPool) Release(owner string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if n, ok := p.lookup(owner); ok {
+		delete(p.owners, n)
+	}
+}
+
+// lookup returns the address owned by owner. Callers must hold the lock.
+func (p *IPPool) lookup(owner string) (uint32, bool) {
+	for n, o := range p.owners {
+		if o == owner {
+			return n, true
+		}
+	}
+	return 0, false
+}
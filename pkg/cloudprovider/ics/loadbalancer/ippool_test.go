@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"testing"
+)
+
+func TestNewIPPool(t *testing.T) {
+	tests := []struct {
+		pool string
+		fail bool
+	}{
+		{"10.0.0.1", false},
+		{"10.0.0.1-10.0.0.5, 10.0.1.0/30", false},
+		{"", true},
+		{"10.0.0.5-10.0.0.1", true},
+		{"10.0.0.0/33", true},
+		{"fd00::1", true},
+		{"not-an-ip", true},
+	}
+
+	for _, test := range tests {
+		_, err := NewIPPool(test.pool)
+		if test.fail && err == nil {
+			t.Errorf("NewIPPool(%q) should fail", test.pool)
+		}
+		if !test.fail && err != nil {
+			t.Errorf("NewIPPool(%q) err=%v", test.pool, err)
+		}
+	}
+}
+
+func TestIPPoolAllocate(t *testing.T) {
+	// The network and broadcast addresses of the /30 are skipped.
+	pool, err := NewIPPool("10.0.0.1, 10.0.1.0/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocate := func(owner string, requested string, expected string) {
+		ip, err := pool.Allocate(owner, requested)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("Allocate(%s, %q) = %s, expected an error", owner, requested, ip)
+			}
+			return
+		}
+		if err != nil || ip != expected {
+			t.Errorf("Allocate(%s, %q) = %s, %v, expected %s", owner, requested, ip, err, expected)
+		}
+	}
+
+	allocate("a", "", "10.0.0.1")
+	allocate("a", "", "10.0.0.1")
+	allocate("b", "", "10.0.1.1")
+	allocate("c", "10.0.1.1", "")
+	allocate("c", "10.0.2.1", "")
+	allocate("c", "10.0.1.2", "10.0.1.2")
+	allocate("d", "", "")
+
+	// Moving to a requested VIP frees the previous one.
+	allocate("c", "10.0.0.1", "")
+	pool.Release("a")
+	allocate("c", "10.0.0.1", "10.0.0.1")
+	allocate("d", "", "10.0.1.2")
+
+	if err := pool.Reserve("e", "10.0.1.2"); err == nil {
+		t.Errorf("Reserve of a VIP owned by another load balancer should fail")
+	}
+	if err := pool.Reserve("e", "192.168.0.1"); err != nil {
+		t.Errorf("Reserve of a VIP outside the pool err=%v", err)
+	}
+}
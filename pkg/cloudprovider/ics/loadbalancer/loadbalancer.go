@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog"
+)
+
+const (
+	// BackendHAProxy is the backend writing HAProxy configuration files.
+	BackendHAProxy = "haproxy"
+
+	// DefaultAllocationsNamespace and DefaultAllocationsConfigMap name the
+	// ConfigMap keeping the VIP allocations when none is configured.
+	DefaultAllocationsNamespace = "kube-system"
+	DefaultAllocationsConfigMap = "ics-load-balancer-vips"
+)
+
+// Error constants
+var (
+	// ErrEmptyIPPool is returned when the ip-pool has no addresses.
+	ErrEmptyIPPool = errors.New("ip-pool has no addresses")
+
+	// ErrIPPoolExhausted is returned when every VIP of the pool is in use.
+	ErrIPPoolExhausted = errors.New("no free address left in the ip-pool")
+
+	// ErrAllocationConflict is returned when the VIP allocations changed
+	// while they were being updated.
+	ErrAllocationConflict = errors.New("VIP allocations changed concurrently")
+
+	// ErrNoNodeAddress is returned when none of the nodes has an address
+	// to forward traffic to.
+	ErrNoNodeAddress = errors.New("no node has an internal or external IP")
+)
+
+// LoadBalancer is the cloudprovider.LoadBalancer programming a Backend.
+type LoadBalancer struct {
+	backend Backend
+}
+
+// NewLoadBalancer returns the LoadBalancer programming the backend selected
+// in cfg. The VIP allocations are kept in memory until SetAllocationStore is
+// called.
+func NewLoadBalancer(cfg *Config) (*LoadBalancer, error) {
+	var backend Backend
+	var err error
+
+	switch cfg.Backend {
+	case BackendHAProxy:
+		backend, err = newHAProxyBackend(cfg)
+	default:
+		err = fmt.Errorf("unknown load balancer backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoadBalancer{backend: backend}, nil
+}
+
+// SetAllocationStore replaces the store of the VIP allocations, e.g. with
+// NewConfigMapStore once the Kubernetes client is available.
+func (lb *LoadBalancer) SetAllocationStore(store AllocationStore) {
+	lb.backend.SetAllocationStore(store)
+}
+
+// GetLoadBalancer returns whether the load balancer of the service exists,
+// and if so, its status.
+func (lb *LoadBalancer) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	name := lb.GetLoadBalancerName(ctx, clusterName, service)
+
+	vip, err := lb.backend.Get(ctx, name)
+	if err != nil {
+		klog.Errorf("Failed to get load balancer %s of service %s/%s: %v", name, service.Namespace, service.Name, err)
+		return nil, false, err
+	}
+	if vip == "" {
+		return nil, false, nil
+	}
+	return toStatus(vip), true, nil
+}
+
+// GetLoadBalancerName returns the name of the load balancer of the service.
+func (lb *LoadBalancer) GetLoadBalancerName(ctx context.Context, clusterName string, service *v1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer creates or updates the load balancer of the service and
+// returns its status.
+func (lb *LoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	spec, err := lb.newSpec(ctx, clusterName, service, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	vip, err := lb.backend.Ensure(ctx, spec)
+	if err != nil {
+		klog.Errorf("Failed to ensure load balancer %s of service %s: %v", spec.Name, spec.Service, err)
+		return nil, err
+	}
+
+	klog.V(2).Infof("Ensured load balancer %s of service %s with VIP %s", spec.Name, spec.Service, vip)
+	return toStatus(vip), nil
+}
+
+// UpdateLoadBalancer updates the nodes the load balancer of the service
+// forwards traffic to.
+func (lb *LoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
+	_, err := lb.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+	return err
+}
+
+// EnsureLoadBalancerDeleted deletes the load balancer of the service and
+// releases its VIP.
+func (lb *LoadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
+	name := lb.GetLoadBalancerName(ctx, clusterName, service)
+
+	if err := lb.backend.Delete(ctx, name); err != nil {
+		klog.Errorf("Failed to delete load balancer %s of service %s/%s: %v", name, service.Namespace, service.Name, err)
+		return err
+	}
+
+	klog.V(2).Infof("Deleted load balancer %s of service %s/%s", name, service.Namespace, service.Name)
+	return nil
+}
+
+func (lb *LoadBalancer) newSpec(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*Spec, error) {
+	spec := &Spec{
+		Name:    lb.GetLoadBalancerName(ctx, clusterName, service),
+		Service: service.Namespace + "/" + service.Name,
+		IP:      service.Spec.LoadBalancerIP,
+	}
+
+	for _, port := range service.Spec.Ports {
+		if port.Protocol != v1.ProtocolTCP {
+			return nil, fmt.Errorf("service %s port %d: protocol %s is not supported by load balancers", spec.Service, port.Port, port.Protocol)
+		}
+		spec.Ports = append(spec.Ports, Port{
+			Name:     port.Name,
+			Protocol: port.Protocol,
+			Port:     port.Port,
+			NodePort: port.NodePort,
+		})
+	}
+
+	for _, node := range nodes {
+		if address := nodeAddress(node); address != "" {
+			spec.Nodes = append(spec.Nodes, address)
+		} else {
+			klog.Warningf("Node %s has no address, it is left out of load balancer %s", node.Name, spec.Name)
+		}
+	}
+	if len(spec.Nodes) == 0 {
+		return nil, ErrNoNodeAddress
+	}
+
+	return spec, nil
+}
+
+// nodeAddress returns the internal IP of the node, or its external IP if it
+// has no internal one.
+func nodeAddress(node *v1.Node) string {
+	var external string
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case v1.NodeInternalIP:
+			return address.Address
+		case v1.NodeExternalIP:
+			if external == "" {
+				external = address.Address
+			}
+		}
+	}
+	return external
+}
+
+func toStatus(vip string) *v1.LoadBalancerStatus {
+	return &v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{{IP: vip}},
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func newService(name string, uid string, protocol v1.Protocol) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       k8stypes.UID("uid-" + uid),
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{Name: "http", Protocol: protocol, Port: 80, NodePort: 30080},
+			},
+		},
+	}
+}
+
+func newNode(name string, addresses ...v1.NodeAddress) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Addresses: addresses},
+	}
+}
+
+func TestHAProxyLoadBalancer(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "haproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{
+		Backend:          BackendHAProxy,
+		IPPool:           "10.0.0.100-10.0.0.101",
+		HAProxyConfigDir: dir,
+	}
+	store := newMemoryStore()
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer err=%v", err)
+	}
+	lb.SetAllocationStore(store)
+
+	nodes := []*v1.Node{
+		newNode("node0", v1.NodeAddress{Type: v1.NodeExternalIP, Address: "192.168.0.10"},
+			v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.10"}),
+		newNode("node1", v1.NodeAddress{Type: v1.NodeExternalIP, Address: "192.168.0.11"}),
+		newNode("node2"),
+	}
+	web := newService("web", "web", v1.ProtocolTCP)
+
+	if _, exists, err := lb.GetLoadBalancer(ctx, "kubernetes", web); err != nil || exists {
+		t.Fatalf("GetLoadBalancer exists=%t err=%v before EnsureLoadBalancer", exists, err)
+	}
+
+	status, err := lb.EnsureLoadBalancer(ctx, "kubernetes", web, nodes)
+	if err != nil {
+		t.Fatalf("EnsureLoadBalancer err=%v", err)
+	}
+	if len(status.Ingress) != 1 || status.Ingress[0].IP != "10.0.0.100" {
+		t.Fatalf("EnsureLoadBalancer status=%+v", status)
+	}
+
+	name := lb.GetLoadBalancerName(ctx, "kubernetes", web)
+	data, err := ioutil.ReadFile(filepath.Join(dir, name+haproxyConfigExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"bind 10.0.0.100:80",
+		"server node0 10.0.0.10:30080 check",
+		"server node1 192.168.0.11:30080 check",
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("HAProxy config is missing %q:\n%s", line, data)
+		}
+	}
+
+	// The VIP allocations survive a failover to an instance with another
+	// configuration directory.
+	otherDir, err := ioutil.TempDir("", "haproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(otherDir)
+	cfg.HAProxyConfigDir = otherDir
+	lb, err = NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer err=%v", err)
+	}
+	lb.SetAllocationStore(store)
+	status, exists, err := lb.GetLoadBalancer(ctx, "kubernetes", web)
+	if err != nil || !exists || status.Ingress[0].IP != "10.0.0.100" {
+		t.Fatalf("GetLoadBalancer status=%+v exists=%t err=%v after restart", status, exists, err)
+	}
+	api := newService("api", "api", v1.ProtocolTCP)
+	status, err = lb.EnsureLoadBalancer(ctx, "kubernetes", api, nodes)
+	if err != nil || status.Ingress[0].IP != "10.0.0.101" {
+		t.Fatalf("EnsureLoadBalancer status=%+v err=%v", status, err)
+	}
+
+	// The pool is exhausted until a load balancer is deleted.
+	db := newService("db", "db", v1.ProtocolTCP)
+	if _, err := lb.EnsureLoadBalancer(ctx, "kubernetes", db, nodes); err != ErrIPPoolExhausted {
+		t.Errorf("EnsureLoadBalancer err=%v, expected %v", err, ErrIPPoolExhausted)
+	}
+	if err := lb.EnsureLoadBalancerDeleted(ctx, "kubernetes", web); err != nil {
+		t.Fatalf("EnsureLoadBalancerDeleted err=%v", err)
+	}
+	if err := lb.EnsureLoadBalancerDeleted(ctx, "kubernetes", web); err != nil {
+		t.Fatalf("EnsureLoadBalancerDeleted of a deleted load balancer err=%v", err)
+	}
+	if err := lb.UpdateLoadBalancer(ctx, "kubernetes", db, nodes); err != nil {
+		t.Errorf("UpdateLoadBalancer err=%v", err)
+	}
+
+	// Only TCP is supported and some node must be reachable.
+	dns := newService("dns", "dns", v1.ProtocolUDP)
+	if _, err := lb.EnsureLoadBalancer(ctx, "kubernetes", dns, nodes); err == nil {
+		t.Errorf("EnsureLoadBalancer of a UDP service should fail")
+	}
+	if _, err := lb.EnsureLoadBalancer(ctx, "kubernetes", db, nodes[2:]); err != ErrNoNodeAddress {
+		t.Errorf("EnsureLoadBalancer err=%v, expected %v", err, ErrNoNodeAddress)
+	}
+}
+
+func TestHAProxyEnsureReleasesVIP(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "haproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lb, err := NewLoadBalancer(&Config{
+		Backend:          BackendHAProxy,
+		IPPool:           "10.0.0.100",
+		HAProxyConfigDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("NewLoadBalancer err=%v", err)
+	}
+	nodes := []*v1.Node{newNode("node0", v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.10"})}
+	web := newService("web", "web", v1.ProtocolTCP)
+
+	// The VIP is released if the configuration file cannot be written.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lb.EnsureLoadBalancer(ctx, "kubernetes", web, nodes); err == nil {
+		t.Fatalf("EnsureLoadBalancer should fail without a configuration directory")
+	}
+	if _, exists, err := lb.GetLoadBalancer(ctx, "kubernetes", web); err != nil || exists {
+		t.Fatalf("GetLoadBalancer exists=%t err=%v after a failed EnsureLoadBalancer", exists, err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	api := newService("api", "api", v1.ProtocolTCP)
+	if status, err := lb.EnsureLoadBalancer(ctx, "kubernetes", api, nodes); err != nil || status.Ingress[0].IP != "10.0.0.100" {
+		t.Fatalf("EnsureLoadBalancer status=%+v err=%v", status, err)
+	}
+}
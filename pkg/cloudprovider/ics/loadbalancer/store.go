@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// AllocationStore persists the VIPs allocated to the load balancers, so that
+// every instance of the cloud controller manager, and the next leader after
+// a failover, sees the same allocations.
+type AllocationStore interface {
+	// Load returns the VIPs by load balancer name, and the version of the
+	// allocations.
+	Load(ctx context.Context) (map[string]string, string, error)
+	// Save replaces the allocations. ErrAllocationConflict is returned if
+	// they changed since version was loaded.
+	Save(ctx context.Context, allocations map[string]string, version string) error
+}
+
+// configMapStore keeps the allocations in a ConfigMap, keyed by load
+// balancer name. The resourceVersion of the ConfigMap guards against
+// concurrent allocations.
+type configMapStore struct {
+	client    clientset.Interface
+	namespace string
+	name      string
+}
+
+// NewConfigMapStore returns an AllocationStore keeping the allocations in the
+// ConfigMap of cfg, created on the first allocation.
+func NewConfigMapStore(client clientset.Interface, cfg *Config) AllocationStore {
+	store := &configMapStore{
+		client:    client,
+		namespace: cfg.AllocationsNamespace,
+		name:      cfg.AllocationsConfigMap,
+	}
+	if store.namespace == "" {
+		store.namespace = DefaultAllocationsNamespace
+	}
+	if store.name == "" {
+		store.name = DefaultAllocationsConfigMap
+	}
+	return store
+}
+
+func (s *configMapStore) Load(ctx context.Context) (map[string]string, string, error) {
+	configMap, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	allocations := make(map[string]string, len(configMap.Data))
+	for name, vip := range configMap.Data {
+		allocations[name] = vip
+	}
+	return allocations, configMap.ResourceVersion, nil
+}
+
+func (s *configMapStore) Save(ctx context.Context, allocations map[string]string, version string) error {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            s.name,
+			Namespace:       s.namespace,
+			ResourceVersion: version,
+		},
+		Data: allocations,
+	}
+
+	var err error
+	if version == "" {
+		_, err = s.client.CoreV1().ConfigMaps(s.namespace).Create(configMap)
+	} else {
+		_, err = s.client.CoreV1().ConfigMaps(s.namespace).Update(configMap)
+	}
+	if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		return ErrAllocationConflict
+	}
+	return err
+}
+
+// memoryStore keeps the allocations in memory. It is only safe for a single
+// instance of the cloud controller manager, and is used until the
+// Kubernetes client is available.
+type memoryStore struct {
+	sync.Mutex
+
+	allocations map[string]string
+	version     int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{allocations: make(map[string]string)}
+}
+
+func (s *memoryStore) Load(ctx context.Context) (map[string]string, string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	allocations := make(map[string]string, len(s.allocations))
+	for name, vip := range s.allocations {
+		allocations[name] = vip
+	}
+	return allocations, strconv.Itoa(s.version), nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, allocations map[string]string, version string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if version != strconv.Itoa(s.version) {
+		return ErrAllocationConflict
+	}
+	s.allocations = make(map[string]string, len(allocations))
+	for name, vip := range allocations {
+		s.allocations[name] = vip
+	}
+	s.version++
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client, &Config{})
+
+	allocations, version, err := store.Load(ctx)
+	if err != nil || len(allocations) != 0 || version != "" {
+		t.Fatalf("Load allocations=%v version=%q err=%v without a ConfigMap", allocations, version, err)
+	}
+
+	allocations["web"] = "10.0.0.100"
+	if err := store.Save(ctx, allocations, version); err != nil {
+		t.Fatalf("Save err=%v", err)
+	}
+	configMap, err := client.CoreV1().ConfigMaps(DefaultAllocationsNamespace).Get(DefaultAllocationsConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get err=%v", err)
+	}
+	if configMap.Data["web"] != "10.0.0.100" {
+		t.Errorf("ConfigMap data=%v", configMap.Data)
+	}
+
+	// Another instance creating the ConfigMap at the same time conflicts.
+	if err := store.Save(ctx, map[string]string{"api": "10.0.0.100"}, ""); err != ErrAllocationConflict {
+		t.Errorf("Save err=%v, expected %v", err, ErrAllocationConflict)
+	}
+}
+
+func TestMemoryStoreConflict(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+
+	_, version, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, map[string]string{"web": "10.0.0.100"}, version); err != nil {
+		t.Fatalf("Save err=%v", err)
+	}
+	if err := store.Save(ctx, map[string]string{"api": "10.0.0.100"}, version); err != ErrAllocationConflict {
+		t.Errorf("Save err=%v, expected %v", err, ErrAllocationConflict)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancer
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Config is the [LoadBalancer] section of the cloud config.
+type Config struct {
+	// Backend programming the load balancers. Load balancer support is
+	// disabled when empty.
+	Backend string `gcfg:"backend"`
+	// IPPool is a comma separated list of the VIPs handed out to load
+	// balancers. Entries are single addresses, ranges such as
+	// 10.0.0.10-10.0.0.20 or CIDRs such as 10.0.1.0/28.
+	IPPool string `gcfg:"ip-pool"`
+	// HAProxyConfigDir is the directory the haproxy backend writes one
+	// configuration file per load balancer into.
+	HAProxyConfigDir string `gcfg:"haproxy-config-dir"`
+	// AllocationsNamespace and AllocationsConfigMap name the ConfigMap
+	// keeping the VIP allocations. Default: kube-system and
+	// ics-load-balancer-vips
+	AllocationsNamespace string `gcfg:"allocations-namespace"`
+	AllocationsConfigMap string `gcfg:"allocations-configmap"`
+}
+
+// Backend programs load balancers. Implementations own the allocation of the
+// VIPs and must be safe for concurrent use.
+type Backend interface {
+	// Get returns the VIP of the named load balancer, or "" if it does not
+	// exist.
+	Get(ctx context.Context, name string) (string, error)
+	// Ensure creates or updates the load balancer described by spec and
+	// returns its VIP.
+	Ensure(ctx context.Context, spec *Spec) (string, error)
+	// Delete removes the named load balancer and releases its VIP. Deleting
+	// a load balancer that does not exist is not an error.
+	Delete(ctx context.Context, name string) error
+	// SetAllocationStore replaces the store of the VIP allocations.
+	SetAllocationStore(store AllocationStore)
+}
+
+// Spec describes a load balancer in backend terms.
+type Spec struct {
+	// Name is the name of the load balancer.
+	Name string
+	// Service is the namespace/name of the Service it serves.
+	Service string
+	// IP is the VIP requested through spec.loadBalancerIP, if any.
+	IP string
+	// Ports are the ports to balance.
+	Ports []Port
+	// Nodes are the addresses of the nodes traffic is forwarded to.
+	Nodes []string
+}
+
+// Port is a port of a load balancer forwarded to a node port.
+type Port struct {
+	Name     string
+	Protocol v1.Protocol
+	Port     int32
+	NodePort int32
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+const (
+	// MaintenanceTaintKey is the key of the taint applied to the nodes whose
+	// host is in maintenance.
+	MaintenanceTaintKey = "ics.inspur.com/host-maintenance"
+
+	// MaintenanceCordonedAnnotation marks the nodes cordoned because their
+	// host is in maintenance, so that only those are uncordoned afterwards.
+	MaintenanceCordonedAnnotation = "ics.inspur.com/maintenance-cordoned"
+
+	// MaintenanceTaintHostState and MaintenanceTaintHALost are the values of
+	// the maintenance taint, whether the host is in one of the maintenance
+	// states or its cluster lost HA. The latter is kept across restarts while
+	// HA stays disabled.
+	MaintenanceTaintHostState = "host-state"
+	MaintenanceTaintHALost    = "ha-lost"
+
+	// MaintenanceSyncInterval is how often the hosts of the nodes are checked
+	// for maintenance.
+	MaintenanceSyncInterval = 30 * time.Second
+)
+
+// maintenanceController taints, and optionally cordons and drains, the nodes
+// whose host is in maintenance, and reverts that once the host is back.
+type maintenanceController struct {
+	nodeManager *NodeManager
+	client      clientset.Interface
+
+	effect     v1.TaintEffect
+	hostStates map[string]bool
+	haRequired bool
+	cordon     bool
+	drain      bool
+
+	// Maps tenantRef/clusterID to whether HA was enabled at the last sync,
+	// and to whether HA was disabled since.
+	haEnabled map[string]bool
+	haLost    map[string]bool
+}
+
+// hostMaintenance is the maintenance state of a host.
+type hostMaintenance struct {
+	// reason is the value of the taint of the nodes on the host, empty if the
+	// host is not in maintenance.
+	reason string
+	// haDisabled is true if HA is required and disabled on the cluster of the
+	// host.
+	haDisabled bool
+}
+
+// newMaintenanceController returns a maintenanceController, or nil if no
+// taint effect is configured. The client is set once available.
+func newMaintenanceController(nodeManager *NodeManager, cfg *CPIConfig) (*maintenanceController, error) {
+	if cfg.Maintenance.TaintEffect == "" {
+		return nil, nil
+	}
+
+	effect := v1.TaintEffect(cfg.Maintenance.TaintEffect)
+	if effect != v1.TaintEffectNoSchedule && effect != v1.TaintEffectNoExecute {
+		return nil, fmt.Errorf("invalid maintenance taint-effect %q, must be %s or %s",
+			effect, v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute)
+	}
+
+	// The SDK does not define the host states, so those meaning maintenance
+	// must come from the configuration.
+	hostStates := make(map[string]bool)
+	for _, state := range cfg.Maintenance.HostStates {
+		if state = strings.TrimSpace(state); state != "" {
+			hostStates[strings.ToUpper(state)] = true
+		}
+	}
+	if len(hostStates) == 0 {
+		return nil, fmt.Errorf("maintenance host-state must be set with taint-effect")
+	}
+
+	return &maintenanceController{
+		nodeManager: nodeManager,
+		effect:      effect,
+		hostStates:  hostStates,
+		haRequired:  cfg.Maintenance.HARequired,
+		haEnabled:   make(map[string]bool),
+		haLost:      make(map[string]bool),
+		cordon:      cfg.Maintenance.Cordon || cfg.Maintenance.Drain,
+		drain:       cfg.Maintenance.Drain,
+	}, nil
+}
+
+// Run syncs the nodes every MaintenanceSyncInterval until stopCh is closed.
+// It does not block.
+func (c *maintenanceController) Run(stopCh <-chan struct{}) {
+	go wait.Until(func() {
+		c.sync(context.Background())
+	}, MaintenanceSyncInterval, stopCh)
+}
+
+// sync maps the hosts of every datacenter holding a registered node to the
+// nodes running on them, and updates each node.
+func (c *maintenanceController) sync(ctx context.Context) {
+	registered := make(map[string]string)
+	c.nodeManager.nodeRegInfoLock.RLock()
+	for uuid, node := range c.nodeManager.nodeRegUUIDMap {
+		registered[uuid] = node.Name
+	}
+	c.nodeManager.nodeRegInfoLock.RUnlock()
+
+	// Maps node name to its cached NodeInfo.
+	nodes := make(map[string]*NodeInfo)
+	for uuid, nodeName := range registered {
+		if nodeInfo := c.nodeManager.cachedNodeInfo(uuid, cm.FindVMByUUID); nodeInfo != nil {
+			nodes[nodeName] = nodeInfo
+		}
+	}
+
+	// Maps tenantRef to the maintenance state of its hosts by ID.
+	maintenance := make(map[string]map[string]hostMaintenance)
+	for _, nodeInfo := range nodes {
+		if _, ok := maintenance[nodeInfo.tenantRef]; ok {
+			continue
+		}
+		hosts, err := c.hostsInMaintenance(ctx, nodeInfo.tenantRef)
+		if err != nil {
+			klog.Warningf("Failed to list the hosts in maintenance of %s: %v", nodeInfo.tenantRef, err)
+			continue
+		}
+		maintenance[nodeInfo.tenantRef] = hosts
+	}
+
+	for nodeName, nodeInfo := range nodes {
+		hosts, ok := maintenance[nodeInfo.tenantRef]
+		if !ok {
+			continue
+		}
+		if err := c.syncNode(nodeName, hosts[nodeInfo.vm.HostID]); err != nil {
+			klog.Warningf("Failed to sync the maintenance taint of node %s: %v", nodeName, err)
+		}
+	}
+}
+
+// hostsInMaintenance returns the maintenance state of the hosts of the
+// configured datacenters of the iCenter, by host ID.
+func (c *maintenanceController) hostsInMaintenance(ctx context.Context, tenantRef string) (map[string]hostMaintenance, error) {
+	instance := c.nodeManager.connectionManager.Instances()[tenantRef]
+	if instance == nil {
+		return nil, ErrICenterNotFound
+	}
+	if err := c.nodeManager.connectionManager.Connect(ctx, instance); err != nil {
+		return nil, err
+	}
+
+	// Maps cluster ID to whether its HA is disabled, and to whether it was
+	// disabled since this controller saw it enabled.
+	unprotected := make(map[string]bool)
+	haLost := make(map[string]bool)
+	if c.haRequired {
+		clusters, err := icslib.GetAllClusters(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			unprotected[cluster.Id] = !cluster.HA.HAEnabled
+			haLost[cluster.Id] = c.haDisabled(tenantRef+"/"+cluster.Id, cluster.HA.HAEnabled)
+		}
+	}
+
+	datacenters, err := icslib.GetAllDatacenter(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]hostMaintenance)
+	for _, datacenter := range datacenters {
+		if !datacenterConfigured(instance, datacenter) {
+			continue
+		}
+		hostSystems, err := icslib.GetHostSystemListByDC(ctx, instance, datacenter.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hostSystems {
+			state := hostMaintenance{haDisabled: unprotected[host.ClusterID]}
+			switch {
+			case c.hostStates[strings.ToUpper(host.Status)]:
+				state.reason = MaintenanceTaintHostState
+			case haLost[host.ClusterID]:
+				state.reason = MaintenanceTaintHALost
+			}
+			if state.reason != "" {
+				klog.V(4).Infof("Host %s of %s is in maintenance, status=%s reason=%s", host.Name, tenantRef, host.Status, state.reason)
+			}
+			hosts[host.ID] = state
+		}
+	}
+	return hosts, nil
+}
+
+// haDisabled records whether HA is enabled on the cluster, and returns true
+// if HA was disabled after being seen enabled. Clusters never seen with HA
+// are not treated as in maintenance, unless their nodes already carry the
+// MaintenanceTaintHALost taint, see syncNode.
+func (c *maintenanceController) haDisabled(cluster string, enabled bool) bool {
+	if enabled {
+		delete(c.haLost, cluster)
+	} else if c.haEnabled[cluster] {
+		c.haLost[cluster] = true
+	}
+	c.haEnabled[cluster] = enabled
+	return c.haLost[cluster]
+}
+
+// syncNode taints, and optionally cordons and drains, the node while its host
+// is in maintenance. Otherwise it removes the taint, and uncordons the node
+// if it was cordoned here. A node tainted because its cluster lost HA stays
+// so while HA is disabled, even if that was before a restart.
+func (c *maintenanceController) syncNode(nodeName string, state hostMaintenance) error {
+	node, err := c.client.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	updated := node.DeepCopy()
+
+	taint := -1
+	for i := range updated.Spec.Taints {
+		if updated.Spec.Taints[i].Key == MaintenanceTaintKey {
+			taint = i
+		}
+	}
+	_, cordoned := updated.Annotations[MaintenanceCordonedAnnotation]
+
+	reason := state.reason
+	if reason == "" && state.haDisabled && taint >= 0 && updated.Spec.Taints[taint].Value == MaintenanceTaintHALost {
+		reason = MaintenanceTaintHALost
+	}
+	maintenance := reason != ""
+
+	changed := false
+	if maintenance {
+		if taint < 0 {
+			now := metav1.Now()
+			updated.Spec.Taints = append(updated.Spec.Taints, v1.Taint{
+				Key:       MaintenanceTaintKey,
+				Value:     reason,
+				Effect:    c.effect,
+				TimeAdded: &now,
+			})
+			changed = true
+		} else if updated.Spec.Taints[taint].Value != reason {
+			updated.Spec.Taints[taint].Value = reason
+			changed = true
+		}
+		if c.cordon && !updated.Spec.Unschedulable {
+			updated.Spec.Unschedulable = true
+			if updated.Annotations == nil {
+				updated.Annotations = make(map[string]string)
+			}
+			updated.Annotations[MaintenanceCordonedAnnotation] = "true"
+			changed = true
+		}
+	} else {
+		if taint >= 0 {
+			updated.Spec.Taints = append(updated.Spec.Taints[:taint], updated.Spec.Taints[taint+1:]...)
+			changed = true
+		}
+		if cordoned {
+			updated.Spec.Unschedulable = false
+			delete(updated.Annotations, MaintenanceCordonedAnnotation)
+			changed = true
+		}
+	}
+
+	if changed {
+		if _, err := c.client.CoreV1().Nodes().Update(updated); err != nil {
+			return err
+		}
+		klog.V(2).Infof("Node %s updated for maintenance=%t", nodeName, maintenance)
+	}
+
+	if maintenance && c.drain {
+		return c.drainNode(nodeName)
+	}
+	return nil
+}
+
+// drainNode evicts the pods of the node, except for mirror and DaemonSet
+// pods. Evictions refused by a PodDisruptionBudget are retried on the next
+// sync.
+func (c *maintenanceController) drainNode(nodeName string) error {
+	pods, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods.Items {
+		if _, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]; mirror {
+			continue
+		}
+		if controller := metav1.GetControllerOf(&pod); controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		err := c.client.CoreV1().Pods(pod.Namespace).Evict(&policy.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		switch {
+		case err == nil:
+			klog.V(2).Infof("Evicted pod %s/%s from node %s", pod.Namespace, pod.Name, nodeName)
+		case apierrors.IsNotFound(err):
+		case apierrors.IsTooManyRequests(err):
+			klog.V(2).Infof("Eviction of pod %s/%s from node %s refused, retrying later", pod.Namespace, pod.Name, nodeName)
+		default:
+			return err
+		}
+	}
+	return nil
+}
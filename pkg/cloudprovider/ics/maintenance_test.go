@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+func TestNewMaintenanceController(t *testing.T) {
+	cfg := &CPIConfig{}
+	if c, err := newMaintenanceController(nil, cfg); c != nil || err != nil {
+		t.Errorf("maintenance should be disabled without a taint effect, got %v, %v", c, err)
+	}
+
+	cfg.Maintenance.TaintEffect = "PreferNoSchedule"
+	if _, err := newMaintenanceController(nil, cfg); err == nil {
+		t.Error("an invalid taint effect should fail")
+	}
+
+	cfg.Maintenance.TaintEffect = "NoExecute"
+	cfg.Maintenance.Drain = true
+	if _, err := newMaintenanceController(nil, cfg); err == nil {
+		t.Error("a taint effect without host states should fail")
+	}
+
+	cfg.Maintenance.HostStates = []string{" maintain "}
+	c, err := newMaintenanceController(nil, cfg)
+	if err != nil {
+		t.Fatalf("newMaintenanceController err=%v", err)
+	}
+	if !c.cordon {
+		t.Error("drain should imply cordon")
+	}
+	if len(c.hostStates) != 1 || !c.hostStates["MAINTAIN"] {
+		t.Errorf("host states = %v", c.hostStates)
+	}
+}
+
+func TestMaintenanceController(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	nm := newNodeManager(nil, connMgr)
+	cpiCfg := &CPIConfig{}
+	cpiCfg.Maintenance.TaintEffect = string(v1.TaintEffectNoSchedule)
+	cpiCfg.Maintenance.HostStates = []string{"MAINTAIN"}
+	cpiCfg.Maintenance.Cordon = true
+	c, err := newMaintenanceController(nm, cpiCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := model.VMs()[0]
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: vm.Name},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: vm.UUID},
+		},
+	}
+	c.client = fake.NewSimpleClientset(node)
+	nm.RegisterNode(node)
+
+	sync := func() *v1.Node {
+		c.sync(ctx)
+		node, err := c.client.CoreV1().Nodes().Get(vm.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	tainted := func(node *v1.Node) bool {
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == MaintenanceTaintKey && taint.Effect == v1.TaintEffectNoSchedule {
+				return true
+			}
+		}
+		return false
+	}
+	setStatus := func(status string) {
+		if err := model.UpdateHost(vm.HostID, func(host *types.Host) {
+			host.Status = status
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if node := sync(); tainted(node) || node.Spec.Unschedulable {
+		t.Errorf("node should not be tainted nor cordoned, got %+v", node.Spec)
+	}
+
+	setStatus("MAINTAIN")
+	node = sync()
+	if !tainted(node) || !node.Spec.Unschedulable {
+		t.Errorf("node should be tainted and cordoned, got %+v", node.Spec)
+	}
+	if _, ok := node.Annotations[MaintenanceCordonedAnnotation]; !ok {
+		t.Error("cordoned annotation missing")
+	}
+
+	setStatus("CONNECTED")
+	node = sync()
+	if tainted(node) || node.Spec.Unschedulable {
+		t.Errorf("node should be untainted and uncordoned, got %+v", node.Spec)
+	}
+	if _, ok := node.Annotations[MaintenanceCordonedAnnotation]; ok {
+		t.Error("cordoned annotation should be removed")
+	}
+
+	// A node cordoned by someone else stays cordoned.
+	node.Spec.Unschedulable = true
+	if _, err := c.client.CoreV1().Nodes().Update(node); err != nil {
+		t.Fatal(err)
+	}
+	if node := sync(); !node.Spec.Unschedulable {
+		t.Error("a node not cordoned for maintenance should stay cordoned")
+	}
+
+	// With ha-required, a host of a cluster whose HA was disabled counts as in
+	// maintenance.
+	c.haRequired = true
+	host := model.Hosts()[0]
+	for _, h := range model.Hosts() {
+		if h.ID == vm.HostID {
+			host = h
+		}
+	}
+	setHA := func(enabled bool) {
+		if err := model.UpdateCluster(host.ClusterID, func(cluster *types.Cluster) {
+			cluster.HA.HAEnabled = enabled
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setHA(false)
+	if node := sync(); tainted(node) {
+		t.Error("node of a cluster never seen with HA should not be tainted")
+	}
+	setHA(true)
+	if node := sync(); tainted(node) {
+		t.Error("node of an HA cluster should not be tainted")
+	}
+	setHA(false)
+	if node := sync(); !tainted(node) {
+		t.Error("node of a cluster whose HA was disabled should be tainted")
+	}
+	if node := sync(); !tainted(node) {
+		t.Error("node should stay tainted while HA is disabled")
+	}
+
+	// After a restart, the taint of a cluster that lost HA is kept until HA
+	// is enabled again.
+	restarted, err := newMaintenanceController(nm, cpiCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.client = c.client
+	restarted.haRequired = true
+	c = restarted
+	if node := sync(); !tainted(node) || !node.Spec.Unschedulable {
+		t.Errorf("node should stay tainted and cordoned after a restart, got %+v", node.Spec)
+	}
+	setHA(true)
+	if node := sync(); tainted(node) {
+		t.Error("node should be untainted once HA is enabled again")
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/klog"
+
+	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/server"
+)
+
+// NodeEventHistory is the number of node events kept to resume watches.
+const NodeEventHistory = 1024
+
+type nodeEvent struct {
+	seq   uint64
+	event *pb.NodeEvent
+}
+
+// nodeEvents records the changes of the nodes exported by the API, and sends
+// them to the watchers. Resume tokens are the sequence number of the event,
+// prefixed by an epoch so that tokens of a previous process are rejected.
+type nodeEvents struct {
+	epoch string
+
+	lock sync.Mutex
+	seq  uint64
+	// Maps UUID to the node as last sent.
+	nodes map[string]*pb.Node
+	// The last NodeEventHistory events, oldest first.
+	history []nodeEvent
+	// Closed and replaced by every new event.
+	changed chan struct{}
+}
+
+func newNodeEvents() *nodeEvents {
+	return &nodeEvents{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		nodes:   make(map[string]*pb.Node),
+		changed: make(chan struct{}),
+	}
+}
+
+func (e *nodeEvents) resumeToken(seq uint64) string {
+	return fmt.Sprintf("%s-%d", e.epoch, seq)
+}
+
+func (e *nodeEvents) parseResumeToken(token string) (uint64, error) {
+	i := strings.LastIndex(token, "-")
+	if i < 0 || token[:i] != e.epoch {
+		return 0, server.ErrResumeTokenExpired
+	}
+	seq, err := strconv.ParseUint(token[i+1:], 10, 64)
+	if err != nil {
+		return 0, server.ErrResumeTokenExpired
+	}
+	return seq, nil
+}
+
+// update records the event turning the node last sent for uuid into the one
+// returned by current, nil meaning the node is not exported. current is
+// called with the lock held, so that concurrent updates are recorded in the
+// order of the states they read.
+func (e *nodeEvents) update(uuid string, current func() *pb.Node) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	node := current()
+	last := e.nodes[uuid]
+
+	event := &pb.NodeEvent{Node: node}
+	switch {
+	case node != nil && last == nil:
+		event.Type = pb.NodeEvent_ADDED
+		e.nodes[uuid] = node
+	case node != nil && !proto.Equal(node, last):
+		event.Type = pb.NodeEvent_MODIFIED
+		e.nodes[uuid] = node
+	case node == nil && last != nil:
+		event.Type = pb.NodeEvent_DELETED
+		event.Node = last
+		delete(e.nodes, uuid)
+	default:
+		return
+	}
+
+	e.seq++
+	event.ResumeToken = e.resumeToken(e.seq)
+	e.history = append(e.history, nodeEvent{seq: e.seq, event: event})
+	if len(e.history) > NodeEventHistory {
+		e.history = e.history[len(e.history)-NodeEventHistory:]
+	}
+	close(e.changed)
+	e.changed = make(chan struct{})
+
+	klog.V(4).Infof("Node event %s %s, resumeToken=%s", event.Type, uuid, event.ResumeToken)
+}
+
+// since returns the events after seq, or ErrResumeTokenExpired if some of
+// them are no longer kept. It must be called with the lock held.
+func (e *nodeEvents) since(seq uint64) ([]*pb.NodeEvent, error) {
+	if seq > e.seq {
+		return nil, server.ErrResumeTokenExpired
+	}
+	if seq == e.seq {
+		return nil, nil
+	}
+	if len(e.history) == 0 || e.history[0].seq > seq+1 {
+		return nil, server.ErrResumeTokenExpired
+	}
+
+	events := make([]*pb.NodeEvent, 0, e.seq-seq)
+	for _, recorded := range e.history {
+		if recorded.seq > seq {
+			events = append(events, recorded.event)
+		}
+	}
+	return events, nil
+}
+
+// watch sends the events after resumeToken, or an ADDED event for every node
+// if resumeToken is empty, and then every new event until ctx is done or
+// send fails.
+func (e *nodeEvents) watch(ctx context.Context, resumeToken string, send func(*pb.NodeEvent) error) error {
+	e.lock.Lock()
+	var events []*pb.NodeEvent
+	cursor := e.seq
+	if resumeToken == "" {
+		for _, node := range e.nodes {
+			events = append(events, &pb.NodeEvent{
+				Type:        pb.NodeEvent_ADDED,
+				Node:        node,
+				ResumeToken: e.resumeToken(cursor),
+			})
+		}
+	} else {
+		seq, err := e.parseResumeToken(resumeToken)
+		if err == nil {
+			events, err = e.since(seq)
+		}
+		if err != nil {
+			e.lock.Unlock()
+			return err
+		}
+	}
+	changed := e.changed
+	e.lock.Unlock()
+
+	for {
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+
+		var err error
+		e.lock.Lock()
+		events, err = e.since(cursor)
+		cursor = e.seq
+		changed = e.changed
+		e.lock.Unlock()
+		if err != nil {
+			// The watcher fell too far behind.
+			return err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/server"
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+// collect watches e from resumeToken and returns the first n events.
+func collect(t *testing.T, e *nodeEvents, resumeToken string, n int) []*pb.NodeEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var events []*pb.NodeEvent
+	err := e.watch(ctx, resumeToken, func(event *pb.NodeEvent) error {
+		events = append(events, event)
+		if len(events) == n {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("watch(%q) err=%v", resumeToken, err)
+	}
+	return events
+}
+
+func TestNodeEvents(t *testing.T) {
+	e := newNodeEvents()
+	node := func(name string) func() *pb.Node {
+		return func() *pb.Node {
+			if name == "" {
+				return nil
+			}
+			return &pb.Node{Name: name, Uuid: "uuid"}
+		}
+	}
+
+	e.update("uuid", node("first"))
+	e.update("uuid", node("first"))
+	snapshot := collect(t, e, "", 1)
+	if len(snapshot) != 1 || snapshot[0].Type != pb.NodeEvent_ADDED || snapshot[0].Node.Name != "first" {
+		t.Fatalf("unexpected snapshot %v", snapshot)
+	}
+
+	e.update("uuid", node("second"))
+	e.update("uuid", node(""))
+	e.update("uuid", node(""))
+	events := collect(t, e, snapshot[0].ResumeToken, 2)
+	if events[0].Type != pb.NodeEvent_MODIFIED || events[0].Node.Name != "second" {
+		t.Errorf("expected MODIFIED second, got %v", events[0])
+	}
+	if events[1].Type != pb.NodeEvent_DELETED || events[1].Node.Name != "second" {
+		t.Errorf("expected DELETED second, got %v", events[1])
+	}
+
+	// Resuming from the middle sends the rest only.
+	if events := collect(t, e, events[0].ResumeToken, 1); events[0].Type != pb.NodeEvent_DELETED {
+		t.Errorf("expected DELETED, got %v", events[0])
+	}
+
+	// The snapshot of an empty cache is empty, new events follow.
+	go e.update("uuid", node("third"))
+	if events := collect(t, e, "", 1); events[0].Type != pb.NodeEvent_ADDED || events[0].Node.Name != "third" {
+		t.Errorf("expected ADDED third, got %v", events[0])
+	}
+
+	for _, token := range []string{"other-1", "garbage", e.resumeToken(e.seq + 1)} {
+		if err := e.watch(context.Background(), token, nil); err != server.ErrResumeTokenExpired {
+			t.Errorf("watch(%q) err=%v, expected %v", token, err, server.ErrResumeTokenExpired)
+		}
+	}
+
+	token := e.resumeToken(e.seq)
+	for i := 0; i <= NodeEventHistory; i++ {
+		e.update("uuid", node(strings.Repeat("x", i%2+1)))
+	}
+	if err := e.watch(context.Background(), token, nil); err != server.ErrResumeTokenExpired {
+		t.Errorf("watch() beyond the history err=%v, expected %v", err, server.ErrResumeTokenExpired)
+	}
+}
+
+func TestNodeManagerWatchNodes(t *testing.T) {
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	nm := newNodeManager(nil, connMgr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := make(chan *pb.NodeEvent, 10)
+	go nm.WatchNodes(ctx, "", func(event *pb.NodeEvent) error {
+		events <- event
+		return nil
+	})
+	next := func() *pb.NodeEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a node event")
+		}
+		return nil
+	}
+
+	vm := model.VMs()[0]
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: vm.Name},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: strings.ToLower(vm.UUID)},
+		},
+	}
+
+	nm.RegisterNode(node)
+	added := next()
+	if added.Type != pb.NodeEvent_ADDED || added.Node.Uuid != strings.ToLower(vm.UUID) || added.Node.Name != vm.Name {
+		t.Errorf("expected ADDED %s, got %v", vm.Name, added)
+	}
+
+	nm.UnregisterNode(node)
+	if deleted := next(); deleted.Type != pb.NodeEvent_DELETED || deleted.Node.Name != vm.Name {
+		t.Errorf("expected DELETED %s, got %v", vm.Name, deleted)
+	}
+
+	// Resuming after the ADDED event only sends the DELETED one.
+	var resumed []*pb.NodeEvent
+	resumeCtx, resumeCancel := context.WithCancel(context.Background())
+	nm.WatchNodes(resumeCtx, added.ResumeToken, func(event *pb.NodeEvent) error {
+		resumed = append(resumed, event)
+		resumeCancel()
+		return nil
+	})
+	if len(resumed) != 1 || resumed[0].Type != pb.NodeEvent_DELETED {
+		t.Errorf("unexpected resumed events %v", resumed)
+	}
+}
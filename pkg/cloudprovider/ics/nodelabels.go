@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+const (
+	// DefaultNodeLabelPrefix is the default prefix of the node labels synced
+	// from VM tags.
+	DefaultNodeLabelPrefix = "ics.inspur.com/"
+
+	// NodeLabelSyncInterval is how often the labels of every registered node
+	// are synced with the tags of its VM.
+	NodeLabelSyncInterval = 1 * time.Minute
+)
+
+// nodeLabeler keeps the labels of the registered nodes in sync with the tags
+// attached to their VMs. Only the label keys of the configured tag categories
+// are managed, other labels are never touched.
+type nodeLabeler struct {
+	nodeManager *NodeManager
+	client      clientset.Interface
+
+	// Maps tag category to label key.
+	keys map[string]string
+}
+
+// newNodeLabeler returns a nodeLabeler for the configured tag categories, or
+// nil if no category is configured. The client is set once available.
+func newNodeLabeler(nodeManager *NodeManager, cfg *CPIConfig) (*nodeLabeler, error) {
+	if len(cfg.NodeLabels.Categories) == 0 {
+		return nil, nil
+	}
+
+	prefix := cfg.NodeLabels.Prefix
+	if prefix == "" {
+		prefix = DefaultNodeLabelPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	keys := make(map[string]string)
+	for _, category := range cfg.NodeLabels.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" {
+			continue
+		}
+		name := category
+		if i := strings.LastIndex(category, ":"); i >= 0 {
+			category, name = category[:i], category[i+1:]
+		}
+		key := prefix + name
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid node label %q for tag category %q: %s",
+				key, category, strings.Join(errs, "; "))
+		}
+		keys[category] = key
+	}
+
+	return &nodeLabeler{nodeManager: nodeManager, keys: keys}, nil
+}
+
+// Run syncs the labels of every registered node every NodeLabelSyncInterval
+// until stopCh is closed. It does not block.
+func (l *nodeLabeler) Run(stopCh <-chan struct{}) {
+	go wait.Until(func() {
+		l.syncAll(context.Background())
+	}, NodeLabelSyncInterval, stopCh)
+}
+
+func (l *nodeLabeler) syncAll(ctx context.Context) {
+	nodes := make(map[string]string)
+	l.nodeManager.nodeRegInfoLock.RLock()
+	for uuid, node := range l.nodeManager.nodeRegUUIDMap {
+		nodes[uuid] = node.Name
+	}
+	l.nodeManager.nodeRegInfoLock.RUnlock()
+
+	for uuid, nodeName := range nodes {
+		if err := l.syncNode(ctx, uuid, nodeName); err != nil {
+			klog.Warningf("Failed to sync the labels of node %s: %v", nodeName, err)
+		}
+	}
+}
+
+// syncNode sets the managed labels of the node to the tags of its VM, and
+// removes the managed labels whose tags are no longer attached.
+func (l *nodeLabeler) syncNode(ctx context.Context, uuid string, nodeName string) error {
+	nodeInfo, err := l.nodeManager.lookupNodeInfo(uuid, cm.FindVMByUUID)
+	if err != nil {
+		return err
+	}
+	vmTags, err := l.nodeManager.connectionManager.LookupTagsByVM(ctx, nodeInfo.tenantRef, nodeInfo.vm.ID)
+	if err != nil {
+		return err
+	}
+
+	node, err := l.client.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	patch := l.labelPatch(node, l.desiredLabels(vmTags))
+	if len(patch) == 0 {
+		klog.V(5).Infof("Labels of node %s are in sync", nodeName)
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": patch,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := l.client.CoreV1().Nodes().Patch(nodeName, types.MergePatchType, data); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("Synced the labels of node %s: %s", nodeName, string(data))
+	return nil
+}
+
+// desiredLabels maps the tags of the configured categories to labels. If more
+// than one tag of a category is attached, the first in order is used.
+func (l *nodeLabeler) desiredLabels(vmTags map[string][]string) map[string]string {
+	labels := make(map[string]string)
+	for category, key := range l.keys {
+		names := vmTags[category]
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		if len(names) > 1 {
+			klog.Warningf("Tags %v of category %s are attached, using %s for label %s", names, category, names[0], key)
+		}
+		if errs := validation.IsValidLabelValue(names[0]); len(errs) > 0 {
+			klog.Warningf("Tag %s of category %s is not a valid label value: %s", names[0], category, strings.Join(errs, "; "))
+			continue
+		}
+		labels[key] = names[0]
+	}
+	return labels
+}
+
+// labelPatch returns the merge patch of the labels turning the managed labels
+// of the node into the desired ones. A nil value removes the label.
+func (l *nodeLabeler) labelPatch(node *v1.Node, desired map[string]string) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for _, key := range l.keys {
+		current, ok := node.Labels[key]
+		value, want := desired[key]
+		switch {
+		case want && (!ok || current != value):
+			patch[key] = value
+		case !want && ok:
+			patch[key] = nil
+		}
+	}
+	return patch
+}
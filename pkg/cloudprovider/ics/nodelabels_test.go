@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+func TestNewNodeLabeler(t *testing.T) {
+	cfg := &CPIConfig{}
+	if l, err := newNodeLabeler(nil, cfg); l != nil || err != nil {
+		t.Errorf("labels should be disabled without categories, got %v, %v", l, err)
+	}
+
+	cfg.NodeLabels.Categories = []string{"hardware", " compliance:pci ", ""}
+	l, err := newNodeLabeler(nil, cfg)
+	if err != nil {
+		t.Fatalf("newNodeLabeler err=%v", err)
+	}
+	expected := map[string]string{
+		"hardware":   DefaultNodeLabelPrefix + "hardware",
+		"compliance": DefaultNodeLabelPrefix + "pci",
+	}
+	if !reflect.DeepEqual(l.keys, expected) {
+		t.Errorf("keys = %v, expected %v", l.keys, expected)
+	}
+
+	cfg.NodeLabels.Prefix = "example.com"
+	cfg.NodeLabels.Categories = []string{"hardware"}
+	if l, err = newNodeLabeler(nil, cfg); err != nil || l.keys["hardware"] != "example.com/hardware" {
+		t.Errorf("prefix without a trailing slash, got %v, %v", l, err)
+	}
+
+	cfg.NodeLabels.Categories = []string{"hardware:not a label"}
+	if _, err := newNodeLabeler(nil, cfg); err == nil {
+		t.Error("an invalid label key should fail")
+	}
+}
+
+func TestNodeLabeler(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	nm := newNodeManager(nil, connMgr)
+	cpiCfg := &CPIConfig{}
+	cpiCfg.NodeLabels.Categories = []string{"hardware", "compliance:pci"}
+	l, err := newNodeLabeler(nm, cpiCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := model.VMs()[0]
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   vm.Name,
+			Labels: map[string]string{"unmanaged": "kept"},
+		},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: vm.UUID},
+		},
+	}
+	l.client = fake.NewSimpleClientset(node)
+	nm.RegisterNode(node)
+
+	gpuID := model.AddTag(types.Tag{Name: "gpu", Description: "hardware"})
+	pciID := model.AddTag(types.Tag{Name: "pci-dss", Description: "compliance"})
+	otherID := model.AddTag(types.Tag{Name: "other", Description: "unconfigured"})
+	for _, tagID := range []string{gpuID, pciID, otherID} {
+		if err := model.AttachTag(tagID, vm.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	labels := func() map[string]string {
+		l.syncAll(ctx)
+		node, err := l.client.CoreV1().Nodes().Get(vm.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node.Labels
+	}
+
+	expected := map[string]string{
+		"unmanaged":                         "kept",
+		DefaultNodeLabelPrefix + "hardware": "gpu",
+		DefaultNodeLabelPrefix + "pci":      "pci-dss",
+	}
+	if actual := labels(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("labels = %v, expected %v", actual, expected)
+	}
+
+	// Detaching a tag removes its label. The fake clientset cannot remove map
+	// keys through a patch, so the patch is checked instead.
+	if err := model.DetachTag(pciID, vm.ID); err != nil {
+		t.Fatal(err)
+	}
+	synced := node.DeepCopy()
+	synced.Labels = expected
+	client := fake.NewSimpleClientset(synced)
+	l.client = client
+	l.syncAll(ctx)
+
+	var patch string
+	for _, action := range client.Actions() {
+		if action, ok := action.(k8stesting.PatchAction); ok {
+			patch = string(action.GetPatch())
+		}
+	}
+	expectedPatch := `{"metadata":{"labels":{"ics.inspur.com/pci":null}}}`
+	if patch != expectedPatch {
+		t.Errorf("patch = %s, expected %s", patch, expectedPatch)
+	}
+}
@@ -26,6 +26,8 @@ import (
 	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 	v1 "k8s.io/api/core/v1"
 	v1helper "k8s.io/cloud-provider/node/helpers"
 	"k8s.io/klog"
@@ -52,6 +54,8 @@ func newNodeManager(cpiCfg *CPIConfig, cm *cm.ConnectionManager) *NodeManager {
 		nodeRegUUIDMap:    make(map[string]*v1.Node),
 		icsList:            make(map[string]*ICenterInfo),
 		connectionManager: cm,
+		nodeEvents:        newNodeEvents(),
+		hostZones:         make(map[string]*hostZone),
 		cpiCfg:            cpiCfg,
 	}
 }
@@ -79,7 +83,151 @@ func (nm *NodeManager) addNodeInfo(node *NodeInfo) {
 	nm.nodeNameMap[node.NodeName] = node
 	nm.nodeUUIDMap[node.UUID] = node
 	nm.AddNodeInfoToICSList(node.icsServer, node.dataCenter.Name, node)
+	metrics.SetNodeCacheSize(len(nm.nodeUUIDMap))
 	nm.nodeInfoLock.Unlock()
+	nm.publishNode(node.UUID)
+}
+
+// replaceNodeInfo replaces the cached node with updated, unless it has been
+// removed or replaced since it was read. It returns true if it did.
+func (nm *NodeManager) replaceNodeInfo(node *NodeInfo, updated *NodeInfo) bool {
+	nm.nodeInfoLock.Lock()
+	if nm.nodeUUIDMap[node.UUID] != node {
+		nm.nodeInfoLock.Unlock()
+		return false
+	}
+	klog.V(4).Info("replaceNodeInfo NodeName: ", updated.NodeName, ", UUID: ", updated.UUID)
+	if nm.nodeNameMap[node.NodeName] == node {
+		delete(nm.nodeNameMap, node.NodeName)
+	}
+	if dc, err := nm.FindDatacenterInfoInICSList(node.icsServer, node.dataCenter.Name); err == nil && dc.vmList[node.UUID] == node {
+		delete(dc.vmList, node.UUID)
+	}
+	nm.nodeNameMap[updated.NodeName] = updated
+	nm.nodeUUIDMap[updated.UUID] = updated
+	nm.AddNodeInfoToICSList(updated.icsServer, updated.dataCenter.Name, updated)
+	nm.nodeInfoLock.Unlock()
+	nm.publishNode(updated.UUID)
+	return true
+}
+
+// removeNodeInfo drops the node from the node maps and the ICS -> DC -> VM
+// tree, unless it has already been replaced by a newer NodeInfo. It returns
+// true if the node was still cached.
+func (nm *NodeManager) removeNodeInfo(node *NodeInfo) bool {
+	nm.nodeInfoLock.Lock()
+	klog.V(4).Info("removeNodeInfo NodeName: ", node.NodeName, ", UUID: ", node.UUID)
+	if nm.nodeNameMap[node.NodeName] == node {
+		delete(nm.nodeNameMap, node.NodeName)
+	}
+	cached := nm.nodeUUIDMap[node.UUID] == node
+	if cached {
+		delete(nm.nodeUUIDMap, node.UUID)
+	}
+	if dc, err := nm.FindDatacenterInfoInICSList(node.icsServer, node.dataCenter.Name); err == nil && dc.vmList[node.UUID] == node {
+		delete(dc.vmList, node.UUID)
+	}
+	metrics.SetNodeCacheSize(len(nm.nodeUUIDMap))
+	nm.nodeInfoLock.Unlock()
+	nm.publishNode(node.UUID)
+	return cached
+}
+
+// pruneNodes drops the cached NodeInfos a reload of the iCenters
+// invalidated: those of the removed and reconnected iCenters, whose session
+// they hold, and those of the datacenters no longer configured. The others
+// are kept. It returns the dropped NodeInfos.
+func (nm *NodeManager) pruneNodes(result cm.ReloadResult) []*NodeInfo {
+	invalid := make(map[string]bool)
+	for _, tenantRef := range append(result.Removed, result.Reconnected...) {
+		invalid[tenantRef] = true
+	}
+	updated := make(map[string]bool)
+	for _, tenantRef := range result.Updated {
+		updated[tenantRef] = true
+	}
+	instances := nm.connectionManager.Instances()
+
+	var pruned []*NodeInfo
+	nm.nodeInfoLock.RLock()
+	for _, node := range nm.nodeUUIDMap {
+		if invalid[node.tenantRef] ||
+			updated[node.tenantRef] && !datacenterConfigured(instances[node.tenantRef], node.dataCenter) {
+			pruned = append(pruned, node)
+		}
+	}
+	nm.nodeInfoLock.RUnlock()
+
+	for _, node := range pruned {
+		nm.removeNodeInfo(node)
+	}
+	return pruned
+}
+
+// datacenterConfigured returns true if the datacenter, by name or ID, is one
+// of the iCenter, which has all of them if none is configured.
+func datacenterConfigured(icsInstance *cm.ICSInstance, datacenter *icslib.Datacenter) bool {
+	if icsInstance == nil {
+		return false
+	}
+	if strings.TrimSpace(icsInstance.Config().Datacenters) == "" {
+		return true
+	}
+	for _, dc := range strings.Split(icsInstance.Config().Datacenters, ",") {
+		dc = strings.TrimSpace(dc)
+		if dc == datacenter.Name || dc == datacenter.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// rediscoverNodes discovers again the registered nodes among the pruned
+// ones, e.g. in the iCenter they were moved to.
+func (nm *NodeManager) rediscoverNodes(pruned []*NodeInfo) {
+	for _, node := range pruned {
+		nm.nodeRegInfoLock.RLock()
+		_, registered := nm.nodeRegUUIDMap[node.UUID]
+		nm.nodeRegInfoLock.RUnlock()
+		if !registered {
+			continue
+		}
+		if err := nm.DiscoverNode(node.UUID, cm.FindVMByUUID); err != nil {
+			klog.Warningf("Failed to discover node %s again after the reload: %v", node.NodeName, err)
+		}
+	}
+}
+
+// cachedNodeInfo returns the cached NodeInfo of the node with the given name
+// or UUID, or nil if the node has not been discovered.
+func (nm *NodeManager) cachedNodeInfo(nodeID string, searchBy cm.FindVM) *NodeInfo {
+	nm.nodeInfoLock.RLock()
+	defer nm.nodeInfoLock.RUnlock()
+
+	if searchBy == cm.FindVMByUUID {
+		return nm.nodeUUIDMap[strings.ToLower(nodeID)]
+	}
+	return nm.nodeNameMap[nodeID]
+}
+
+// lookupNodeInfo returns the cached NodeInfo of the node with the given name
+// or UUID, discovering the node first if it is not cached.
+func (nm *NodeManager) lookupNodeInfo(nodeID string, searchBy cm.FindVM) (*NodeInfo, error) {
+	node := nm.cachedNodeInfo(nodeID, searchBy)
+	metrics.RecordNodeCacheLookup(node != nil)
+	if node != nil {
+		return node, nil
+	}
+
+	if err := nm.DiscoverNode(nodeID, searchBy); err != nil {
+		return nil, err
+	}
+	if node := nm.cachedNodeInfo(nodeID, searchBy); node != nil {
+		return node, nil
+	}
+
+	klog.Errorf("DiscoverNode succeeded, but CACHE missed for node=%s. If this is a Linux VM, hostnames are case sensitive. Make sure they match.", nodeID)
+	return nil, ErrNodeNotFound
 }
 
 func (nm *NodeManager) addNode(uuid string, node *v1.Node) {
@@ -87,6 +235,7 @@ func (nm *NodeManager) addNode(uuid string, node *v1.Node) {
 	klog.V(4).Info("addNode NodeName: ", node.GetName(), ", UID: ", uuid)
 	nm.nodeRegUUIDMap[uuid] = node
 	nm.nodeRegInfoLock.Unlock()
+	nm.publishNode(uuid)
 }
 
 func (nm *NodeManager) removeNode(uuid string, node *v1.Node) {
@@ -94,6 +243,30 @@ func (nm *NodeManager) removeNode(uuid string, node *v1.Node) {
 	klog.V(4).Info("removeNode NodeName: ", node.GetName(), ", UID: ", uuid)
 	delete(nm.nodeRegUUIDMap, uuid)
 	nm.nodeRegInfoLock.Unlock()
+	nm.publishNode(uuid)
+}
+
+// publishNode records the change of the node with the given UUID, if any,
+// for the API watchers. A node is exported once it is both registered and
+// discovered.
+func (nm *NodeManager) publishNode(uuid string) {
+	uuid = strings.ToLower(uuid)
+	nm.nodeEvents.update(uuid, func() *pb.Node {
+		nm.nodeRegInfoLock.RLock()
+		registered := nm.nodeRegUUIDMap[uuid] != nil
+		nm.nodeRegInfoLock.RUnlock()
+		if !registered {
+			return nil
+		}
+
+		nm.nodeInfoLock.RLock()
+		defer nm.nodeInfoLock.RUnlock()
+		node := nm.nodeUUIDMap[uuid]
+		if node == nil {
+			return nil
+		}
+		return newPBNode(node)
+	})
 }
 
 func (nm *NodeManager) shakeOutNodeIDLookup(ctx context.Context, nodeID string, searchBy cm.FindVM) (*cm.VMDiscoveryInfo, error) {
@@ -158,24 +331,57 @@ func returnIPsFromSpecificFamily(family string, ips []string) []string {
 // DiscoverNode finds a node's VM using the specified search value and search
 // type.
 func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
+	_, err := nm.discoverNode(nodeID, searchBy, nil)
+	return err
+}
+
+// discoverNode discovers and caches the node, recording how the address
+// rules treated every NIC of its VM in diag, unless it is nil.
+func (nm *NodeManager) discoverNode(nodeID string, searchBy cm.FindVM, diag *NodeDiagnosis) (*NodeInfo, error) {
 	ctx := context.Background()
 
 	vmDI, err := nm.shakeOutNodeIDLookup(ctx, nodeID, searchBy)
 	if err != nil {
 		klog.Errorf("shakeOutNodeIDLookup failed. Err=%v", err)
-		return err
+		if err == icslib.ErrNoVMFound {
+			metrics.RecordDiscoverNode(searchBy.String(), metrics.ResultNotFound)
+		} else {
+			metrics.RecordDiscoverNode(searchBy.String(), metrics.ResultError)
+		}
+		return nil, err
 	}
+
+	nodeInfo, err := nm.newNodeInfo(vmDI, diag)
+	if err != nil {
+		metrics.RecordDiscoverNode(searchBy.String(), metrics.ResultError)
+		return nil, err
+	}
+	metrics.RecordDiscoverNode(searchBy.String(), metrics.ResultFound)
+	klog.V(2).Infof("Found node %s as vm=%+v in ics=%s and datacenter=%s",
+		nodeID, vmDI.VM, vmDI.IcsServer, vmDI.DataCenter.Name)
+	klog.V(2).Info("Hostname: ", vmDI.VM.Name, " UUID: ", vmDI.VM.UUID)
+
+	nm.addNodeInfo(nodeInfo)
+
+	return nodeInfo, nil
+}
+
+// newNodeInfo builds the NodeInfo, addresses and instance type included, of
+// a discovered VM. The decisions of the address rules on every NIC are
+// recorded in diag, unless it is nil.
+func (nm *NodeManager) newNodeInfo(vmDI *cm.VMDiscoveryInfo, diag *NodeDiagnosis) (*NodeInfo, error) {
+	var err error
 	oVM := vmDI.VM
 
 	tenantRef := vmDI.IcsServer
 	if vmDI.TenantRef != "" {
 		tenantRef = vmDI.TenantRef
 	}
-	icsInstance := nm.connectionManager.ICSInstanceMap[tenantRef]
+	icsInstance := nm.connectionManager.Instances()[tenantRef]
 
 	ipFamily := []string{icfg.DefaultIPFamily}
 	if icsInstance != nil {
-		ipFamily = icsInstance.Cfg.IPFamilyPriority
+		ipFamily = icsInstance.Config().IPFamilyPriority
 	} else {
 		klog.Warningf("Unable to find icsInstance for %s. Defaulting to ipv4.", tenantRef)
 	}
@@ -189,13 +395,13 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 		if nm.cpiCfg.Nodes.InternalNetworkSubnetCIDR != "" {
 			_, internalNetworkSubnet, err = net.ParseCIDR(nm.cpiCfg.Nodes.InternalNetworkSubnetCIDR)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 		if nm.cpiCfg.Nodes.ExternalNetworkSubnetCIDR != "" {
 			_, externalNetworkSubnet, err = net.ParseCIDR(nm.cpiCfg.Nodes.ExternalNetworkSubnetCIDR)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 		internalVMNetworkName = nm.cpiCfg.Nodes.InternalVMNetworkName
@@ -227,24 +433,35 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 			(externalVMNetworkName != "" && !strings.EqualFold(externalVMNetworkName, v.Name)) {
 			klog.V(4).Infof("Skipping device because vNIC Network=%s doesn't match internal=%s or external=%s network names",
 				v.Name, internalVMNetworkName, externalVMNetworkName)
+			diag.recordNIC(v, fmt.Sprintf("skipped, network matches neither internal-vm-network-name %q nor external-vm-network-name %q",
+				internalVMNetworkName, externalVMNetworkName))
 			continue
 		}
 
+		var decisions []string
+
 		// Only return a single IP address based on the preference of IPFamily
 		// Must break out of loop in the event of ipv6,ipv4 where the NIC does
 		// contain a valid IPv6 and IPV4 address
 		for _, family := range ipFamily {
 			ips := returnIPsFromSpecificFamily(family, []string{v.IP})
+			if len(ips) == 0 {
+				decisions = append(decisions, fmt.Sprintf("no usable %s address", family))
+			}
 
 			if addressMatchingEnabled {
 				for _, ip := range ips {
 					parsedIP := net.ParseIP(ip)
 					if parsedIP == nil {
-						return fmt.Errorf("can't parse IP: %s", ip)
+						return nil, fmt.Errorf("can't parse IP: %s", ip)
 					}
 
+					if !internalNetworkSubnet.Contains(parsedIP) && !externalNetworkSubnet.Contains(parsedIP) {
+						decisions = append(decisions, fmt.Sprintf("%s skipped, in neither internal-network-subnet-cidr nor external-network-subnet-cidr", ip))
+					}
 					if internalNetworkSubnet != nil && internalNetworkSubnet.Contains(parsedIP) {
 						klog.V(2).Infof("Adding Internal IP by AddressMatching: %s", ip)
+						decisions = append(decisions, fmt.Sprintf("internal IP %s by internal-network-subnet-cidr", ip))
 						v1helper.AddToNodeAddresses(&addrs,
 							v1.NodeAddress{
 								Type:    v1.NodeInternalIP,
@@ -255,6 +472,7 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 
 					if externalNetworkSubnet != nil && externalNetworkSubnet.Contains(parsedIP) {
 						klog.V(2).Infof("Adding External IP by AddressMatching: %s", ip)
+						decisions = append(decisions, fmt.Sprintf("external IP %s by external-network-subnet-cidr", ip))
 						v1helper.AddToNodeAddresses(&addrs,
 							v1.NodeAddress{
 								Type:    v1.NodeExternalIP,
@@ -266,6 +484,7 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 			} else if internalVMNetworkName != "" && strings.EqualFold(internalVMNetworkName, v.Name) {
 				for _, ip := range ips {
 					klog.V(2).Infof("Adding Internal IP by NetworkName: %s", ip)
+					decisions = append(decisions, fmt.Sprintf("internal IP %s by internal-vm-network-name", ip))
 					v1helper.AddToNodeAddresses(&addrs,
 						v1.NodeAddress{
 							Type:    v1.NodeInternalIP,
@@ -278,6 +497,7 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 			} else if externalVMNetworkName != "" && strings.EqualFold(externalVMNetworkName, v.Name) {
 				for _, ip := range ips {
 					klog.V(2).Infof("Adding External IP by NetworkName: %s", ip)
+					decisions = append(decisions, fmt.Sprintf("external IP %s by external-vm-network-name", ip))
 					v1helper.AddToNodeAddresses(&addrs,
 						v1.NodeAddress{
 							Type:    v1.NodeExternalIP,
@@ -290,6 +510,7 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 			} else {
 				for _, ip := range ips {
 					klog.V(2).Infof("Adding IP: %s", ip)
+					decisions = append(decisions, fmt.Sprintf("internal and external IP %s, matched by no address rule", ip))
 					v1helper.AddToNodeAddresses(&addrs,
 						v1.NodeAddress{
 							Type:    v1.NodeExternalIP,
@@ -308,14 +529,12 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 				break
 			}
 		}
+		diag.recordNIC(v, strings.Join(decisions, "; "))
 	}
 
 	if !found {
 		klog.Warningf("Unable to find a suitable IP address. ipFamily: %s", ipFamily)
 	}
-	klog.V(2).Infof("Found node %s as vm=%+v in ics=%s and datacenter=%s",
-		nodeID, vmDI.VM, vmDI.IcsServer, vmDI.DataCenter.Name)
-	klog.V(2).Info("Hostname: ", oVM.Name, " UUID: ", oVM.UUID)
 
 	os := strings.Fields(oVM.GuestosLabel)[0]
 
@@ -328,9 +547,8 @@ func (nm *NodeManager) DiscoverNode(nodeID string, searchBy cm.FindVM) error {
 
 	nodeInfo := &NodeInfo{tenantRef: tenantRef, dataCenter: vmDI.DataCenter, vm: vmDI.VM, icsServer: vmDI.IcsServer,
 		UUID: vmDI.UUID, NodeName: vmDI.NodeName, NodeType: instanceType, NodeAddresses: addrs}
-	nm.addNodeInfo(nodeInfo)
 
-	return nil
+	return nodeInfo, nil
 }
 
 // GetNode gets the NodeInfo by UUID
@@ -403,26 +621,38 @@ func (nm *NodeManager) datacenterToNodeList(vmList map[string]*NodeInfo, nodeLis
 			continue
 		}
 
-		pbNode := &pb.Node{
-			Icenter:    node.icsServer,
-			Datacenter: node.dataCenter.Name,
-			Name:       node.NodeName,
-			Dnsnames:   make([]string, 0),
-			Addresses:  make([]string, 0),
-			Uuid:       node.UUID,
-		}
-		for _, address := range node.NodeAddresses {
-			switch address.Type {
-			case v1.NodeExternalIP:
-				pbNode.Addresses = append(pbNode.Addresses, address.Address)
-			case v1.NodeHostName:
-				pbNode.Dnsnames = append(pbNode.Dnsnames, address.Address)
-			default:
-				klog.Warning("Unknown/unsupported address type:", address.Type)
-			}
+		*nodeList = append(*nodeList, newPBNode(node))
+	}
+}
+
+// newPBNode transforms the NodeInfo to a *pb.Node
+func newPBNode(node *NodeInfo) *pb.Node {
+	pbNode := &pb.Node{
+		Icenter:    node.icsServer,
+		Datacenter: node.dataCenter.Name,
+		Name:       node.NodeName,
+		Dnsnames:   make([]string, 0),
+		Addresses:  make([]string, 0),
+		Uuid:       node.UUID,
+	}
+	for _, address := range node.NodeAddresses {
+		switch address.Type {
+		case v1.NodeExternalIP:
+			pbNode.Addresses = append(pbNode.Addresses, address.Address)
+		case v1.NodeHostName:
+			pbNode.Dnsnames = append(pbNode.Dnsnames, address.Address)
+		default:
+			klog.Warning("Unknown/unsupported address type:", address.Type)
 		}
-		*nodeList = append(*nodeList, pbNode)
 	}
+	return pbNode
+}
+
+// WatchNodes sends the changes of the exported nodes after resumeToken, or
+// an ADDED event for every exported node if resumeToken is empty, until ctx
+// is done or send fails.
+func (nm *NodeManager) WatchNodes(ctx context.Context, resumeToken string, send func(*pb.NodeEvent) error) error {
+	return nm.nodeEvents.watch(ctx, resumeToken, send)
 }
 
 // AddNodeInfoToICSList creates a relational mapping from ICS -> DC -> VM/Node
@@ -27,19 +27,19 @@ import (
 	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
-	"github.com/inspur-ics/ics-go-sdk/client/types"
 )
 
 func TestRegUnregNode(t *testing.T) {
-	cfg, ok := configFromEnvOrSim(true)
+	cfg, model, ok := configFromSim(true)
 	defer ok()
 
 	connMgr := cm.NewConnectionManager(cfg, nil, nil)
 	defer connMgr.Logout()
 
 	nm := newNodeManager(nil, connMgr)
-	name := "vm-001"
-	UUID := "c7f4b777-6ffc-4473-85cc-382e3e719a85"
+	vm := model.VMs()[0]
+	name := vm.Name
+	UUID := vm.UUID
 
 	node := &v1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -78,16 +78,14 @@ func TestRegUnregNode(t *testing.T) {
 }
 
 func TestDiscoverNodeByName(t *testing.T) {
-	cfg, ok := configFromEnvOrSim(true)
+	cfg, model, ok := configFromSim(true)
 	defer ok()
 
 	connMgr := cm.NewConnectionManager(cfg, nil, nil)
 	defer connMgr.Logout()
 
 	nm := newNodeManager(nil, connMgr)
-	//FIXME TODO.WANGYONGCHAO
-	vm := types.VirtualMachine {}
-	vm.HostName = strings.ToLower(vm.Name) // simulator.SearchIndex.FindByDnsName matches against the guest.hostName property
+	vm := model.VMs()[len(model.VMs())-1]
 	name := vm.Name
 
 	err := connMgr.Connect(context.Background(), connMgr.ICSInstanceMap[cfg.Global.ICenterIP])
@@ -106,18 +104,41 @@ func TestDiscoverNodeByName(t *testing.T) {
 	if len(nm.nodeUUIDMap) != 1 {
 		t.Errorf("Failed: nodeUUIDMap should be a length of  1")
 	}
+
+	nodeInfo, found := nm.nodeNameMap[name]
+	if !found {
+		t.Fatalf("Failed: node %s not found in nodeNameMap", name)
+	}
+	if nodeInfo.dataCenter.Name != "DC1" {
+		t.Errorf("Failed: node %s should be in DC1, found in %s", name, nodeInfo.dataCenter.Name)
+	}
+	expectedType := "ics-vm.cpu-2.mem-4gb.os-CentOS"
+	if nodeInfo.NodeType != expectedType {
+		t.Errorf("Failed: instance type mismatch %s != %s", nodeInfo.NodeType, expectedType)
+	}
+	expectedIP := vm.Nics[0].IP
+	for _, addrType := range []v1.NodeAddressType{v1.NodeInternalIP, v1.NodeExternalIP} {
+		found := false
+		for _, addr := range nodeInfo.NodeAddresses {
+			if addr.Type == addrType && addr.Address == expectedIP {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Failed: %s %s not found in %v", addrType, expectedIP, nodeInfo.NodeAddresses)
+		}
+	}
 }
 
 func TestExport(t *testing.T) {
-	cfg, ok := configFromEnvOrSim(true)
+	cfg, model, ok := configFromSim(true)
 	defer ok()
 
 	connMgr := cm.NewConnectionManager(cfg, nil, nil)
 	defer connMgr.Logout()
 
 	nm := newNodeManager(nil, connMgr)
-	//FIXME TODO.WANGYONGCHAO
-	vm := types.VirtualMachine {}
+	vm := model.VMs()[0]
 	name := vm.Name
 	UUID := vm.UUID
 
@@ -6,16 +6,17 @@ package cloudproviderics
 import (
 	context "context"
 	fmt "fmt"
-
 	proto "github.com/golang/protobuf/proto"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
+var _ = math.Inf
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the proto package it is being compiled against.
@@ -23,6 +24,34 @@ var _ = fmt.Errorf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type NodeEvent_Type int32
+
+const (
+	NodeEvent_ADDED    NodeEvent_Type = 0
+	NodeEvent_MODIFIED NodeEvent_Type = 1
+	NodeEvent_DELETED  NodeEvent_Type = 2
+)
+
+var NodeEvent_Type_name = map[int32]string{
+	0: "ADDED",
+	1: "MODIFIED",
+	2: "DELETED",
+}
+
+var NodeEvent_Type_value = map[string]int32{
+	"ADDED":    0,
+	"MODIFIED": 1,
+	"DELETED":  2,
+}
+
+func (x NodeEvent_Type) String() string {
+	return proto.EnumName(NodeEvent_Type_name, int32(x))
+}
+
+func (NodeEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{8, 0}
+}
+
 type Node struct {
 	Icenter              string   `protobuf:"bytes,1,opt,name=icenter,proto3" json:"icenter,omitempty"`
 	Datacenter           string   `protobuf:"bytes,2,opt,name=datacenter,proto3" json:"datacenter,omitempty"`
@@ -39,7 +68,7 @@ func (m *Node) Reset()         { *m = Node{} }
 func (m *Node) String() string { return proto.CompactTextString(m) }
 func (*Node) ProtoMessage()    {}
 func (*Node) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{0}
+	return fileDescriptor_630e23fe7cf01247, []int{0}
 }
 
 func (m *Node) XXX_Unmarshal(b []byte) error {
@@ -113,7 +142,7 @@ func (m *GetNodeRequest) Reset()         { *m = GetNodeRequest{} }
 func (m *GetNodeRequest) String() string { return proto.CompactTextString(m) }
 func (*GetNodeRequest) ProtoMessage()    {}
 func (*GetNodeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{1}
+	return fileDescriptor_630e23fe7cf01247, []int{1}
 }
 
 func (m *GetNodeRequest) XXX_Unmarshal(b []byte) error {
@@ -153,7 +182,7 @@ func (m *GetNodeReply) Reset()         { *m = GetNodeReply{} }
 func (m *GetNodeReply) String() string { return proto.CompactTextString(m) }
 func (*GetNodeReply) ProtoMessage()    {}
 func (*GetNodeReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{2}
+	return fileDescriptor_630e23fe7cf01247, []int{2}
 }
 
 func (m *GetNodeReply) XXX_Unmarshal(b []byte) error {
@@ -200,7 +229,7 @@ func (m *ListNodesRequest) Reset()         { *m = ListNodesRequest{} }
 func (m *ListNodesRequest) String() string { return proto.CompactTextString(m) }
 func (*ListNodesRequest) ProtoMessage()    {}
 func (*ListNodesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{3}
+	return fileDescriptor_630e23fe7cf01247, []int{3}
 }
 
 func (m *ListNodesRequest) XXX_Unmarshal(b []byte) error {
@@ -247,7 +276,7 @@ func (m *ListNodesReply) Reset()         { *m = ListNodesReply{} }
 func (m *ListNodesReply) String() string { return proto.CompactTextString(m) }
 func (*ListNodesReply) ProtoMessage()    {}
 func (*ListNodesReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{4}
+	return fileDescriptor_630e23fe7cf01247, []int{4}
 }
 
 func (m *ListNodesReply) XXX_Unmarshal(b []byte) error {
@@ -292,7 +321,7 @@ func (m *VersionRequest) Reset()         { *m = VersionRequest{} }
 func (m *VersionRequest) String() string { return proto.CompactTextString(m) }
 func (*VersionRequest) ProtoMessage()    {}
 func (*VersionRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{5}
+	return fileDescriptor_630e23fe7cf01247, []int{5}
 }
 
 func (m *VersionRequest) XXX_Unmarshal(b []byte) error {
@@ -324,7 +353,7 @@ func (m *VersionReply) Reset()         { *m = VersionReply{} }
 func (m *VersionReply) String() string { return proto.CompactTextString(m) }
 func (*VersionReply) ProtoMessage()    {}
 func (*VersionReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_b637d4c33cef7514, []int{6}
+	return fileDescriptor_630e23fe7cf01247, []int{6}
 }
 
 func (m *VersionReply) XXX_Unmarshal(b []byte) error {
@@ -352,7 +381,442 @@ func (m *VersionReply) GetVersion() string {
 	return ""
 }
 
+type WatchNodesRequest struct {
+	Icenter    string `protobuf:"bytes,1,opt,name=icenter,proto3" json:"icenter,omitempty"`
+	Datacenter string `protobuf:"bytes,2,opt,name=datacenter,proto3" json:"datacenter,omitempty"`
+	// Resume token of the last event received. Empty to start with an ADDED
+	// event for every current node.
+	ResumeToken          string   `protobuf:"bytes,3,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchNodesRequest) Reset()         { *m = WatchNodesRequest{} }
+func (m *WatchNodesRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchNodesRequest) ProtoMessage()    {}
+func (*WatchNodesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{7}
+}
+
+func (m *WatchNodesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchNodesRequest.Unmarshal(m, b)
+}
+func (m *WatchNodesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchNodesRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchNodesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchNodesRequest.Merge(m, src)
+}
+func (m *WatchNodesRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchNodesRequest.Size(m)
+}
+func (m *WatchNodesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchNodesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchNodesRequest proto.InternalMessageInfo
+
+func (m *WatchNodesRequest) GetIcenter() string {
+	if m != nil {
+		return m.Icenter
+	}
+	return ""
+}
+
+func (m *WatchNodesRequest) GetDatacenter() string {
+	if m != nil {
+		return m.Datacenter
+	}
+	return ""
+}
+
+func (m *WatchNodesRequest) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+type NodeEvent struct {
+	Type NodeEvent_Type `protobuf:"varint,1,opt,name=type,proto3,enum=cloudproviderics.NodeEvent_Type" json:"type,omitempty"`
+	// The node, or its last known state for DELETED.
+	Node                 *Node    `protobuf:"bytes,2,opt,name=node,proto3" json:"node,omitempty"`
+	ResumeToken          string   `protobuf:"bytes,3,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *NodeEvent) Reset()         { *m = NodeEvent{} }
+func (m *NodeEvent) String() string { return proto.CompactTextString(m) }
+func (*NodeEvent) ProtoMessage()    {}
+func (*NodeEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{8}
+}
+
+func (m *NodeEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_NodeEvent.Unmarshal(m, b)
+}
+func (m *NodeEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_NodeEvent.Marshal(b, m, deterministic)
+}
+func (m *NodeEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NodeEvent.Merge(m, src)
+}
+func (m *NodeEvent) XXX_Size() int {
+	return xxx_messageInfo_NodeEvent.Size(m)
+}
+func (m *NodeEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_NodeEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NodeEvent proto.InternalMessageInfo
+
+func (m *NodeEvent) GetType() NodeEvent_Type {
+	if m != nil {
+		return m.Type
+	}
+	return NodeEvent_ADDED
+}
+
+func (m *NodeEvent) GetNode() *Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *NodeEvent) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+type Zone struct {
+	Zone                 string   `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region               string   `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	Icenter              string   `protobuf:"bytes,3,opt,name=icenter,proto3" json:"icenter,omitempty"`
+	Datacenter           string   `protobuf:"bytes,4,opt,name=datacenter,proto3" json:"datacenter,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Zone) Reset()         { *m = Zone{} }
+func (m *Zone) String() string { return proto.CompactTextString(m) }
+func (*Zone) ProtoMessage()    {}
+func (*Zone) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{9}
+}
+
+func (m *Zone) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Zone.Unmarshal(m, b)
+}
+func (m *Zone) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Zone.Marshal(b, m, deterministic)
+}
+func (m *Zone) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Zone.Merge(m, src)
+}
+func (m *Zone) XXX_Size() int {
+	return xxx_messageInfo_Zone.Size(m)
+}
+func (m *Zone) XXX_DiscardUnknown() {
+	xxx_messageInfo_Zone.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Zone proto.InternalMessageInfo
+
+func (m *Zone) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func (m *Zone) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+func (m *Zone) GetIcenter() string {
+	if m != nil {
+		return m.Icenter
+	}
+	return ""
+}
+
+func (m *Zone) GetDatacenter() string {
+	if m != nil {
+		return m.Datacenter
+	}
+	return ""
+}
+
+type GetZoneRequest struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetZoneRequest) Reset()         { *m = GetZoneRequest{} }
+func (m *GetZoneRequest) String() string { return proto.CompactTextString(m) }
+func (*GetZoneRequest) ProtoMessage()    {}
+func (*GetZoneRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{10}
+}
+
+func (m *GetZoneRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetZoneRequest.Unmarshal(m, b)
+}
+func (m *GetZoneRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetZoneRequest.Marshal(b, m, deterministic)
+}
+func (m *GetZoneRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetZoneRequest.Merge(m, src)
+}
+func (m *GetZoneRequest) XXX_Size() int {
+	return xxx_messageInfo_GetZoneRequest.Size(m)
+}
+func (m *GetZoneRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetZoneRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetZoneRequest proto.InternalMessageInfo
+
+func (m *GetZoneRequest) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+type GetZoneReply struct {
+	Zone                 *Zone    `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetZoneReply) Reset()         { *m = GetZoneReply{} }
+func (m *GetZoneReply) String() string { return proto.CompactTextString(m) }
+func (*GetZoneReply) ProtoMessage()    {}
+func (*GetZoneReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{11}
+}
+
+func (m *GetZoneReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetZoneReply.Unmarshal(m, b)
+}
+func (m *GetZoneReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetZoneReply.Marshal(b, m, deterministic)
+}
+func (m *GetZoneReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetZoneReply.Merge(m, src)
+}
+func (m *GetZoneReply) XXX_Size() int {
+	return xxx_messageInfo_GetZoneReply.Size(m)
+}
+func (m *GetZoneReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetZoneReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetZoneReply proto.InternalMessageInfo
+
+func (m *GetZoneReply) GetZone() *Zone {
+	if m != nil {
+		return m.Zone
+	}
+	return nil
+}
+
+func (m *GetZoneReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ListZonesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListZonesRequest) Reset()         { *m = ListZonesRequest{} }
+func (m *ListZonesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListZonesRequest) ProtoMessage()    {}
+func (*ListZonesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{12}
+}
+
+func (m *ListZonesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListZonesRequest.Unmarshal(m, b)
+}
+func (m *ListZonesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListZonesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListZonesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListZonesRequest.Merge(m, src)
+}
+func (m *ListZonesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListZonesRequest.Size(m)
+}
+func (m *ListZonesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListZonesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListZonesRequest proto.InternalMessageInfo
+
+type ListZonesReply struct {
+	Zones                []*Zone  `protobuf:"bytes,1,rep,name=zones,proto3" json:"zones,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListZonesReply) Reset()         { *m = ListZonesReply{} }
+func (m *ListZonesReply) String() string { return proto.CompactTextString(m) }
+func (*ListZonesReply) ProtoMessage()    {}
+func (*ListZonesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{13}
+}
+
+func (m *ListZonesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListZonesReply.Unmarshal(m, b)
+}
+func (m *ListZonesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListZonesReply.Marshal(b, m, deterministic)
+}
+func (m *ListZonesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListZonesReply.Merge(m, src)
+}
+func (m *ListZonesReply) XXX_Size() int {
+	return xxx_messageInfo_ListZonesReply.Size(m)
+}
+func (m *ListZonesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListZonesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListZonesReply proto.InternalMessageInfo
+
+func (m *ListZonesReply) GetZones() []*Zone {
+	if m != nil {
+		return m.Zones
+	}
+	return nil
+}
+
+func (m *ListZonesReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ListNodesByZoneRequest struct {
+	Zone                 string   `protobuf:"bytes,1,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region               string   `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListNodesByZoneRequest) Reset()         { *m = ListNodesByZoneRequest{} }
+func (m *ListNodesByZoneRequest) String() string { return proto.CompactTextString(m) }
+func (*ListNodesByZoneRequest) ProtoMessage()    {}
+func (*ListNodesByZoneRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{14}
+}
+
+func (m *ListNodesByZoneRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodesByZoneRequest.Unmarshal(m, b)
+}
+func (m *ListNodesByZoneRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodesByZoneRequest.Marshal(b, m, deterministic)
+}
+func (m *ListNodesByZoneRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodesByZoneRequest.Merge(m, src)
+}
+func (m *ListNodesByZoneRequest) XXX_Size() int {
+	return xxx_messageInfo_ListNodesByZoneRequest.Size(m)
+}
+func (m *ListNodesByZoneRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodesByZoneRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodesByZoneRequest proto.InternalMessageInfo
+
+func (m *ListNodesByZoneRequest) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func (m *ListNodesByZoneRequest) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+type ListNodesByZoneReply struct {
+	Nodes                []*Node  `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListNodesByZoneReply) Reset()         { *m = ListNodesByZoneReply{} }
+func (m *ListNodesByZoneReply) String() string { return proto.CompactTextString(m) }
+func (*ListNodesByZoneReply) ProtoMessage()    {}
+func (*ListNodesByZoneReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_630e23fe7cf01247, []int{15}
+}
+
+func (m *ListNodesByZoneReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodesByZoneReply.Unmarshal(m, b)
+}
+func (m *ListNodesByZoneReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodesByZoneReply.Marshal(b, m, deterministic)
+}
+func (m *ListNodesByZoneReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodesByZoneReply.Merge(m, src)
+}
+func (m *ListNodesByZoneReply) XXX_Size() int {
+	return xxx_messageInfo_ListNodesByZoneReply.Size(m)
+}
+func (m *ListNodesByZoneReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodesByZoneReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodesByZoneReply proto.InternalMessageInfo
+
+func (m *ListNodesByZoneReply) GetNodes() []*Node {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+func (m *ListNodesByZoneReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 func init() {
+	proto.RegisterEnum("cloudproviderics.NodeEvent_Type", NodeEvent_Type_name, NodeEvent_Type_value)
 	proto.RegisterType((*Node)(nil), "cloudproviderics.Node")
 	proto.RegisterType((*GetNodeRequest)(nil), "cloudproviderics.GetNodeRequest")
 	proto.RegisterType((*GetNodeReply)(nil), "cloudproviderics.GetNodeReply")
@@ -360,36 +824,62 @@ func init() {
 	proto.RegisterType((*ListNodesReply)(nil), "cloudproviderics.ListNodesReply")
 	proto.RegisterType((*VersionRequest)(nil), "cloudproviderics.VersionRequest")
 	proto.RegisterType((*VersionReply)(nil), "cloudproviderics.VersionReply")
-}
-
-func init() { proto.RegisterFile("cloudproviderics.proto", fileDescriptor_b637d4c33cef7514) }
-
-var fileDescriptor_b637d4c33cef7514 = []byte{
-	// 375 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0x4d, 0x6b, 0xea, 0x40,
-	0x14, 0x35, 0x1a, 0xf5, 0xe5, 0x3e, 0x11, 0x19, 0x5c, 0x0c, 0xe1, 0xf1, 0x08, 0x83, 0x3c, 0x5c,
-	0x85, 0x87, 0xdd, 0x75, 0x69, 0x17, 0x6e, 0xa4, 0x88, 0xb4, 0x22, 0x74, 0x95, 0x3a, 0x17, 0x1a,
-	0xd0, 0x4c, 0x3a, 0x93, 0xa4, 0xf8, 0x77, 0x4a, 0x7f, 0x68, 0x99, 0x99, 0x98, 0xc6, 0x12, 0x11,
-	0xba, 0xbb, 0x1f, 0xe7, 0x9e, 0x73, 0xe6, 0x84, 0x80, 0xbf, 0xdb, 0x8b, 0x9c, 0xa7, 0x52, 0x14,
-	0x31, 0x47, 0x59, 0xa8, 0xf4, 0x05, 0x25, 0x86, 0xa9, 0x14, 0x99, 0x20, 0xe3, 0xa6, 0x1d, 0x7b,
-	0x77, 0xc0, 0xbd, 0x17, 0x1c, 0x09, 0x85, 0x7e, 0xb1, 0xc3, 0x24, 0x43, 0x49, 0x9d, 0xc0, 0x99,
-	0x7a, 0xeb, 0x53, 0x4b, 0xfe, 0x02, 0xf0, 0x28, 0x8b, 0xca, 0x65, 0xdb, 0x2c, 0x6b, 0x13, 0x42,
-	0xc0, 0x4d, 0xa2, 0x03, 0xd2, 0x8e, 0xd9, 0x98, 0x9a, 0xf8, 0xf0, 0x8b, 0x27, 0x4a, 0x97, 0x8a,
-	0xba, 0x41, 0x67, 0xea, 0xad, 0xab, 0x9e, 0xfc, 0x01, 0x2f, 0xe2, 0x5c, 0xa2, 0x52, 0xa8, 0x68,
-	0xd7, 0x2c, 0xbf, 0x06, 0x9a, 0x2d, 0xcf, 0x63, 0x4e, 0x7b, 0x96, 0x4d, 0xd7, 0x6c, 0x02, 0xc3,
-	0x05, 0x66, 0xda, 0xe6, 0x1a, 0x5f, 0x73, 0x54, 0x59, 0x85, 0x72, 0x6a, 0xa8, 0x07, 0x18, 0x54,
-	0xa8, 0x74, 0x7f, 0x24, 0x21, 0xb8, 0x89, 0xe0, 0x68, 0x30, 0xbf, 0x67, 0x7e, 0xd8, 0x98, 0x8d,
-	0x81, 0x1b, 0x1c, 0x19, 0x43, 0x17, 0xa5, 0x14, 0xa7, 0x27, 0xda, 0x86, 0x2d, 0x61, 0xb4, 0x8c,
-	0x95, 0xa1, 0x55, 0x27, 0xf5, 0x1f, 0x67, 0xc5, 0xb6, 0x30, 0xac, 0xb1, 0x69, 0x97, 0xff, 0xa1,
-	0xab, 0xd5, 0x15, 0x75, 0x82, 0xce, 0x15, 0x9b, 0x16, 0x78, 0xc1, 0xe7, 0x08, 0x86, 0x1b, 0x94,
-	0x2a, 0x16, 0x49, 0xe9, 0x92, 0x4d, 0x61, 0x50, 0x4d, 0xb4, 0x92, 0x76, 0x6d, 0xfb, 0xca, 0xb5,
-	0x6d, 0x67, 0x1f, 0x6d, 0x18, 0xdf, 0x69, 0xd9, 0x55, 0x29, 0xbb, 0xb1, 0xb2, 0xe4, 0x11, 0xfa,
-	0x65, 0xa4, 0x64, 0xd2, 0x6c, 0xec, 0xfc, 0xbb, 0xf8, 0xec, 0x0a, 0x2a, 0xdd, 0x1f, 0x59, 0x8b,
-	0x3c, 0x81, 0x57, 0xa5, 0x40, 0xfe, 0x35, 0x9f, 0x7c, 0x0f, 0xdd, 0x9f, 0x5c, 0xc5, 0x59, 0xf2,
-	0x2d, 0xc0, 0x02, 0xb3, 0xf2, 0xe5, 0x97, 0x6c, 0x9f, 0x47, 0x75, 0xc9, 0x76, 0x3d, 0x3e, 0xd6,
-	0x9a, 0xdf, 0x42, 0xb0, 0x13, 0x87, 0xb0, 0x38, 0xbc, 0x45, 0x12, 0xcf, 0x2f, 0xc2, 0xf2, 0x64,
-	0xde, 0x98, 0xe3, 0xca, 0x79, 0xee, 0x99, 0x9f, 0xf0, 0xe6, 0x33, 0x00, 0x00, 0xff, 0xff, 0x4d,
-	0x6a, 0x77, 0xb3, 0xa2, 0x03, 0x00, 0x00,
+	proto.RegisterType((*WatchNodesRequest)(nil), "cloudproviderics.WatchNodesRequest")
+	proto.RegisterType((*NodeEvent)(nil), "cloudproviderics.NodeEvent")
+	proto.RegisterType((*Zone)(nil), "cloudproviderics.Zone")
+	proto.RegisterType((*GetZoneRequest)(nil), "cloudproviderics.GetZoneRequest")
+	proto.RegisterType((*GetZoneReply)(nil), "cloudproviderics.GetZoneReply")
+	proto.RegisterType((*ListZonesRequest)(nil), "cloudproviderics.ListZonesRequest")
+	proto.RegisterType((*ListZonesReply)(nil), "cloudproviderics.ListZonesReply")
+	proto.RegisterType((*ListNodesByZoneRequest)(nil), "cloudproviderics.ListNodesByZoneRequest")
+	proto.RegisterType((*ListNodesByZoneReply)(nil), "cloudproviderics.ListNodesByZoneReply")
+}
+
+func init() { proto.RegisterFile("cloudproviderics.proto", fileDescriptor_630e23fe7cf01247) }
+
+var fileDescriptor_630e23fe7cf01247 = []byte{
+	// 648 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0x7f, 0x6b, 0xd3, 0x40,
+	0x18, 0x5e, 0xd6, 0x74, 0x5d, 0xde, 0x95, 0x1a, 0x8f, 0x51, 0x42, 0x1c, 0x25, 0x9e, 0x22, 0x45,
+	0x46, 0x91, 0xea, 0x17, 0x70, 0x4b, 0x1c, 0x83, 0x4d, 0x4b, 0x57, 0x14, 0xf6, 0x8f, 0xd4, 0xe4,
+	0xd0, 0x60, 0x97, 0x8b, 0xb9, 0xa4, 0x50, 0x3f, 0x8e, 0x1f, 0xc4, 0xaf, 0xe3, 0xd7, 0x90, 0xfb,
+	0x91, 0x2c, 0x6d, 0xd2, 0x58, 0x64, 0xff, 0xdd, 0xfb, 0xde, 0x93, 0xf7, 0x7d, 0xee, 0xb9, 0xe7,
+	0xde, 0x40, 0xdf, 0x5f, 0xd0, 0x2c, 0x88, 0x13, 0xba, 0x0c, 0x03, 0x92, 0x84, 0x3e, 0x1b, 0xc5,
+	0x09, 0x4d, 0x29, 0x32, 0x37, 0xf3, 0xf8, 0x97, 0x06, 0xfa, 0x7b, 0x1a, 0x10, 0x64, 0x41, 0x27,
+	0xf4, 0x49, 0x94, 0x92, 0xc4, 0xd2, 0x1c, 0x6d, 0x68, 0x4c, 0xf3, 0x10, 0x0d, 0x00, 0x82, 0x79,
+	0x3a, 0x57, 0x9b, 0xfb, 0x62, 0xb3, 0x94, 0x41, 0x08, 0xf4, 0x68, 0x7e, 0x47, 0xac, 0x96, 0xd8,
+	0x11, 0x6b, 0x64, 0xc3, 0x61, 0x10, 0x31, 0xbe, 0x64, 0x96, 0xee, 0xb4, 0x86, 0xc6, 0xb4, 0x88,
+	0xd1, 0x09, 0x18, 0xf3, 0x20, 0x48, 0x08, 0x63, 0x84, 0x59, 0x6d, 0xb1, 0x79, 0x9f, 0xe0, 0xd5,
+	0xb2, 0x2c, 0x0c, 0xac, 0x03, 0x59, 0x8d, 0xaf, 0xf1, 0x73, 0xe8, 0x5d, 0x90, 0x94, 0xd3, 0x9c,
+	0x92, 0x1f, 0x19, 0x61, 0x69, 0x81, 0xd2, 0x4a, 0xa8, 0x09, 0x74, 0x0b, 0x54, 0xbc, 0x58, 0xa1,
+	0x97, 0xa0, 0x47, 0x34, 0x20, 0x02, 0x73, 0x34, 0xee, 0x8f, 0x2a, 0x9a, 0x08, 0xa8, 0xc0, 0xa0,
+	0x63, 0x68, 0x93, 0x24, 0xa1, 0xf9, 0xf1, 0x64, 0x80, 0xaf, 0xc0, 0xbc, 0x0a, 0x99, 0x28, 0xc9,
+	0xf2, 0xce, 0xff, 0xad, 0x13, 0x9e, 0x41, 0xaf, 0x54, 0x8d, 0x33, 0x3c, 0x85, 0x36, 0xef, 0xce,
+	0x2c, 0xcd, 0x69, 0x35, 0x50, 0x94, 0xa0, 0x2d, 0x1c, 0x4d, 0xe8, 0x7d, 0x24, 0x09, 0x0b, 0x69,
+	0xa4, 0x18, 0xe2, 0x21, 0x74, 0x8b, 0x0c, 0xef, 0x62, 0x41, 0x67, 0x29, 0xe3, 0x9c, 0xb1, 0x0a,
+	0x71, 0x0c, 0x8f, 0x3f, 0xcd, 0x53, 0xff, 0xdb, 0xc3, 0x1c, 0x10, 0x3d, 0x85, 0x6e, 0x42, 0x58,
+	0x76, 0x47, 0x3e, 0xa7, 0xf4, 0x3b, 0x89, 0x94, 0x21, 0x8e, 0x64, 0x6e, 0xc6, 0x53, 0xf8, 0xb7,
+	0x06, 0x06, 0xef, 0xe6, 0x2d, 0x49, 0x94, 0xa2, 0x37, 0xa0, 0xa7, 0xab, 0x58, 0xde, 0x50, 0x6f,
+	0xec, 0xd4, 0x1f, 0x5f, 0x40, 0x47, 0xb3, 0x55, 0x4c, 0xa6, 0x02, 0x5d, 0xdc, 0xeb, 0xfe, 0x0e,
+	0xf7, 0xba, 0x03, 0xa5, 0x53, 0xd0, 0x79, 0x71, 0x64, 0x40, 0xfb, 0xad, 0xeb, 0x7a, 0xae, 0xb9,
+	0x87, 0xba, 0x70, 0x78, 0xfd, 0xc1, 0xbd, 0x7c, 0x77, 0xe9, 0xb9, 0xa6, 0x86, 0x8e, 0xa0, 0xe3,
+	0x7a, 0x57, 0xde, 0xcc, 0x73, 0xcd, 0x7d, 0xbc, 0x00, 0xfd, 0x96, 0x46, 0x84, 0x1b, 0xf0, 0x27,
+	0x8d, 0x48, 0x6e, 0x40, 0xbe, 0x46, 0x7d, 0x38, 0x48, 0xc8, 0x57, 0xae, 0xb3, 0xd4, 0x46, 0x45,
+	0x65, 0x45, 0x5b, 0x4d, 0x8a, 0xea, 0x15, 0xcb, 0x48, 0xe3, 0xf3, 0x86, 0xff, 0x36, 0xbe, 0x44,
+	0x29, 0xe3, 0x17, 0xdc, 0x6a, 0x05, 0x12, 0x50, 0xc9, 0xb9, 0xde, 0x54, 0x48, 0x1a, 0x9f, 0xe3,
+	0x72, 0x5f, 0xe4, 0xf6, 0x55, 0x39, 0x65, 0x5f, 0x5e, 0xa3, 0xc1, 0xbe, 0xa2, 0x91, 0x04, 0x6d,
+	0xe9, 0xe4, 0x42, 0xbf, 0x78, 0x14, 0x67, 0xab, 0x8d, 0x93, 0xee, 0xaa, 0x30, 0xbe, 0x85, 0xe3,
+	0x4a, 0x95, 0x07, 0x7a, 0x60, 0xe3, 0x3f, 0x3a, 0x98, 0xe7, 0xfc, 0xb3, 0x89, 0xfa, 0xec, 0xf2,
+	0xfc, 0x06, 0x5d, 0x43, 0x47, 0xcd, 0x1a, 0x54, 0x63, 0xdb, 0xf5, 0x61, 0x65, 0x0f, 0x1a, 0x10,
+	0xf1, 0x62, 0x85, 0xf7, 0xd0, 0x0d, 0x18, 0x05, 0x7f, 0x84, 0xab, 0xf0, 0xcd, 0x29, 0x64, 0x3b,
+	0x8d, 0x18, 0x59, 0x74, 0x02, 0x70, 0x41, 0x52, 0x35, 0x0a, 0xea, 0x68, 0xae, 0xcf, 0x0d, 0x7b,
+	0xd0, 0x80, 0x90, 0x15, 0xa7, 0x00, 0xf7, 0xf3, 0x02, 0x3d, 0xab, 0xe2, 0x2b, 0xd3, 0xc4, 0x7e,
+	0xd2, 0xf0, 0xa8, 0xf1, 0xde, 0x2b, 0x4d, 0x29, 0x29, 0xde, 0x54, 0xbd, 0x92, 0x25, 0x4f, 0xd8,
+	0x83, 0x06, 0xc4, 0x9a, 0x92, 0xb7, 0x34, 0xda, 0xae, 0x64, 0xd9, 0xd6, 0xb6, 0xd3, 0x88, 0x91,
+	0x45, 0x09, 0x3c, 0xda, 0xb0, 0x17, 0x1a, 0x36, 0x5c, 0xc0, 0x9a, 0x8f, 0xed, 0x17, 0x3b, 0x20,
+	0x45, 0x9b, 0xb3, 0x31, 0x9c, 0xf8, 0xf4, 0x6e, 0x14, 0x46, 0x2c, 0xce, 0x92, 0xf5, 0xaf, 0x46,
+	0xa1, 0xcf, 0xce, 0x2a, 0x36, 0x9c, 0x68, 0x5f, 0x0e, 0xc4, 0x8f, 0xfd, 0xf5, 0xdf, 0x01, 0x00,
+	0x42, 0x5b, 0xce, 0x3a, 0xf2, 0x07, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -407,6 +897,10 @@ type CloudProviderICSClient interface {
 	GetNode(ctx context.Context, in *GetNodeRequest, opts ...grpc.CallOption) (*GetNodeReply, error)
 	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesReply, error)
 	GetVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionReply, error)
+	WatchNodes(ctx context.Context, in *WatchNodesRequest, opts ...grpc.CallOption) (CloudProviderICS_WatchNodesClient, error)
+	GetZone(ctx context.Context, in *GetZoneRequest, opts ...grpc.CallOption) (*GetZoneReply, error)
+	ListZones(ctx context.Context, in *ListZonesRequest, opts ...grpc.CallOption) (*ListZonesReply, error)
+	ListNodesByZone(ctx context.Context, in *ListNodesByZoneRequest, opts ...grpc.CallOption) (*ListNodesByZoneReply, error)
 }
 
 type cloudProviderICSClient struct {
@@ -444,11 +938,74 @@ func (c *cloudProviderICSClient) GetVersion(ctx context.Context, in *VersionRequ
 	return out, nil
 }
 
+func (c *cloudProviderICSClient) WatchNodes(ctx context.Context, in *WatchNodesRequest, opts ...grpc.CallOption) (CloudProviderICS_WatchNodesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CloudProviderICS_serviceDesc.Streams[0], "/cloudproviderics.CloudProviderICS/WatchNodes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cloudProviderICSWatchNodesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CloudProviderICS_WatchNodesClient interface {
+	Recv() (*NodeEvent, error)
+	grpc.ClientStream
+}
+
+type cloudProviderICSWatchNodesClient struct {
+	grpc.ClientStream
+}
+
+func (x *cloudProviderICSWatchNodesClient) Recv() (*NodeEvent, error) {
+	m := new(NodeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cloudProviderICSClient) GetZone(ctx context.Context, in *GetZoneRequest, opts ...grpc.CallOption) (*GetZoneReply, error) {
+	out := new(GetZoneReply)
+	err := c.cc.Invoke(ctx, "/cloudproviderics.CloudProviderICS/GetZone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudProviderICSClient) ListZones(ctx context.Context, in *ListZonesRequest, opts ...grpc.CallOption) (*ListZonesReply, error) {
+	out := new(ListZonesReply)
+	err := c.cc.Invoke(ctx, "/cloudproviderics.CloudProviderICS/ListZones", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudProviderICSClient) ListNodesByZone(ctx context.Context, in *ListNodesByZoneRequest, opts ...grpc.CallOption) (*ListNodesByZoneReply, error) {
+	out := new(ListNodesByZoneReply)
+	err := c.cc.Invoke(ctx, "/cloudproviderics.CloudProviderICS/ListNodesByZone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CloudProviderICSServer is the server API for CloudProviderICS service.
 type CloudProviderICSServer interface {
 	GetNode(context.Context, *GetNodeRequest) (*GetNodeReply, error)
 	ListNodes(context.Context, *ListNodesRequest) (*ListNodesReply, error)
 	GetVersion(context.Context, *VersionRequest) (*VersionReply, error)
+	WatchNodes(*WatchNodesRequest, CloudProviderICS_WatchNodesServer) error
+	GetZone(context.Context, *GetZoneRequest) (*GetZoneReply, error)
+	ListZones(context.Context, *ListZonesRequest) (*ListZonesReply, error)
+	ListNodesByZone(context.Context, *ListNodesByZoneRequest) (*ListNodesByZoneReply, error)
 }
 
 // UnimplementedCloudProviderICSServer can be embedded to have forward compatible implementations.
@@ -464,6 +1021,18 @@ func (*UnimplementedCloudProviderICSServer) ListNodes(ctx context.Context, req *
 func (*UnimplementedCloudProviderICSServer) GetVersion(ctx context.Context, req *VersionRequest) (*VersionReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
 }
+func (*UnimplementedCloudProviderICSServer) WatchNodes(req *WatchNodesRequest, srv CloudProviderICS_WatchNodesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNodes not implemented")
+}
+func (*UnimplementedCloudProviderICSServer) GetZone(ctx context.Context, req *GetZoneRequest) (*GetZoneReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetZone not implemented")
+}
+func (*UnimplementedCloudProviderICSServer) ListZones(ctx context.Context, req *ListZonesRequest) (*ListZonesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListZones not implemented")
+}
+func (*UnimplementedCloudProviderICSServer) ListNodesByZone(ctx context.Context, req *ListNodesByZoneRequest) (*ListNodesByZoneReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNodesByZone not implemented")
+}
 
 func RegisterCloudProviderICSServer(s *grpc.Server, srv CloudProviderICSServer) {
 	s.RegisterService(&_CloudProviderICS_serviceDesc, srv)
@@ -523,6 +1092,81 @@ func _CloudProviderICS_GetVersion_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CloudProviderICS_WatchNodes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchNodesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CloudProviderICSServer).WatchNodes(m, &cloudProviderICSWatchNodesServer{stream})
+}
+
+type CloudProviderICS_WatchNodesServer interface {
+	Send(*NodeEvent) error
+	grpc.ServerStream
+}
+
+type cloudProviderICSWatchNodesServer struct {
+	grpc.ServerStream
+}
+
+func (x *cloudProviderICSWatchNodesServer) Send(m *NodeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CloudProviderICS_GetZone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetZoneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudProviderICSServer).GetZone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cloudproviderics.CloudProviderICS/GetZone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudProviderICSServer).GetZone(ctx, req.(*GetZoneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudProviderICS_ListZones_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListZonesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudProviderICSServer).ListZones(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cloudproviderics.CloudProviderICS/ListZones",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudProviderICSServer).ListZones(ctx, req.(*ListZonesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudProviderICS_ListNodesByZone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNodesByZoneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudProviderICSServer).ListNodesByZone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cloudproviderics.CloudProviderICS/ListNodesByZone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudProviderICSServer).ListNodesByZone(ctx, req.(*ListNodesByZoneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _CloudProviderICS_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "cloudproviderics.CloudProviderICS",
 	HandlerType: (*CloudProviderICSServer)(nil),
@@ -539,7 +1183,25 @@ var _CloudProviderICS_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetVersion",
 			Handler:    _CloudProviderICS_GetVersion_Handler,
 		},
+		{
+			MethodName: "GetZone",
+			Handler:    _CloudProviderICS_GetZone_Handler,
+		},
+		{
+			MethodName: "ListZones",
+			Handler:    _CloudProviderICS_ListZones_Handler,
+		},
+		{
+			MethodName: "ListNodesByZone",
+			Handler:    _CloudProviderICS_ListNodesByZone_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchNodes",
+			Handler:       _CloudProviderICS_WatchNodes_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "cloudproviderics.proto",
 }
@@ -12,12 +12,18 @@ import (
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 )
 
-// NewICSCloudProviderClient creates CloudProviderICSClient
-func NewICSCloudProviderClient(ctx context.Context) (pb.CloudProviderICSClient, error) {
+// NewICSCloudProviderClient creates CloudProviderICSClient. Without any
+// DialOption, it connects in plaintext, otherwise opts must set the transport
+// security, see WithClientTLS and grpc.WithInsecure.
+func NewICSCloudProviderClient(ctx context.Context, opts ...grpc.DialOption) (pb.CloudProviderICSClient, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+
 	var conn *grpc.ClientConn
 	var err error
 	for i := 0; i < RetryAttempts; i++ {
-		conn, err = grpc.Dial(icfg.DefaultAPIBinding, grpc.WithInsecure())
+		conn, err = grpc.Dial(icfg.DefaultAPIBinding, opts...)
 		if err == nil {
 			break
 		}
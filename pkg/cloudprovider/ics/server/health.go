@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/klog"
+)
+
+// ServiceName is the name of the API service in the gRPC health service.
+const ServiceName = "cloudproviderics.CloudProviderICS"
+
+// HealthChecker reports the readiness of the dependencies of the CCM by
+// name.
+type HealthChecker interface {
+	Readiness() map[string]Check
+}
+
+// Check is the readiness of a dependency.
+type Check struct {
+	// Why the dependency is not ready, nil if it is.
+	Err error
+	// Shown next to the outcome of the check if not empty.
+	Detail string
+}
+
+// SetServing sets the status reported by the gRPC health service, for the
+// API service and the server as a whole.
+func (s *server) SetServing(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(ServiceName, status)
+}
+
+// HealthServer serves /healthz and /readyz over HTTP. /healthz succeeds as
+// long as the process is up, /readyz only when every dependency is ready, or
+// with ?any=true when at least one is.
+type HealthServer struct {
+	binding string
+	checker HealthChecker
+	s       *http.Server
+}
+
+// NewHealthServer returns a HealthServer reporting the readiness of checker.
+func NewHealthServer(binding string, checker HealthChecker) *HealthServer {
+	h := &HealthServer{
+		binding: binding,
+		checker: checker,
+	}
+	h.s = &http.Server{Handler: h.Handler()}
+	return h
+}
+
+// Handler returns the handler of /healthz and /readyz.
+func (h *HealthServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("/readyz", h.readyz)
+	return mux
+}
+
+// readyz writes one [+] or [-] line per dependency, like the Kubernetes
+// components do, and fails with 503 if any of them is not ready. With
+// ?any=true, it fails only if none of them is ready.
+func (h *HealthServer) readyz(w http.ResponseWriter, r *http.Request) {
+	anyReady := r.URL.Query().Get("any") == "true"
+	readiness := h.checker.Readiness()
+	names := make([]string, 0, len(readiness))
+	for name := range readiness {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	failed := 0
+	for _, name := range names {
+		check := readiness[name]
+		var detail string
+		if check.Detail != "" {
+			detail = " (" + check.Detail + ")"
+		}
+		if check.Err != nil {
+			failed++
+			fmt.Fprintf(&out, "[-]%s failed: %v%s\n", name, check.Err, detail)
+		} else {
+			fmt.Fprintf(&out, "[+]%s ok%s\n", name, detail)
+		}
+	}
+
+	ready := failed == 0
+	if anyReady && len(names) > 0 {
+		ready = failed < len(names)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		out.WriteString("readyz check failed\n")
+	} else {
+		out.WriteString("readyz check passed\n")
+	}
+	w.Write(out.Bytes())
+}
+
+// Start the health server. The server is listening once Start returns.
+func (h *HealthServer) Start() {
+	lis, err := net.Listen("tcp", h.binding)
+	if err != nil {
+		klog.Fatalf("Health server Listen() failed: %s", err)
+	}
+
+	go func() {
+		if err := h.s.Serve(lis); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("Health server Serve() failed: %s", err)
+		}
+	}()
+
+	klog.Infof("Health server listening on %s", lis.Addr())
+}
+
+// Stop the health server.
+func (h *HealthServer) Stop() {
+	h.s.Close()
+}
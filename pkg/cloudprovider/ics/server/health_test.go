@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+type fakeHealthChecker map[string]Check
+
+func (c fakeHealthChecker) Readiness() map[string]Check {
+	return c
+}
+
+func TestHealthServer(t *testing.T) {
+	checker := fakeHealthChecker{
+		"icenter/a": {Detail: "endpoint 10.0.0.1:443"},
+		"icenter/b": {Err: errors.New("unreachable")},
+	}
+	ts := httptest.NewServer(NewHealthServer("", checker).Handler())
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get("/healthz"); code != http.StatusOK || body != "ok" {
+		t.Errorf("/healthz = %d %q, expected 200 ok", code, body)
+	}
+
+	code, body := get("/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with an unreachable iCenter = %d, expected 503", code)
+	}
+	if !strings.Contains(body, "[+]icenter/a ok (endpoint 10.0.0.1:443)") || !strings.Contains(body, "[-]icenter/b failed: unreachable\n") {
+		t.Errorf("unexpected /readyz body %q", body)
+	}
+
+	if code, _ := get("/readyz?any=true"); code != http.StatusOK {
+		t.Errorf("/readyz?any=true with one iCenter ready = %d, expected 200", code)
+	}
+	checker["icenter/a"] = Check{Err: errors.New("unreachable")}
+	if code, _ := get("/readyz?any=true"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz?any=true with no iCenter ready = %d, expected 503", code)
+	}
+
+	checker["icenter/a"] = Check{}
+	checker["icenter/b"] = Check{}
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz with every iCenter ready = %d, expected 200", code)
+	}
+}
+
+func TestGRPCServerHealth(t *testing.T) {
+	s, err := NewServer(icfg.DefaultAPIBinding, &fakeNodeMgr{}, SecurityConfig{})
+	if err != nil {
+		t.Fatalf("NewServer err=%v", err)
+	}
+	s.Start()
+	defer s.(*server).Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), (5 * time.Second))
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, icfg.DefaultAPIBinding, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) err=%v", service, err)
+		}
+		return resp.Status
+	}
+
+	for _, service := range []string{"", ServiceName} {
+		if status := check(service); status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("Check(%q) before SetServing = %v, expected NOT_SERVING", service, status)
+		}
+	}
+
+	s.SetServing(true)
+	for _, service := range []string{"", ServiceName} {
+		if status := check(service); status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, expected SERVING", service, status)
+		}
+	}
+}
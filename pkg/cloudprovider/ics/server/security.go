@@ -0,0 +1,376 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+)
+
+const (
+	// ReloadCheckInterval is how often, at most, the certificate, client CA
+	// and token files are checked for changes.
+	ReloadCheckInterval = 10 * time.Second
+
+	// authorizationHeader is the metadata key of the bearer token.
+	authorizationHeader = "authorization"
+
+	// bearerPrefix prefixes the token in the authorization header.
+	bearerPrefix = "Bearer "
+
+	// healthMethodPrefix prefixes the methods of the gRPC health service,
+	// which probes call without the bearer token.
+	healthMethodPrefix = "/grpc.health.v1.Health/"
+)
+
+// SecurityConfig configures the transport security and the authentication of
+// the API. The zero value serves the API in plaintext without authentication.
+type SecurityConfig struct {
+	// TLS certificate and key served by the API.
+	CertFile string
+	KeyFile  string
+	// CA bundle the client certificates must be signed by. Client
+	// certificates are not requested unless set.
+	ClientCAFile string
+	// File holding the bearer token the clients must present. Requires the
+	// TLS certificate, so that the token is not sent in plaintext.
+	TokenFile string
+	// DisableReflection disables gRPC reflection.
+	DisableReflection bool
+}
+
+// serverOptions returns the gRPC server options enforcing the configuration.
+func (c SecurityConfig) serverOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if c.CertFile != "" {
+		certs, err := newCertReloader(c.CertFile, c.KeyFile, c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			GetConfigForClient: certs.getConfigForClient,
+		})))
+	}
+
+	if c.TokenFile != "" {
+		if c.CertFile == "" {
+			return nil, fmt.Errorf("the API token file %s requires a TLS certificate", c.TokenFile)
+		}
+		auth, err := newTokenAuth(c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			grpc.UnaryInterceptor(auth.unaryInterceptor),
+			grpc.StreamInterceptor(auth.streamInterceptor))
+	}
+
+	return opts, nil
+}
+
+// fileReloader calls load once the modification time of any of its files
+// changes. Changes are checked at most every ReloadCheckInterval.
+type fileReloader struct {
+	files         []string
+	load          func() error
+	checkInterval time.Duration
+
+	lock      sync.Mutex
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+func newFileReloader(load func() error, files ...string) (*fileReloader, error) {
+	r := &fileReloader{files: files, load: load, checkInterval: ReloadCheckInterval}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := load(); err != nil {
+		return nil, err
+	}
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+func (r *fileReloader) stat() ([]time.Time, error) {
+	modTimes := make([]time.Time, len(r.files))
+	for i, file := range r.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// reloadIfChanged reloads the files if they changed. On failure, the files
+// loaded before are kept.
+func (r *fileReloader) reloadIfChanged() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.lastCheck) < r.checkInterval {
+		return
+	}
+	r.lastCheck = time.Now()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		klog.Errorf("Failed to check %v for changes: %v", r.files, err)
+		return
+	}
+	changed := false
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		klog.Errorf("Failed to reload %v, keeping the previous ones: %v", r.files, err)
+		return
+	}
+	r.modTimes = modTimes
+	klog.Infof("Reloaded %v", r.files)
+}
+
+// certReloader serves the API certificate, and verifies the client
+// certificates against the client CA if one is set.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	reloader     *fileReloader
+
+	lock      sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	files := []string{certFile, keyFile}
+	if clientCAFile != "" {
+		files = append(files, clientCAFile)
+	}
+	reloader, err := newFileReloader(c.load, files...)
+	if err != nil {
+		return nil, err
+	}
+	c.reloader = reloader
+	return c, nil
+}
+
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", c.clientCAFile)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	return nil
+}
+
+func (c *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.reloader.reloadIfChanged()
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{*c.cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+	}
+	if c.clientCAs != nil {
+		config.ClientCAs = c.clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tokenAuth rejects the calls without the bearer token of its file, except
+// those of the gRPC health service.
+type tokenAuth struct {
+	tokenFile string
+	reloader  *fileReloader
+
+	lock  sync.RWMutex
+	token []byte
+}
+
+func newTokenAuth(tokenFile string) (*tokenAuth, error) {
+	a := &tokenAuth{tokenFile: tokenFile}
+	reloader, err := newFileReloader(a.load, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	a.reloader = reloader
+	return a, nil
+}
+
+func (a *tokenAuth) load() error {
+	data, err := ioutil.ReadFile(a.tokenFile)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("token file %s is empty", a.tokenFile)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.token = []byte(token)
+	return nil
+}
+
+func (a *tokenAuth) authorize(ctx context.Context) error {
+	a.reloader.reloadIfChanged()
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	var token string
+	for _, value := range md.Get(authorizationHeader) {
+		if strings.HasPrefix(value, bearerPrefix) {
+			token = strings.TrimPrefix(value, bearerPrefix)
+		}
+	}
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if subtle.ConstantTimeCompare([]byte(token), a.token) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	return nil
+}
+
+func (a *tokenAuth) unaryInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+		return handler(ctx, req)
+	}
+	if err := a.authorize(ctx); err != nil {
+		klog.V(2).Infof("Rejected %s: %v", info.FullMethod, err)
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *tokenAuth) streamInterceptor(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+		return handler(srv, ss)
+	}
+	if err := a.authorize(ss.Context()); err != nil {
+		klog.V(2).Infof("Rejected %s: %v", info.FullMethod, err)
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// bearerToken sends a bearer token with every call.
+type bearerToken struct {
+	token  string
+	secure bool
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: bearerPrefix + t.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials
+func (t bearerToken) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// WithBearerToken returns a DialOption sending the token with every call. If
+// secure is true, the token is never sent over a plaintext connection.
+func WithBearerToken(token string, secure bool) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(bearerToken{token: token, secure: secure})
+}
+
+// WithClientTLS returns a DialOption verifying the API certificate against
+// caFile, and presenting the client certificate of certFile and keyFile for
+// mTLS if both are set. An empty caFile uses the system roots.
+func WithClientTLS(caFile, certFile, keyFile, serverName string) (grpc.DialOption, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert returns a certificate signed by parent, or a self-signed CA if
+// parent is nil.
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write writes the certificate and key to dir/name.crt and dir/name.key.
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// touch moves the modification time of the files forward, so that a rewrite
+// within the timestamp granularity is still seen as a change.
+func touch(t *testing.T, files ...string) {
+	future := time.Now().Add(time.Minute)
+	for _, file := range files {
+		if err := os.Chtimes(file, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGRPCServerSecurity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ics-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "server", ca).write(t, dir, "server")
+	clientCertFile, clientKeyFile := newTestCert(t, "client", ca).write(t, dir, "client")
+	otherCertFile, otherKeyFile := newTestCert(t, "other", nil).write(t, dir, "other")
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	//server
+	s, err := NewServer(icfg.DefaultAPIBinding, &fakeNodeMgr{}, SecurityConfig{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		ClientCAFile:      caFile,
+		TokenFile:         tokenFile,
+		DisableReflection: true,
+	})
+	if err != nil {
+		t.Fatalf("NewServer err=%v", err)
+	}
+	s.Start()
+	defer s.(*server).Stop()
+
+	//clients
+	ctx, cancel := context.WithTimeout(context.Background(), (5 * time.Second))
+	defer cancel()
+
+	getVersion := func(opts ...grpc.DialOption) error {
+		c, err := NewICSCloudProviderClient(ctx, opts...)
+		if err != nil {
+			t.Fatalf("could not greet: %v", err)
+		}
+		_, err = c.GetVersion(ctx, &pb.VersionRequest{})
+		return err
+	}
+	clientTLS := func(certFile, keyFile string) grpc.DialOption {
+		opt, err := WithClientTLS(caFile, certFile, keyFile, "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return opt
+	}
+
+	if err := getVersion(clientTLS(clientCertFile, clientKeyFile), WithBearerToken("s3cr3t", true)); err != nil {
+		t.Errorf("mTLS with the token should succeed: %v", err)
+	}
+	err = getVersion(clientTLS(clientCertFile, clientKeyFile), WithBearerToken("wrong", true))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("a wrong token should be unauthenticated, got %v", err)
+	}
+	err = getVersion(clientTLS(clientCertFile, clientKeyFile))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("a missing token should be unauthenticated, got %v", err)
+	}
+	if err := getVersion(clientTLS("", ""), WithBearerToken("s3cr3t", true)); err == nil {
+		t.Error("TLS without a client certificate should fail")
+	}
+	if err := getVersion(clientTLS(otherCertFile, otherKeyFile), WithBearerToken("s3cr3t", true)); err == nil {
+		t.Error("a client certificate of another CA should fail")
+	}
+	if err := getVersion(grpc.WithInsecure(), WithBearerToken("s3cr3t", false)); err == nil {
+		t.Error("plaintext should fail")
+	}
+
+	// The health service is served without the token.
+	conn, err := grpc.DialContext(ctx, icfg.DefaultAPIBinding, clientTLS(clientCertFile, clientKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("the health service should not require the token: %v", err)
+	}
+
+	if _, err := NewServer(icfg.DefaultAPIBinding, &fakeNodeMgr{}, SecurityConfig{TokenFile: tokenFile}); err == nil {
+		t.Error("a token file without a TLS certificate should fail")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ics-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	first := newTestCert(t, "first", ca)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	certs, err := newCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("newCertReloader err=%v", err)
+	}
+	certs.reloader.checkInterval = 0
+
+	servedCN := func() string {
+		config, err := certs.getConfigForClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Subject.CommonName
+	}
+	if cn := servedCN(); cn != "first" {
+		t.Errorf("served %s, expected first", cn)
+	}
+
+	newTestCert(t, "second", ca).write(t, dir, "server")
+	touch(t, certFile, keyFile)
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("served %s after the rotation, expected second", cn)
+	}
+
+	// An invalid certificate keeps the previous one.
+	if err := ioutil.WriteFile(certFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, certFile)
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("served %s after an invalid rotation, expected second", cn)
+	}
+}
+
+func TestTokenAuthReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ics-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newTokenAuth(tokenFile)
+	if err != nil {
+		t.Fatalf("newTokenAuth err=%v", err)
+	}
+	auth.reloader.checkInterval = 0
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs(authorizationHeader, bearerPrefix+token))
+	}
+
+	if err := auth.authorize(withToken("first")); err != nil {
+		t.Errorf("authorize(first) err=%v", err)
+	}
+	if err := auth.authorize(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("authorize() without a token = %v", err)
+	}
+
+	if err := ioutil.WriteFile(tokenFile, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, tokenFile)
+	if err := auth.authorize(withToken("first")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("the rotated token should be rejected, got %v", err)
+	}
+	if err := auth.authorize(withToken("second")); err != nil {
+		t.Errorf("authorize(second) err=%v", err)
+	}
+}
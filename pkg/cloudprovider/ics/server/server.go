@@ -19,13 +19,17 @@ limitations under the License.
 package server
 
 import (
+	"errors"
 	"log"
 	"net"
-	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"k8s.io/klog"
 
 	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
@@ -40,35 +44,58 @@ const (
 	RetryAttempts int = 3
 )
 
+var (
+	// ErrResumeTokenExpired is returned when the events after a resume token
+	// are no longer available. The client must watch again without it.
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
 // NodeManagerInterface describes types that can export a list of Kubernetes
 // nodes into the supplied slice address.
 type NodeManagerInterface interface {
 	GetNode(UUID string, node *pb.Node) error
 	ExportNodes(icenter string, datacenter string, nodeList *[]*pb.Node) error
+	WatchNodes(ctx context.Context, resumeToken string, send func(*pb.NodeEvent) error) error
+	GetZone(ctx context.Context, UUID string, zone *pb.Zone) error
+	ExportZones(ctx context.Context, zoneList *[]*pb.Zone) error
+	ExportNodesByZone(ctx context.Context, zone string, region string, nodeList *[]*pb.Node) error
 }
 
-// GRPCServer describes an object that can start a gRPC server.
+// GRPCServer describes an object that can start a gRPC server and report
+// whether it is serving.
 type GRPCServer interface {
 	Start()
+	SetServing(serving bool)
 }
 
 type server struct {
 	binding string
 	s       *grpc.Server
 	nodeMgr NodeManagerInterface
+	health  *health.Server
 }
 
-// NewServer generates a new gRPC Server
-func NewServer(binding string, nodeMgr NodeManagerInterface) GRPCServer {
-	s := grpc.NewServer()
+// NewServer generates a new gRPC Server secured by the given configuration
+func NewServer(binding string, nodeMgr NodeManagerInterface, security SecurityConfig) (GRPCServer, error) {
+	opts, err := security.serverOptions()
+	if err != nil {
+		return nil, err
+	}
+	s := grpc.NewServer(opts...)
 	myServer := &server{
 		binding: binding,
 		s:       s,
 		nodeMgr: nodeMgr,
+		health:  health.NewServer(),
 	}
 	pb.RegisterCloudProviderICSServer(s, myServer)
-	reflection.Register(s)
-	return myServer
+	// Not serving until the iCenters are known to be reachable.
+	myServer.SetServing(false)
+	healthpb.RegisterHealthServer(s, myServer.health)
+	if !security.DisableReflection {
+		reflection.Register(s)
+	}
+	return myServer, nil
 }
 
 // GetNode implements CloudProviderICS interface
@@ -99,6 +126,63 @@ func (s *server) ListNodes(ctx context.Context, request *pb.ListNodesRequest) (*
 	return reply, nil
 }
 
+// WatchNodes implements CloudProviderICS interface
+func (s *server) WatchNodes(request *pb.WatchNodesRequest, stream pb.CloudProviderICS_WatchNodesServer) error {
+	//Do not allow specifying the Datacenter without specifying the iCenter
+	if request.Icenter == "" && request.Datacenter != "" {
+		request.Datacenter = ""
+	}
+	err := s.nodeMgr.WatchNodes(stream.Context(), request.ResumeToken, func(event *pb.NodeEvent) error {
+		if request.Icenter != "" && event.Node.Icenter != request.Icenter {
+			return nil
+		}
+		if request.Datacenter != "" && event.Node.Datacenter != request.Datacenter {
+			return nil
+		}
+		return stream.Send(event)
+	})
+	if err == ErrResumeTokenExpired {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	return err
+}
+
+// GetZone implements CloudProviderICS interface
+func (s *server) GetZone(ctx context.Context, request *pb.GetZoneRequest) (*pb.GetZoneReply, error) {
+	reply := &pb.GetZoneReply{
+		Zone: &pb.Zone{},
+	}
+	err := s.nodeMgr.GetZone(ctx, request.Uuid, reply.Zone)
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply, nil
+}
+
+// ListZones implements CloudProviderICS interface
+func (s *server) ListZones(ctx context.Context, request *pb.ListZonesRequest) (*pb.ListZonesReply, error) {
+	reply := &pb.ListZonesReply{
+		Zones: make([]*pb.Zone, 0),
+	}
+	err := s.nodeMgr.ExportZones(ctx, &reply.Zones)
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply, nil
+}
+
+// ListNodesByZone implements CloudProviderICS interface
+func (s *server) ListNodesByZone(ctx context.Context, request *pb.ListNodesByZoneRequest) (*pb.ListNodesByZoneReply, error) {
+	reply := &pb.ListNodesByZoneReply{
+		Nodes: make([]*pb.Node, 0),
+	}
+	err := s.nodeMgr.ExportNodesByZone(ctx, request.Zone, request.Region, &reply.Nodes)
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply, nil
+}
+
 // GetVersion implements obtaining the version of the API server
 func (s *server) GetVersion(ctx context.Context, request *pb.VersionRequest) (*pb.VersionReply, error) {
 	return &pb.VersionReply{
@@ -106,43 +190,21 @@ func (s *server) GetVersion(ctx context.Context, request *pb.VersionRequest) (*p
 	}, nil
 }
 
-// Start the server
+// Start the server. The server is listening once Start returns.
 func (s *server) Start() {
-	go func() {
-		lis, err := net.Listen("tcp", s.binding)
-		if err != nil {
-			klog.Fatalf("Server Listen() failed: %s", err)
-
-		}
+	lis, err := net.Listen("tcp", s.binding)
+	if err != nil {
+		klog.Fatalf("Server Listen() failed: %s", err)
+	}
 
-		err = s.s.Serve(lis)
+	go func() {
+		err := s.s.Serve(lis)
 		if err != nil {
 			log.Printf("Server Serve() failed: %s", err)
 		}
 	}()
 
-	//Wait until the server is up and running
-	for i := 0; i < RetryAttempts; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), (5 * time.Second))
-		defer cancel()
-
-		c, err := NewICSCloudProviderClient(ctx)
-		if err != nil {
-			klog.Warningf("could not greet: %v", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		r, err := c.GetVersion(ctx, &pb.VersionRequest{})
-		if err != nil {
-			klog.Warningf("could not getversion: %v", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		klog.Infof("APIVersion: %s", r.GetVersion())
-		break
-	}
+	klog.Infof("APIVersion: %s, listening on %s", APIVersion, lis.Addr())
 }
 
 // Stop the server
@@ -18,12 +18,15 @@ package server
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
@@ -65,6 +68,51 @@ func (nm *fakeNodeMgr) ExportNodes(icenter string, datacenter string, nodeList *
 	return nil
 }
 
+func (nm *fakeNodeMgr) WatchNodes(ctx context.Context, resumeToken string, send func(*pb.NodeEvent) error) error {
+	if resumeToken == "expired" {
+		return ErrResumeTokenExpired
+	}
+
+	for _, icenter := range []string{"10.0.0.2", "127.0.0.1"} {
+		event := &pb.NodeEvent{
+			Type:        pb.NodeEvent_ADDED,
+			Node:        &pb.Node{Icenter: icenter, Datacenter: "dc", Uuid: exampleUUIDForGoTest},
+			ResumeToken: "1",
+		}
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (nm *fakeNodeMgr) GetZone(ctx context.Context, uuid string, zone *pb.Zone) error {
+	if uuid != exampleUUIDForGoTest {
+		return errors.New("VM not found")
+	}
+	zone.Zone = "zone-a"
+	zone.Region = "region-1"
+	zone.Icenter = "127.0.0.1"
+	zone.Datacenter = "dc"
+	return nil
+}
+
+func (nm *fakeNodeMgr) ExportZones(ctx context.Context, zoneList *[]*pb.Zone) error {
+	zone := &pb.Zone{}
+	nm.GetZone(ctx, exampleUUIDForGoTest, zone)
+	*zoneList = append(*zoneList, zone)
+	return nil
+}
+
+func (nm *fakeNodeMgr) ExportNodesByZone(ctx context.Context, zone string, region string, nodeList *[]*pb.Node) error {
+	if zone != "zone-a" || region != "region-1" {
+		return nil
+	}
+	return nm.ExportNodes("", "", nodeList)
+}
+
 func TestGRPCServerNode(t *testing.T) {
 	//server
 	s := grpc.NewServer()
@@ -170,3 +218,111 @@ func TestGRPCServerVersion(t *testing.T) {
 		t.Errorf("GetVersion mismatch %s != %s", APIVersion, r.GetVersion())
 	}
 }
+
+func TestGRPCServerWatchNodes(t *testing.T) {
+	//server
+	s := grpc.NewServer()
+	myServer := &server{
+		binding: icfg.DefaultAPIBinding,
+		s:       s,
+		nodeMgr: &fakeNodeMgr{},
+	}
+	pb.RegisterCloudProviderICSServer(s, myServer)
+	reflection.Register(s)
+
+	myServer.Start()
+	defer myServer.Stop()
+
+	//client
+	ctx, cancel := context.WithTimeout(context.Background(), (5 * time.Second))
+	defer cancel()
+
+	c, err := NewICSCloudProviderClient(ctx)
+	if err != nil {
+		t.Fatalf("could not greet: %v", err)
+	}
+
+	stream, err := c.WatchNodes(ctx, &pb.WatchNodesRequest{Icenter: "127.0.0.1", Datacenter: "dc"})
+	if err != nil {
+		t.Fatalf("could not watch: %v", err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("could not receive: %v", err)
+	}
+	if event.Type != pb.NodeEvent_ADDED || event.Node.Icenter != "127.0.0.1" || event.ResumeToken != "1" {
+		t.Errorf("unexpected event %v", event)
+	}
+
+	stream, err = c.WatchNodes(ctx, &pb.WatchNodesRequest{ResumeToken: "expired"})
+	if err != nil {
+		t.Fatalf("could not watch: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.OutOfRange {
+		t.Errorf("an expired resume token should be out of range, got %v", err)
+	}
+}
+
+func TestGRPCServerZones(t *testing.T) {
+	//server
+	s := grpc.NewServer()
+	myServer := &server{
+		binding: icfg.DefaultAPIBinding,
+		s:       s,
+		nodeMgr: &fakeNodeMgr{},
+	}
+	pb.RegisterCloudProviderICSServer(s, myServer)
+	reflection.Register(s)
+
+	myServer.Start()
+	defer myServer.Stop()
+
+	//client
+	ctx, cancel := context.WithTimeout(context.Background(), (5 * time.Second))
+	defer cancel()
+
+	c, err := NewICSCloudProviderClient(ctx)
+	if err != nil {
+		t.Fatalf("could not greet: %v", err)
+	}
+
+	zone, err := c.GetZone(ctx, &pb.GetZoneRequest{Uuid: exampleUUIDForGoTest})
+	if err != nil {
+		t.Fatalf("could not get zone: %v", err)
+	}
+	if zone.Error != "" || zone.Zone.Zone != "zone-a" || zone.Zone.Region != "region-1" {
+		t.Errorf("unexpected zone %v", zone)
+	}
+
+	zone, err = c.GetZone(ctx, &pb.GetZoneRequest{Uuid: "missing"})
+	if err != nil {
+		t.Fatalf("could not get zone: %v", err)
+	}
+	if zone.Error == "" {
+		t.Errorf("GetZone of a missing VM should fail")
+	}
+
+	zones, err := c.ListZones(ctx, &pb.ListZonesRequest{})
+	if err != nil {
+		t.Fatalf("could not list zones: %v", err)
+	}
+	if len(zones.Zones) != 1 || zones.Zones[0].Zone != "zone-a" {
+		t.Errorf("unexpected zones %v", zones)
+	}
+
+	nodes, err := c.ListNodesByZone(ctx, &pb.ListNodesByZoneRequest{Zone: "zone-a", Region: "region-1"})
+	if err != nil {
+		t.Fatalf("could not list nodes by zone: %v", err)
+	}
+	if len(nodes.Nodes) != 1 || nodes.Nodes[0].Uuid != exampleUUIDForGoTest {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+
+	nodes, err = c.ListNodesByZone(ctx, &pb.ListNodesByZoneRequest{Zone: "zone-b", Region: "region-1"})
+	if err != nil {
+		t.Fatalf("could not list nodes by zone: %v", err)
+	}
+	if len(nodes.Nodes) != 0 {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+}
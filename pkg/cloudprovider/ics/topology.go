@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+const (
+	// HostZoneCacheTTL is how long the zone of a host is cached.
+	HostZoneCacheTTL = 5 * time.Minute
+)
+
+var (
+	// ErrZoneLabelsNotConfigured is returned when the topology is requested
+	// but neither the zone nor the region tag category is configured.
+	ErrZoneLabelsNotConfigured = errors.New("Zone and region tag categories are not configured")
+)
+
+// zoneLabels returns the configured zone and region tag categories.
+func (nm *NodeManager) zoneLabels() (string, string, error) {
+	if nm.cpiCfg == nil || (nm.cpiCfg.Labels.Zone == "" && nm.cpiCfg.Labels.Region == "") {
+		return "", "", ErrZoneLabelsNotConfigured
+	}
+	return nm.cpiCfg.Labels.Zone, nm.cpiCfg.Labels.Region, nil
+}
+
+// exportedNodeInfos returns the NodeInfo of the nodes that are both
+// registered and discovered.
+func (nm *NodeManager) exportedNodeInfos() []*NodeInfo {
+	nm.nodeRegInfoLock.RLock()
+	uuids := make([]string, 0, len(nm.nodeRegUUIDMap))
+	for uuid := range nm.nodeRegUUIDMap {
+		uuids = append(uuids, strings.ToLower(uuid))
+	}
+	nm.nodeRegInfoLock.RUnlock()
+
+	nm.nodeInfoLock.RLock()
+	defer nm.nodeInfoLock.RUnlock()
+	nodes := make([]*NodeInfo, 0, len(uuids))
+	for _, uuid := range uuids {
+		if node := nm.nodeUUIDMap[uuid]; node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// hostZone is the cached zone of a host.
+type hostZone struct {
+	zone    pb.Zone
+	expires time.Time
+}
+
+// nodeZone returns the zone of the host of the node. The zone of each host is
+// cached for HostZoneCacheTTL, so that the tags are not looked up for every
+// node.
+func (nm *NodeManager) nodeZone(ctx context.Context, node *NodeInfo) (*pb.Zone, error) {
+	zoneLabel, regionLabel, err := nm.zoneLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	key := node.tenantRef + "/" + node.vm.HostID
+	nm.hostZonesLock.Lock()
+	cached, ok := nm.hostZones[key]
+	nm.hostZonesLock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		zone := cached.zone
+		return &zone, nil
+	}
+
+	zoneResult, err := nm.connectionManager.LookupZoneByMoref(
+		ctx, node.tenantRef, node.vm.HostID, zoneLabel, regionLabel)
+	if err != nil {
+		klog.Errorf("Failed to get the zone of node %s. err: %+v", node.NodeName, err)
+		return nil, err
+	}
+
+	zone := pb.Zone{
+		Zone:       zoneResult[cm.ZoneLabel],
+		Region:     zoneResult[cm.RegionLabel],
+		Icenter:    node.icsServer,
+		Datacenter: node.dataCenter.Name,
+	}
+	nm.hostZonesLock.Lock()
+	nm.hostZones[key] = &hostZone{zone: zone, expires: time.Now().Add(HostZoneCacheTTL)}
+	nm.hostZonesLock.Unlock()
+	return &zone, nil
+}
+
+// GetZone gets the zone of the node by UUID
+func (nm *NodeManager) GetZone(ctx context.Context, UUID string, zone *pb.Zone) error {
+	if _, _, err := nm.zoneLabels(); err != nil {
+		return err
+	}
+
+	nodeInfo, err := nm.FindNodeInfo(UUID)
+	if err != nil {
+		klog.Errorf("GetZone failed err=%s", err)
+		return err
+	}
+
+	nodeZone, err := nm.nodeZone(ctx, nodeInfo)
+	if err != nil {
+		return err
+	}
+
+	zone.Zone = nodeZone.Zone
+	zone.Region = nodeZone.Region
+	zone.Icenter = nodeZone.Icenter
+	zone.Datacenter = nodeZone.Datacenter
+
+	return nil
+}
+
+// ExportZones lists the distinct zones of the nodes, sorted by region, zone,
+// iCenter and datacenter.
+func (nm *NodeManager) ExportZones(ctx context.Context, zoneList *[]*pb.Zone) error {
+	if _, _, err := nm.zoneLabels(); err != nil {
+		return err
+	}
+
+	type zoneKey struct {
+		zone, region, icenter, datacenter string
+	}
+	seen := make(map[zoneKey]bool)
+	var zones []*pb.Zone
+	for _, node := range nm.exportedNodeInfos() {
+		zone, err := nm.nodeZone(ctx, node)
+		if err != nil {
+			return err
+		}
+		key := zoneKey{zone.Zone, zone.Region, zone.Icenter, zone.Datacenter}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		zones = append(zones, zone)
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		a, b := zones[i], zones[j]
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		if a.Icenter != b.Icenter {
+			return a.Icenter < b.Icenter
+		}
+		return a.Datacenter < b.Datacenter
+	})
+
+	*zoneList = append(*zoneList, zones...)
+	return nil
+}
+
+// ExportNodesByZone lists the nodes whose host is in the zone and region,
+// across all the iCenters and datacenters.
+func (nm *NodeManager) ExportNodesByZone(ctx context.Context, zone string, region string, nodeList *[]*pb.Node) error {
+	if _, _, err := nm.zoneLabels(); err != nil {
+		return err
+	}
+
+	for _, node := range nm.exportedNodeInfos() {
+		nodeZone, err := nm.nodeZone(ctx, node)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(nodeZone.Zone, zone) || !strings.EqualFold(nodeZone.Region, region) {
+			klog.V(4).Infof("Node %s is in zone: %s and region: %s", node.NodeName, nodeZone.Zone, nodeZone.Region)
+			continue
+		}
+		*nodeList = append(*nodeList, newPBNode(node))
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pb "github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/proto"
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+func TestTopology(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	if err := newNodeManager(nil, connMgr).GetZone(ctx, "uuid", &pb.Zone{}); err != ErrZoneLabelsNotConfigured {
+		t.Errorf("GetZone() without zone labels err=%v, expected %v", err, ErrZoneLabelsNotConfigured)
+	}
+
+	nm := newNodeManager(&CPIConfig{Config: *cfg}, connMgr)
+
+	vm := model.VMs()[0]
+	regionID := model.AddTag(types.Tag{Name: "k8s-region-US", Description: cfg.Labels.Region})
+	zoneID := model.AddTag(types.Tag{Name: "k8s-zone-US-CA1", Description: cfg.Labels.Zone})
+	for _, tagID := range []string{regionID, zoneID} {
+		if err := model.AttachTag(tagID, vm.HostID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uuid := strings.ToLower(vm.UUID)
+	nm.RegisterNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: vm.Name},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: uuid},
+		},
+	})
+
+	zone := &pb.Zone{}
+	if err := nm.GetZone(ctx, uuid, zone); err != nil {
+		t.Fatalf("GetZone() err=%v", err)
+	}
+	if zone.Zone != "k8s-zone-US-CA1" || zone.Region != "k8s-region-US" || zone.Icenter == "" || zone.Datacenter == "" {
+		t.Errorf("unexpected zone %v", zone)
+	}
+
+	var zones []*pb.Zone
+	if err := nm.ExportZones(ctx, &zones); err != nil {
+		t.Fatalf("ExportZones() err=%v", err)
+	}
+	if len(zones) != 1 || zones[0].Zone != "k8s-zone-US-CA1" {
+		t.Errorf("unexpected zones %v", zones)
+	}
+
+	var nodes []*pb.Node
+	if err := nm.ExportNodesByZone(ctx, "k8s-zone-US-CA1", "k8s-region-US", &nodes); err != nil {
+		t.Fatalf("ExportNodesByZone() err=%v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Uuid != uuid {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+
+	nodes = nil
+	if err := nm.ExportNodesByZone(ctx, "k8s-zone-US-CA2", "k8s-region-US", &nodes); err != nil {
+		t.Fatalf("ExportNodesByZone() err=%v", err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("unexpected nodes in another zone %v", nodes)
+	}
+
+	// The zone of the host is cached until it expires.
+	if err := model.DetachTag(zoneID, vm.HostID); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.GetZone(ctx, uuid, zone); err != nil || zone.Zone != "k8s-zone-US-CA1" {
+		t.Errorf("GetZone() = %v, %v, expected the cached zone", zone, err)
+	}
+	for _, cached := range nm.hostZones {
+		cached.expires = time.Now()
+	}
+	if err := nm.GetZone(ctx, uuid, &pb.Zone{}); err == nil {
+		t.Error("GetZone() should look up the zone again once the cache expired")
+	}
+}
@@ -22,15 +22,18 @@ import (
 	v1 "k8s.io/api/core/v1"
 	cloudprovider "k8s.io/cloud-provider"
 
+	"github.com/inspur-ics/cloud-provider-ics/pkg/cloudprovider/ics/loadbalancer"
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
 	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
 	k8s "github.com/inspur-ics/cloud-provider-ics/pkg/common/kubernetes"
 )
 
-// GRPCServer describes an object that can start a gRPC server.
+// GRPCServer describes an object that can start a gRPC server and report
+// whether it is serving.
 type GRPCServer interface {
 	Start()
+	SetServing(serving bool)
 }
 
 // CPIConfig is used to read and store information (related only to the CPI) from the cloud configuration file
@@ -49,6 +52,36 @@ type CPIConfig struct {
 		InternalVMNetworkName string `gcfg:"internal-vm-network-name"`
 		ExternalVMNetworkName string `gcfg:"external-vm-network-name"`
 	}
+
+	// Load balancers of Services of type LoadBalancer
+	LoadBalancer loadbalancer.Config
+
+	// Node labels synced from VM tags
+	NodeLabels struct {
+		// Prefix of the label keys. Default: ics.inspur.com/
+		Prefix string `gcfg:"prefix"`
+		// Tag categories synced to node labels, either "category" or
+		// "category:label-name". May be repeated. Labels are disabled if none
+		// is set.
+		Categories []string `gcfg:"category"`
+	}
+
+	// Node taints while their host is in maintenance
+	Maintenance struct {
+		// Effect of the taint, NoSchedule or NoExecute. Disabled if empty.
+		TaintEffect string `gcfg:"taint-effect"`
+		// Host states that are maintenance, may be repeated. Required with
+		// taint-effect.
+		HostStates []string `gcfg:"host-state"`
+		// Also treat the hosts of clusters whose HA was disabled as in
+		// maintenance.
+		HARequired bool `gcfg:"ha-required"`
+		// Cordon the nodes while their host is in maintenance.
+		Cordon bool `gcfg:"cordon"`
+		// Evict the pods of the nodes while their host is in maintenance.
+		// Implies cordon.
+		Drain bool `gcfg:"drain"`
+	}
 }
 
 // ICS is an implementation of cloud provider Interface for ICS.
@@ -59,6 +92,9 @@ type ICS struct {
 	informMgr         *k8s.InformerManager
 	instances         cloudprovider.Instances
 	zones             cloudprovider.Zones
+	loadBalancer      *loadbalancer.LoadBalancer
+	nodeLabeler       *nodeLabeler
+	maintenance       *maintenanceController
 	server            GRPCServer
 }
 
@@ -98,6 +134,10 @@ type NodeManager struct {
 	nodeRegUUIDMap map[string]*v1.Node
 	// ConnectionManager
 	connectionManager *cm.ConnectionManager
+	// Changes of the nodes exported by the API
+	nodeEvents *nodeEvents
+	// Maps tenantRef/hostID to the cached zone of the host.
+	hostZones map[string]*hostZone
 
 	// Reference to CPI-specific configuration
 	cpiCfg *CPIConfig
@@ -105,6 +145,7 @@ type NodeManager struct {
 	// Mutexes
 	nodeInfoLock    sync.RWMutex
 	nodeRegInfoLock sync.RWMutex
+	hostZonesLock   sync.Mutex
 }
 
 type instances struct {
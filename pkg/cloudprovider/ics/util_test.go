@@ -69,19 +69,3 @@ func TestUUIDConvertInvalid(t *testing.T) {
 		t.Errorf("Should return empty string")
 	}
 }
-
-func TestUUIDConvert(t *testing.T) {
-	k8sUUID := "56492e42-22ad-3911-6d72-59cc8f26bc90"
-
-	if k8sUUID != "422e4956-ad22-1139-6d72-59cc8f26bc90" {
-		t.Errorf("Failed to translate UUID")
-	}
-}
-
-func TestUpperUUIDConvert(t *testing.T) {
-	k8sUUID := strings.ToUpper("422e4956-ad22-1139-6d72-59cc8f26bc90")
-
-	if k8sUUID != "56492e42-22ad-3911-6d72-59cc8f26bc90" {
-		t.Errorf("Failed to translate UUID")
-	}
-}
@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+	k8s "github.com/inspur-ics/cloud-provider-ics/pkg/common/kubernetes"
+)
+
+// SecretsSyncTimeout is how long the validation of the cloud config waits for
+// the secrets holding the credentials to be listed.
+const SecretsSyncTimeout = 30 * time.Second
+
+// ErrVMNetworkNotFound is returned when iCenter has no network of the
+// configured internal or external VM network name.
+var ErrVMNetworkNotFound = errors.New("VM network not found")
+
+// ValidationResult is the outcome of validating the cloud config against an
+// iCenter.
+type ValidationResult struct {
+	cm.VerifyResult
+	// The internal and external VM networks iCenter has, and those it does
+	// not.
+	Networks        []string
+	MissingNetworks []string
+}
+
+// ValidationReport is the outcome of validating the cloud config against
+// every iCenter, sorted by tenantRef.
+type ValidationReport []ValidationResult
+
+// Err returns the errors of every iCenter that failed validation, nil if
+// none did.
+func (report ValidationReport) Err() error {
+	var errs []error
+	for _, result := range report {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("iCenter %s: %v", result.TenantRef, result.Err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Print writes the report as a PASS or FAIL line per iCenter, followed by the
+// details of the checks.
+func (report ValidationReport) Print(out io.Writer) {
+	failed := 0
+	for _, result := range report {
+		if result.Err == nil {
+			fmt.Fprintf(out, "PASS  iCenter %s on %s\n", result.TenantRef, result.Endpoint)
+		} else {
+			failed++
+			fmt.Fprintf(out, "FAIL  iCenter %s on %s: %v\n", result.TenantRef, result.Endpoint, result.Err)
+		}
+		printList(out, "datacenters", result.Datacenters)
+		printList(out, "missing datacenters", result.MissingDatacenters)
+		printList(out, "tag categories", result.TagCategories)
+		printList(out, "missing tag categories", result.MissingTagCategories)
+		printList(out, "networks", result.Networks)
+		printList(out, "missing networks", result.MissingNetworks)
+	}
+	fmt.Fprintf(out, "%d of %d iCenters failed validation\n", failed, len(report))
+}
+
+func printList(out io.Writer, name string, values []string) {
+	if len(values) > 0 {
+		fmt.Fprintf(out, "      %s: %s\n", name, strings.Join(values, ", "))
+	}
+}
+
+// ValidateConfig validates the cloud config file against every iCenter it
+// configures: it resolves the credentials, from the config, a secret or the
+// secrets directory, connects, and checks that the datacenters, the zone and
+// region tag categories and the internal and external VM networks exist. The
+// report is written to out, and the errors returned.
+func ValidateConfig(ctx context.Context, file string, out io.Writer) error {
+	cfg, err := readCPIConfigFile(file)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  cloud config %s: %v\n", file, err)
+		return err
+	}
+
+	connMgr, err := newOfflineConnectionManager(cfg)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  secrets of the cloud config %s: %v\n", file, err)
+		return err
+	}
+	defer connMgr.Logout()
+
+	report := validate(ctx, cfg, connMgr)
+	report.Print(out)
+	return report.Err()
+}
+
+func readCPIConfigFile(file string) (*CPIConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCPIConfig(f)
+}
+
+// newOfflineConnectionManager returns a ConnectionManager of cfg for the
+// commands run outside of the cloud controller manager, with the secret
+// listers synced if credentials are held in secrets.
+func newOfflineConnectionManager(cfg *CPIConfig) (*cm.ConnectionManager, error) {
+	if !needsSecretLister(cfg) {
+		return cm.NewConnectionManager(&cfg.Config, nil, nil), nil
+	}
+
+	client, err := k8s.NewClient(ClientName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kubernetes client: %v", err)
+	}
+	informMgr := k8s.NewInformer(client, true)
+	informMgr.GetSecretLister()
+
+	// The global credentials come from the secrets directory when it is set.
+	globalInformMgr := informMgr
+	if cfg.Global.SecretsDirectory != "" {
+		globalInformMgr = nil
+	}
+	connMgr := cm.NewConnectionManager(&cfg.Config, globalInformMgr, client)
+	connMgr.InitializeSecretLister()
+	if !informMgr.WaitForCacheSync(SecretsSyncTimeout) {
+		return nil, fmt.Errorf("timed out listing the secrets after %v", SecretsSyncTimeout)
+	}
+	return connMgr, nil
+}
+
+// needsSecretLister returns true if the credentials of an iCenter are held in
+// a Kubernetes secret.
+func needsSecretLister(cfg *CPIConfig) bool {
+	for _, icsConfig := range cfg.ICSCenter {
+		if icsConfig.User != "" && icsConfig.Password != "" {
+			continue
+		}
+		if icsConfig.SecretRef != icfg.DefaultCredentialManager || cfg.Global.SecretsDirectory == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validate verifies every iCenter of connMgr, then checks its VM networks.
+func validate(ctx context.Context, cfg *CPIConfig, connMgr *cm.ConnectionManager) ValidationReport {
+	credentialErrs := connMgr.ResolveCredentials()
+	verifyReport := connMgr.VerifyAll(ctx, cfg.Labels.Zone, cfg.Labels.Region)
+	instances := connMgr.Instances()
+
+	report := make(ValidationReport, 0, len(verifyReport))
+	for _, verifyResult := range verifyReport {
+		result := ValidationResult{VerifyResult: verifyResult}
+		if err := credentialErrs[result.TenantRef]; err != nil && result.Reachable && !result.CredentialsValid {
+			result.Err = fmt.Errorf("failed to get the credentials: %v", err)
+		}
+		if result.Err == nil {
+			result.Err = validateVMNetworks(ctx, instances[result.TenantRef], cfg, &result)
+		}
+		report = append(report, result)
+	}
+	return report
+}
+
+// validateVMNetworks checks that iCenter has networks of the internal and
+// external VM network names, if set.
+func validateVMNetworks(ctx context.Context, icsInstance *cm.ICSInstance, cfg *CPIConfig,
+	result *ValidationResult) error {
+	names := []string{cfg.Nodes.InternalVMNetworkName, cfg.Nodes.ExternalVMNetworkName}
+	if names[0] == "" && names[1] == "" {
+		return nil
+	}
+
+	networks, err := icslib.GetAllNetworks(ctx, icsInstance)
+	if err != nil {
+		return err
+	}
+	for i, name := range names {
+		if name == "" || (i == 1 && strings.EqualFold(name, names[0])) {
+			continue
+		}
+		found := false
+		for _, network := range networks {
+			if strings.EqualFold(network.Name, name) {
+				found = true
+				break
+			}
+		}
+		if found {
+			result.Networks = append(result.Networks, name)
+		} else {
+			result.MissingNetworks = append(result.MissingNetworks, name)
+		}
+	}
+	if len(result.MissingNetworks) > 0 {
+		return ErrVMNetworkNotFound
+	}
+	return nil
+}
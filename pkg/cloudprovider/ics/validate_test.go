@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icssim"
+)
+
+func TestValidateConfig(t *testing.T) {
+	simCfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+	model.AddTag(types.Tag{Name: "zone-a", Description: simCfg.Labels.Zone})
+	model.AddTag(types.Tag{Name: "region-a", Description: simCfg.Labels.Region})
+
+	dir, err := ioutil.TempDir("", "ics-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretsDir := filepath.Join(dir, "secrets")
+	if err := os.Mkdir(secretsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	emptyDir := filepath.Join(dir, "empty")
+	if err := os.Mkdir(emptyDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	host := simCfg.Global.ICenterIP
+	for key, value := range map[string]string{".username": simCfg.Global.User, ".password": simCfg.Global.Password} {
+		if err := ioutil.WriteFile(filepath.Join(secretsDir, host+key), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	credentials := fmt.Sprintf("user = %s\npassword = %s", simCfg.Global.User, simCfg.Global.Password)
+	tests := []struct {
+		name        string
+		global      string
+		icenter     string
+		nodes       string
+		expectErr   bool
+		expectLines []string
+	}{
+		{
+			name:        "valid",
+			global:      credentials,
+			icenter:     "datacenters = DC0",
+			expectLines: []string{"PASS  iCenter " + host, "datacenters: DC0", "tag categories: k8s-zone, k8s-region", "networks: " + icssim.DefaultNetworkName},
+		},
+		{
+			name:        "credentials from the secrets directory",
+			global:      "secrets-directory = " + secretsDir,
+			icenter:     "datacenters = DC0",
+			expectLines: []string{"PASS  iCenter " + host, "0 of 1 iCenters failed validation"},
+		},
+		{
+			name:        "credentials not found",
+			global:      "secrets-directory = " + emptyDir,
+			icenter:     "datacenters = DC0",
+			expectErr:   true,
+			expectLines: []string{"FAIL  iCenter " + host, "failed to get the credentials"},
+		},
+		{
+			name:        "missing datacenter",
+			global:      credentials,
+			icenter:     "datacenters = DC0,DC9",
+			expectErr:   true,
+			expectLines: []string{"FAIL  iCenter " + host, "missing datacenters: DC9", "1 of 1 iCenters failed validation"},
+		},
+		{
+			name:        "missing network",
+			global:      credentials,
+			icenter:     "datacenters = DC0",
+			nodes:       "external-vm-network-name = Public",
+			expectErr:   true,
+			expectLines: []string{"FAIL  iCenter " + host, "networks: " + icssim.DefaultNetworkName, "missing networks: Public"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fmt.Sprintf(`
+[Global]
+insecure-flag = true
+%s
+
+[ICSCenter "%s"]
+port = %s
+%s
+
+[Labels]
+zone = k8s-zone
+region = k8s-region
+
+[Nodes]
+internal-vm-network-name = %s
+%s
+`, test.global, host, simCfg.Global.ICenterPort, test.icenter, icssim.DefaultNetworkName, test.nodes)
+			file := filepath.Join(dir, "ics.conf")
+			if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			err := ValidateConfig(context.Background(), file, &out)
+			if test.expectErr != (err != nil) {
+				t.Errorf("expected error %t, got %v\n%s", test.expectErr, err, out.String())
+			}
+			for _, line := range test.expectLines {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("expected %q in the report:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := ValidateConfig(context.Background(), filepath.Join(dir, "missing.conf"), &out); err == nil {
+		t.Error("expected an error for a missing cloud config")
+	}
+	if !strings.HasPrefix(out.String(), "FAIL  cloud config") {
+		t.Errorf("expected the cloud config to fail, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+)
+
+const (
+	// NodeRefreshInterval is how often the VMs backing the nodes are read
+	// again to refresh the cached NodeInfo.
+	NodeRefreshInterval = 30 * time.Second
+)
+
+// vmWatcher lists the VMs of every iCenter hosting cached nodes, refreshing
+// the VM inventory, and refreshes the NodeInfo from it: power changes,
+// migrations, reconfigurations, IP changes and deletions. iCenter has no feed
+// of the changes to its VMs.
+type vmWatcher struct {
+	nodeManager *NodeManager
+}
+
+func newVMWatcher(nm *NodeManager) *vmWatcher {
+	return &vmWatcher{nodeManager: nm}
+}
+
+// Run polls the VMs of the nodes every NodeRefreshInterval until stopCh is
+// closed. It does not block.
+func (w *vmWatcher) Run(stopCh <-chan struct{}) {
+	go wait.Until(func() {
+		w.poll(context.Background())
+	}, NodeRefreshInterval, stopCh)
+}
+
+// poll refreshes the cached nodes of every iCenter whose VMs can be listed,
+// one paged list per datacenter. The nodes of the others are left as they
+// are until the next poll.
+func (w *vmWatcher) poll(ctx context.Context) {
+	nm := w.nodeManager
+	connMgr := nm.connectionManager
+
+	nodesByTenant := make(map[string][]*NodeInfo)
+	nm.nodeInfoLock.RLock()
+	for _, node := range nm.nodeUUIDMap {
+		if node.vm != nil {
+			nodesByTenant[node.tenantRef] = append(nodesByTenant[node.tenantRef], node)
+		}
+	}
+	nm.nodeInfoLock.RUnlock()
+
+	for tenantRef, nodes := range nodesByTenant {
+		vms, err := connMgr.RefreshTenantInventory(ctx, tenantRef)
+		if err != nil {
+			klog.Errorf("VM watcher failed to list the VMs of ics=%s: %v", tenantRef, err)
+			continue
+		}
+		vmsByID := make(map[string]*cm.VMDiscoveryInfo, len(vms))
+		for _, vm := range vms {
+			vmsByID[vm.VM.ID] = vm
+		}
+		for _, node := range nodes {
+			nm.refreshNode(node, vmsByID[node.vm.ID])
+		}
+	}
+}
+
+// refreshNode updates the cached NodeInfo from the listed VM of the node, nil
+// if its iCenter no longer has it. Such a node is dropped from the caches and
+// rediscovered in case it migrated to another iCenter. A node removed or
+// replaced while the VMs were listed is left alone.
+func (nm *NodeManager) refreshNode(nodeInfo *NodeInfo, vmInfo *cm.VMDiscoveryInfo) {
+	if vmInfo == nil {
+		klog.Infof("VM of node %s is gone from ics=%s", nodeInfo.NodeName, nodeInfo.icsServer)
+		if !nm.removeNodeInfo(nodeInfo) {
+			return
+		}
+		if err := nm.DiscoverNode(nodeInfo.UUID, cm.FindVMByUUID); err != nil {
+			klog.V(2).Infof("Node %s no longer has a VM: %v", nodeInfo.NodeName, err)
+		}
+		return
+	}
+
+	updated, err := nm.newNodeInfo(&cm.VMDiscoveryInfo{
+		TenantRef:  nodeInfo.tenantRef,
+		DataCenter: vmInfo.DataCenter,
+		VM:         vmInfo.VM,
+		IcsServer:  vmInfo.IcsServer,
+		UUID:       nodeInfo.UUID,
+		NodeName:   nodeInfo.NodeName,
+	}, nil)
+	if err != nil {
+		klog.Errorf("Failed to refresh node %s from vm=%s: %v", nodeInfo.NodeName, vmInfo.VM.ID, err)
+		return
+	}
+
+	if !nm.replaceNodeInfo(nodeInfo, updated) {
+		klog.V(4).Infof("Node %s changed while its VM was listed, not refreshed", nodeInfo.NodeName)
+		return
+	}
+	klog.V(4).Infof("Refreshed node %s from vm=%s", updated.NodeName, vmInfo.VM.ID)
+}
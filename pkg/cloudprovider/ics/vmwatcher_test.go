@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	v1 "k8s.io/api/core/v1"
+
+	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/connectionmanager"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+func TestVMWatcher(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	nm := newNodeManager(nil, connMgr)
+	vms := model.VMs()
+	node, other := vms[0], vms[1]
+
+	if err := nm.DiscoverNode(node.UUID, cm.FindVMByUUID); err != nil {
+		t.Fatalf("DiscoverNode err=%v", err)
+	}
+
+	w := newVMWatcher(nm)
+
+	// VMs that are not nodes are ignored.
+	if err := model.UpdateVM(other.ID, func(vm *types.VirtualMachine) {
+		vm.Nics[0].IP = "10.99.0.2"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// An IP change is picked up by the next poll.
+	if err := model.UpdateVM(node.ID, func(vm *types.VirtualMachine) {
+		vm.Nics[0].IP = "10.99.0.1"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w.poll(ctx)
+
+	if len(nm.nodeUUIDMap) != 1 {
+		t.Fatalf("nodeUUIDMap should be a length of 1, got %d", len(nm.nodeUUIDMap))
+	}
+	nodeInfo := nm.nodeNameMap[node.Name]
+	if nodeInfo == nil {
+		t.Fatalf("node %s missing from nodeNameMap", node.Name)
+	}
+	if nm.nodeUUIDMap[node.UUID] != nodeInfo {
+		t.Errorf("nodeUUIDMap and nodeNameMap disagree")
+	}
+	found := false
+	for _, address := range nodeInfo.NodeAddresses {
+		if address.Type == v1.NodeInternalIP && address.Address == "10.99.0.1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("NodeAddresses were not refreshed: %v", nodeInfo.NodeAddresses)
+	}
+	dc, err := nm.FindDatacenterInfoInICSList(nodeInfo.icsServer, nodeInfo.dataCenter.Name)
+	if err != nil {
+		t.Fatalf("FindDatacenterInfoInICSList err=%v", err)
+	}
+	if dc.vmList[node.UUID] != nodeInfo {
+		t.Errorf("ICS -> DC -> VM tree was not refreshed")
+	}
+
+	// A deleted VM is dropped from the caches.
+	if err := model.RemoveVM(node.ID); err != nil {
+		t.Fatal(err)
+	}
+	w.poll(ctx)
+
+	if len(nm.nodeUUIDMap) != 0 || len(nm.nodeNameMap) != 0 {
+		t.Errorf("deleted VM still cached: %v %v", nm.nodeUUIDMap, nm.nodeNameMap)
+	}
+	if len(dc.vmList) != 0 {
+		t.Errorf("deleted VM still in the ICS -> DC -> VM tree")
+	}
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, node.UUID, cm.FindVMByUUID); err != icslib.ErrNoVMFound {
+		t.Errorf("deleted VM still in the VM inventory, err=%v", err)
+	}
+}
+
+func TestVMWatcherRemovedDuringPoll(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := cm.NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	nm := newNodeManager(nil, connMgr)
+	node := model.VMs()[0]
+	if err := nm.DiscoverNode(node.UUID, cm.FindVMByUUID); err != nil {
+		t.Fatalf("DiscoverNode err=%v", err)
+	}
+	nodeInfo := nm.nodeUUIDMap[node.UUID]
+
+	vms, err := connMgr.RefreshTenantInventory(ctx, nodeInfo.tenantRef)
+	if err != nil {
+		t.Fatalf("RefreshTenantInventory err=%v", err)
+	}
+	var vmInfo *cm.VMDiscoveryInfo
+	for _, vm := range vms {
+		if vm.VM.ID == node.ID {
+			vmInfo = vm
+		}
+	}
+	if vmInfo == nil {
+		t.Fatalf("VM %s not listed", node.ID)
+	}
+
+	// The node is unregistered while its VM is listed.
+	nm.removeNodeInfo(nodeInfo)
+	nm.refreshNode(nodeInfo, vmInfo)
+
+	if len(nm.nodeUUIDMap) != 0 || len(nm.nodeNameMap) != 0 {
+		t.Errorf("removed node cached again: %v %v", nm.nodeUUIDMap, nm.nodeNameMap)
+	}
+}
@@ -17,16 +17,18 @@ limitations under the License.
 package config
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 
 	"k8s.io/klog"
-
-	"gopkg.in/gcfg.v1"
-
 )
 
 func getEnvKeyValue(match string, partial bool) (string, string, error) {
@@ -99,6 +101,22 @@ func (cfg *Config) FromEnv() error {
 		}
 	}
 
+	if env := os.Getenv("ICS_CA_FILE"); env != "" {
+		cfg.Global.CAFile = env
+	}
+	if env := os.Getenv("ICS_CA_DATA"); env != "" {
+		cfg.Global.CAData = env
+	}
+	if env := os.Getenv("ICS_THUMBPRINT"); env != "" {
+		cfg.Global.Thumbprint = env
+	}
+	if env := os.Getenv("ICS_PROXY_URL"); env != "" {
+		cfg.Global.ProxyURL = env
+	}
+	if env := os.Getenv("ICS_NO_PROXY"); env != "" {
+		cfg.Global.NoProxy = env
+	}
+
 	if env := os.Getenv("ICS_API_DISABLE"); env != "" {
 		APIDisable, err := strconv.ParseBool(env)
 		if err != nil {
@@ -111,6 +129,40 @@ func (cfg *Config) FromEnv() error {
 	if env := os.Getenv("ICS_API_BINDING"); env != "" {
 		cfg.Global.APIBinding = env
 	}
+	if env := os.Getenv("ICS_API_TLS_CERT_FILE"); env != "" {
+		cfg.Global.APITLSCertFile = env
+	}
+	if env := os.Getenv("ICS_API_TLS_KEY_FILE"); env != "" {
+		cfg.Global.APITLSKeyFile = env
+	}
+	if env := os.Getenv("ICS_API_CLIENT_CA_FILE"); env != "" {
+		cfg.Global.APIClientCAFile = env
+	}
+	if env := os.Getenv("ICS_API_TOKEN_FILE"); env != "" {
+		cfg.Global.APITokenFile = env
+	}
+
+	if env := os.Getenv("ICS_API_DISABLE_REFLECTION"); env != "" {
+		APIDisableReflection, err := strconv.ParseBool(env)
+		if err != nil {
+			klog.Errorf("Failed to parse ICS_API_DISABLE_REFLECTION: %s", err)
+		} else {
+			cfg.Global.APIDisableReflection = APIDisableReflection
+		}
+	}
+
+	if env := os.Getenv("ICS_HEALTH_BINDING"); env != "" {
+		cfg.Global.HealthBinding = env
+	}
+
+	if env := os.Getenv("ICS_VERIFY_FAIL_FAST"); env != "" {
+		VerifyFailFast, err := strconv.ParseBool(env)
+		if err != nil {
+			klog.Errorf("Failed to parse ICS_VERIFY_FAIL_FAST: %s", err)
+		} else {
+			cfg.Global.VerifyFailFast = VerifyFailFast
+		}
+	}
 
 	if env := os.Getenv("ICS_SECRETS_DIRECTORY"); env != "" {
 		cfg.Global.SecretsDirectory = env
@@ -166,6 +218,10 @@ func (cfg *Config) FromEnv() error {
 			if errPort != nil {
 				port = cfg.Global.ICenterPort
 			}
+			_, endpoints, errEndpoints := getEnvKeyValue("ICENTER_"+id+"_ENDPOINTS", false)
+			if errEndpoints != nil {
+				endpoints = ""
+			}
 			insecureFlag := false
 			_, insecureTmp, errInsecure := getEnvKeyValue("ICENTER_"+id+"_INSECURE", false)
 			if errInsecure != nil {
@@ -174,6 +230,26 @@ func (cfg *Config) FromEnv() error {
 					insecureFlag = insecureFlagTmp
 				}
 			}
+			_, caFile, errCAFile := getEnvKeyValue("ICENTER_"+id+"_CA_FILE", false)
+			if errCAFile != nil {
+				caFile = ""
+			}
+			_, caData, errCAData := getEnvKeyValue("ICENTER_"+id+"_CA_DATA", false)
+			if errCAData != nil {
+				caData = ""
+			}
+			_, thumbprint, errThumbprint := getEnvKeyValue("ICENTER_"+id+"_THUMBPRINT", false)
+			if errThumbprint != nil {
+				thumbprint = ""
+			}
+			_, proxyURL, errProxyURL := getEnvKeyValue("ICENTER_"+id+"_PROXY_URL", false)
+			if errProxyURL != nil {
+				proxyURL = ""
+			}
+			_, noProxy, errNoProxy := getEnvKeyValue("ICENTER_"+id+"_NO_PROXY", false)
+			if errNoProxy != nil {
+				noProxy = ""
+			}
 			_, datacenters, errDatacenters := getEnvKeyValue("ICENTER_"+id+"_DATACENTERS", false)
 			if errDatacenters != nil {
 				datacenters = cfg.Global.Datacenters
@@ -209,7 +285,13 @@ func (cfg *Config) FromEnv() error {
 				TenantRef:         tenantRef,
 				ICenterIP:         icenterIP,
 				ICenterPort:       port,
+				Endpoints:         endpoints,
 				InsecureFlag:      insecureFlag,
+				CAFile:            caFile,
+				CAData:            caData,
+				Thumbprint:        thumbprint,
+				ProxyURL:          proxyURL,
+				NoProxy:           noProxy,
 				Datacenters:       datacenters,
 				SecretRef:         secretRef,
 				SecretName:        secretName,
@@ -227,6 +309,11 @@ func (cfg *Config) FromEnv() error {
 			ICenterIP:         cfg.Global.ICenterIP,
 			ICenterPort:       cfg.Global.ICenterPort,
 			InsecureFlag:      cfg.Global.InsecureFlag,
+			CAFile:            cfg.Global.CAFile,
+			CAData:            cfg.Global.CAData,
+			Thumbprint:        cfg.Global.Thumbprint,
+			ProxyURL:          cfg.Global.ProxyURL,
+			NoProxy:           cfg.Global.NoProxy,
 			Datacenters:       cfg.Global.Datacenters,
 			SecretRef:         DefaultCredentialManager,
 			SecretName:        cfg.Global.SecretName,
@@ -272,6 +359,64 @@ func validateIPFamily(value string) ([]string, error) {
 	return ipFamilies, nil
 }
 
+// parseEndpoints parses a comma separated HOST[:PORT] list, defaulting the
+// ports to port.
+func parseEndpoints(value string, port string) ([]ICSCenterEndpoint, error) {
+	var endpoints []ICSCenterEndpoint
+	for _, endpoint := range strings.Split(value, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" {
+			continue
+		}
+		host, endpointPort, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			// No port, or an IPv6 address without brackets
+			host, endpointPort = strings.Trim(endpoint, "[]"), port
+		}
+		if endpointPort == "" {
+			endpointPort = port
+		}
+		if host == "" {
+			return nil, ErrInvalidICenterEndpoint
+		}
+		endpoints = append(endpoints, ICSCenterEndpoint{Host: host, Port: endpointPort})
+	}
+	return endpoints, nil
+}
+
+// parseThumbprint normalizes a SHA-1 or SHA-256 certificate fingerprint in
+// hex, with or without colons, to upper case hex without colons.
+func parseThumbprint(value string) (string, error) {
+	thumbprint := strings.ToUpper(strings.Replace(strings.TrimSpace(value), ":", "", -1))
+	if thumbprint == "" {
+		return "", nil
+	}
+	if _, err := hex.DecodeString(thumbprint); err != nil {
+		return "", ErrInvalidThumbprint
+	}
+	if len(thumbprint) != 2*sha1.Size && len(thumbprint) != 2*sha256.Size {
+		return "", ErrInvalidThumbprint
+	}
+	return thumbprint, nil
+}
+
+// validateProxyURL checks that a proxy URL, if set, is an absolute http,
+// https or socks5 URL.
+func validateProxyURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return ErrInvalidProxyURL
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return ErrInvalidProxyURL
+}
+
 func (cfg *Config) validateConfig() error {
 	//Fix default global values
 	if cfg.Global.ICenterPort == "" {
@@ -280,6 +425,9 @@ func (cfg *Config) validateConfig() error {
 	if cfg.Global.APIBinding == "" {
 		cfg.Global.APIBinding = DefaultAPIBinding
 	}
+	if cfg.Global.HealthBinding == "" {
+		cfg.Global.HealthBinding = DefaultHealthBinding
+	}
 	if cfg.Global.IPFamily == "" {
 		cfg.Global.IPFamily = DefaultIPFamily
 	}
@@ -290,6 +438,19 @@ func (cfg *Config) validateConfig() error {
 		return err
 	}
 
+	if (cfg.Global.APITLSCertFile == "") != (cfg.Global.APITLSKeyFile == "") {
+		klog.Error(ErrAPITLSKeyPairIncomplete)
+		return ErrAPITLSKeyPairIncomplete
+	}
+	if cfg.Global.APIClientCAFile != "" && cfg.Global.APITLSCertFile == "" {
+		klog.Error(ErrAPIClientCAWithoutTLS)
+		return ErrAPIClientCAWithoutTLS
+	}
+	if cfg.Global.APITokenFile != "" && cfg.Global.APITLSCertFile == "" {
+		klog.Error(ErrAPITokenWithoutTLS)
+		return ErrAPITokenWithoutTLS
+	}
+
 	// Create a single instance of ICSInstance for the Global ICenterIP if the
 	// ICSCenter does not already exist in the map
 	if cfg.Global.ICenterIP != "" && cfg.ICSCenter[cfg.Global.ICenterIP] == nil {
@@ -300,6 +461,11 @@ func (cfg *Config) validateConfig() error {
 			ICenterIP:         cfg.Global.ICenterIP,
 			ICenterPort:       cfg.Global.ICenterPort,
 			InsecureFlag:      cfg.Global.InsecureFlag,
+			CAFile:            cfg.Global.CAFile,
+			CAData:            cfg.Global.CAData,
+			Thumbprint:        cfg.Global.Thumbprint,
+			ProxyURL:          cfg.Global.ProxyURL,
+			NoProxy:           cfg.Global.NoProxy,
 			Datacenters:       cfg.Global.Datacenters,
 			SecretRef:         DefaultCredentialManager,
 			SecretName:        cfg.Global.SecretName,
@@ -324,6 +490,20 @@ func (cfg *Config) validateConfig() error {
 			return ErrInvalidICenterIP
 		}
 
+		if icsConfig.ICenterPort == "" {
+			icsConfig.ICenterPort = cfg.Global.ICenterPort
+		}
+
+		endpoints, err := parseEndpoints(icsConfig.Endpoints, icsConfig.ICenterPort)
+		if err != nil {
+			klog.Errorf("Invalid endpoints %q for ics %s", icsConfig.Endpoints, icsServer)
+			return err
+		}
+		if len(endpoints) > 0 && icsConfig.ICenterIP == "" {
+			icsConfig.ICenterIP = endpoints[0].Host
+			icsConfig.ICenterPort = endpoints[0].Port
+		}
+
 		// If icsConfig.ICenterIP is explicitly set, that means the icsServer
 		// above is the TenantRef
 		if icsConfig.ICenterIP != "" {
@@ -355,9 +535,10 @@ func (cfg *Config) validateConfig() error {
 			icsConfig.SecretRef = icsConfig.SecretNamespace + "/" + icsConfig.SecretName
 		}
 
-		if icsConfig.ICenterPort == "" {
-			icsConfig.ICenterPort = cfg.Global.ICenterPort
+		if len(endpoints) == 0 {
+			endpoints = []ICSCenterEndpoint{{Host: icsConfig.ICenterIP, Port: icsConfig.ICenterPort}}
 		}
+		icsConfig.ICenterEndpoints = endpoints
 
 		if icsConfig.Datacenters == "" {
 			if cfg.Global.Datacenters != "" {
@@ -380,12 +561,38 @@ func (cfg *Config) validateConfig() error {
 		if !insecure {
 			icsConfig.InsecureFlag = cfg.Global.InsecureFlag
 		}
+
+		if icsConfig.CAFile == "" && icsConfig.CAData == "" {
+			icsConfig.CAFile = cfg.Global.CAFile
+			icsConfig.CAData = cfg.Global.CAData
+		}
+		if icsConfig.Thumbprint == "" {
+			icsConfig.Thumbprint = cfg.Global.Thumbprint
+		}
+		thumbprint, err := parseThumbprint(icsConfig.Thumbprint)
+		if err != nil {
+			klog.Errorf("Invalid thumbprint %q for ics %s", icsConfig.Thumbprint, icsServer)
+			return err
+		}
+		icsConfig.Thumbprint = thumbprint
+
+		if icsConfig.ProxyURL == "" {
+			icsConfig.ProxyURL = cfg.Global.ProxyURL
+		}
+		if icsConfig.NoProxy == "" {
+			icsConfig.NoProxy = cfg.Global.NoProxy
+		}
+		if err := validateProxyURL(icsConfig.ProxyURL); err != nil {
+			klog.Errorf("Invalid proxy-url %q for ics %s", icsConfig.ProxyURL, icsServer)
+			return err
+		}
 	}
 
 	return nil
 }
 
-// ReadConfig parses inCloud Sphere cloud config file and stores it into ICSConfig.
+// ReadConfig parses inCloud Sphere cloud config file, in gcfg INI, YAML or
+// JSON, and stores it into ICSConfig.
 // Environment variables are also checked
 func ReadConfig(config io.Reader) (*Config, error) {
 	if config == nil {
@@ -393,7 +600,7 @@ func ReadConfig(config io.Reader) (*Config, error) {
 	}
 
 	cfg := &Config{}
-	if err := gcfg.FatalOnly(gcfg.ReadInto(cfg, config)); err != nil {
+	if err := ReadConfigInto(cfg, config); err != nil {
 		return nil, err
 	}
 
@@ -18,6 +18,7 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -218,3 +219,322 @@ func TestTenantRefs(t *testing.T) {
 		t.Errorf("icsConfig3 SecretRef should be kube-system/eu-secret but actual=%s", icsConfig3.SecretRef)
 	}
 }
+
+func TestVerifyFailFast(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(basicConfig))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+	if cfg.Global.VerifyFailFast {
+		t.Error("verify-fail-fast should be disabled by default")
+	}
+
+	cfg, err = ReadConfig(strings.NewReader(basicConfig + `
+verify-fail-fast = true
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+	if !cfg.Global.VerifyFailFast {
+		t.Error("verify-fail-fast should be enabled")
+	}
+}
+
+func TestAPISecurity(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(basicConfig + `
+api-tls-cert-file = /etc/ics/tls.crt
+api-tls-key-file = /etc/ics/tls.key
+api-client-ca-file = /etc/ics/ca.crt
+api-token-file = /etc/ics/token
+api-disable-reflection = true
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+	if cfg.Global.APITLSCertFile != "/etc/ics/tls.crt" || cfg.Global.APITLSKeyFile != "/etc/ics/tls.key" ||
+		cfg.Global.APIClientCAFile != "/etc/ics/ca.crt" || cfg.Global.APITokenFile != "/etc/ics/token" ||
+		!cfg.Global.APIDisableReflection {
+		t.Errorf("incorrect API security options: %+v", cfg.Global)
+	}
+
+	_, err = ReadConfig(strings.NewReader(basicConfig + `
+api-tls-cert-file = /etc/ics/tls.crt
+`))
+	if err != ErrAPITLSKeyPairIncomplete {
+		t.Errorf("expected %v, got %v", ErrAPITLSKeyPairIncomplete, err)
+	}
+
+	_, err = ReadConfig(strings.NewReader(basicConfig + `
+api-client-ca-file = /etc/ics/ca.crt
+`))
+	if err != ErrAPIClientCAWithoutTLS {
+		t.Errorf("expected %v, got %v", ErrAPIClientCAWithoutTLS, err)
+	}
+
+	_, err = ReadConfig(strings.NewReader(basicConfig + `
+api-token-file = /etc/ics/token
+`))
+	if err != ErrAPITokenWithoutTLS {
+		t.Errorf("expected %v, got %v", ErrAPITokenWithoutTLS, err)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+[Global]
+user = user
+password = password
+port = 443
+
+[ICSCenter "tenant1"]
+endpoints = "10.0.0.1, 10.0.0.2:8443, [fd00::3]"
+
+[ICSCenter "10.0.0.4"]
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	icsConfig1 := cfg.ICSCenter["tenant1"]
+	expected := []ICSCenterEndpoint{{"10.0.0.1", "443"}, {"10.0.0.2", "8443"}, {"fd00::3", "443"}}
+	if !reflect.DeepEqual(icsConfig1.ICenterEndpoints, expected) {
+		t.Errorf("tenant1 endpoints %v, expected %v", icsConfig1.ICenterEndpoints, expected)
+	}
+	if icsConfig1.ICenterIP != "10.0.0.1" || icsConfig1.ICenterPort != "443" {
+		t.Errorf("tenant1 server %s:%s, expected the first endpoint", icsConfig1.ICenterIP, icsConfig1.ICenterPort)
+	}
+
+	icsConfig2 := cfg.ICSCenter["10.0.0.4"]
+	expected = []ICSCenterEndpoint{{"10.0.0.4", "443"}}
+	if !reflect.DeepEqual(icsConfig2.ICenterEndpoints, expected) {
+		t.Errorf("10.0.0.4 endpoints %v, expected %v", icsConfig2.ICenterEndpoints, expected)
+	}
+
+	_, err = ReadConfig(strings.NewReader(`
+[Global]
+user = user
+password = password
+
+[ICSCenter "tenant1"]
+endpoints = ":443"
+`))
+	if err != ErrInvalidICenterEndpoint {
+		t.Errorf("expected %v, got %v", ErrInvalidICenterEndpoint, err)
+	}
+}
+
+func TestCertificateOptions(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+[Global]
+user = user
+password = password
+ca-file = /etc/ics/ca.pem
+thumbprint = "ab:cd:ef:01:23:45:67:89:ab:cd:ef:01:23:45:67:89:ab:cd:ef:01"
+
+[ICSCenter "10.0.0.1"]
+
+[ICSCenter "10.0.0.2"]
+ca-data = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	icsConfig1 := cfg.ICSCenter["10.0.0.1"]
+	if icsConfig1.CAFile != "/etc/ics/ca.pem" || icsConfig1.Thumbprint != "ABCDEF0123456789ABCDEF0123456789ABCDEF01" {
+		t.Errorf("10.0.0.1 should use the Global CA file and thumbprint: %+v", icsConfig1)
+	}
+
+	icsConfig2 := cfg.ICSCenter["10.0.0.2"]
+	if icsConfig2.CAFile != "" || !strings.HasPrefix(icsConfig2.CAData, "-----BEGIN CERTIFICATE-----\n") {
+		t.Errorf("10.0.0.2 should use its CA data instead of the Global CA file: %+v", icsConfig2)
+	}
+
+	_, err = ReadConfig(strings.NewReader(basicConfig + `
+thumbprint = "AB:CD"
+`))
+	if err != ErrInvalidThumbprint {
+		t.Errorf("expected %v, got %v", ErrInvalidThumbprint, err)
+	}
+}
+
+func TestProxyOptions(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+[Global]
+user = user
+password = password
+proxy-url = http://proxy.example.com:3128
+no-proxy = .example.com
+
+[ICSCenter "10.0.0.1"]
+
+[ICSCenter "10.0.0.2"]
+proxy-url = socks5://10.0.0.254:1080
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	icsConfig1 := cfg.ICSCenter["10.0.0.1"]
+	if icsConfig1.ProxyURL != "http://proxy.example.com:3128" || icsConfig1.NoProxy != ".example.com" {
+		t.Errorf("10.0.0.1 should use the Global proxy: %+v", icsConfig1)
+	}
+
+	icsConfig2 := cfg.ICSCenter["10.0.0.2"]
+	if icsConfig2.ProxyURL != "socks5://10.0.0.254:1080" || icsConfig2.NoProxy != ".example.com" {
+		t.Errorf("10.0.0.2 should use its proxy and the Global no-proxy: %+v", icsConfig2)
+	}
+
+	for _, proxyURL := range []string{"ftp://proxy", "proxy.example.com:3128"} {
+		_, err = ReadConfig(strings.NewReader(basicConfig + `
+proxy-url = ` + proxyURL + `
+`))
+		if err != ErrInvalidProxyURL {
+			t.Errorf("proxy-url %s: expected %v, got %v", proxyURL, ErrInvalidProxyURL, err)
+		}
+	}
+}
+
+func TestYAMLConfig(t *testing.T) {
+	iniCfg, err := ReadConfig(strings.NewReader(`
+[Global]
+user = user
+password = password
+insecure-flag = true
+port = 443
+datacenters = DC0
+
+[ICSCenter "tenant1"]
+server = 10.0.0.1
+endpoints = "10.0.0.1, 10.0.0.2:8443"
+ca-data = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+
+[ICSCenter "10.0.0.3"]
+user = other
+
+[Labels]
+zone = k8s-zone
+region = k8s-region
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid INI config is provided: %s", err)
+	}
+
+	for name, config := range map[string]string{
+		"YAML": `
+# Comments are allowed
+global:
+  user: user
+  password: password
+  insecure-flag: true
+  port: 443
+  datacenters: DC0
+icsCenter:
+  tenant1:
+    server: 10.0.0.1
+    endpoints: 10.0.0.1, 10.0.0.2:8443
+    ca-data: |-
+      -----BEGIN CERTIFICATE-----
+      MIIB
+      -----END CERTIFICATE-----
+  10.0.0.3:
+    user: other
+labels:
+  zone: k8s-zone
+  region: k8s-region
+`,
+		"JSON": `{
+  "Global": {"user": "user", "password": "password", "insecure-flag": true, "port": "443", "datacenters": "DC0"},
+  "ICSCenter": {
+    "tenant1": {
+      "server": "10.0.0.1",
+      "endpoints": "10.0.0.1, 10.0.0.2:8443",
+      "ca-data": "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+    },
+    "10.0.0.3": {"user": "other"}
+  },
+  "Labels": {"zone": "k8s-zone", "region": "k8s-region"}
+}`,
+	} {
+		cfg, err := ReadConfig(strings.NewReader(config))
+		if err != nil {
+			t.Errorf("%s: Should succeed when a valid config is provided: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg, iniCfg) {
+			t.Errorf("%s: config %+v differs from the INI config %+v", name, cfg, iniCfg)
+		}
+	}
+
+	for name, config := range map[string]string{
+		"not an object":      "global: user",
+		"nested object":      "global:\n  user:\n    name: user",
+		"nested list":        "global:\n  user: [[user]]",
+		"invalid YAML":       "global: {user",
+		"invalid value":      "global:\n  insecure-flag: maybe",
+		"missing subsection": "icsCenter:\n  user: user",
+	} {
+		if _, err := ReadConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("%s: Should fail when an invalid config is provided", name)
+		}
+	}
+}
+
+func TestYAMLConfigScalars(t *testing.T) {
+	// YAML 1.1 reads these as a bool and numbers, they are kept as written.
+	cfg, err := ReadConfig(strings.NewReader(`
+global:
+  user: 012
+  password: yes
+  insecure-flag: yes
+  datacenters: 0123
+icsCenter:
+  10.0.0.1:
+    password: 1e3
+    datacenters: 0x10
+`))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+	if cfg.Global.User != "012" || cfg.Global.Password != "yes" || cfg.Global.Datacenters != "0123" {
+		t.Errorf("Global user=%q password=%q datacenters=%q, expected them as written",
+			cfg.Global.User, cfg.Global.Password, cfg.Global.Datacenters)
+	}
+	if !cfg.Global.InsecureFlag {
+		t.Error("Global insecure-flag yes should be true")
+	}
+	icsConfig := cfg.ICSCenter["10.0.0.1"]
+	if icsConfig == nil || icsConfig.Password != "1e3" || icsConfig.Datacenters != "0x10" {
+		t.Errorf("ICSCenter should keep the values as written: %+v", icsConfig)
+	}
+}
+
+func TestLegacyVirtualCenterSection(t *testing.T) {
+	for name, config := range map[string]string{
+		"INI": `
+[Global]
+user = user
+password = password
+
+[VirtualCenter "10.0.0.1"]
+datacenters = DC0
+`,
+		"YAML": `
+global:
+  user: user
+  password: password
+virtualCenter:
+  10.0.0.1:
+    datacenters: DC0
+`,
+	} {
+		cfg, err := ReadConfig(strings.NewReader(config))
+		if err != nil {
+			t.Errorf("%s: Should succeed when a VirtualCenter section is provided: %s", name, err)
+			continue
+		}
+		if icsConfig := cfg.ICSCenter["10.0.0.1"]; icsConfig == nil || icsConfig.Datacenters != "DC0" {
+			t.Errorf("%s: VirtualCenter section should be read as ICSCenter: %+v", name, cfg.ICSCenter)
+		}
+	}
+}
@@ -29,6 +29,10 @@ const (
 	// exposing the API service.
 	DefaultAPIBinding string = ":43001"
 
+	// DefaultHealthBinding is the default ADDRESS:PORT binding used for
+	// exposing the /healthz and /readyz endpoints.
+	DefaultHealthBinding string = ":43002"
+
 	// DefaultICenterPort is the default port used to access iCenter.
 	DefaultICenterPort string = "443"
 
@@ -58,10 +62,35 @@ var (
 	// missing from the provided configuration.
 	ErrInvalidICenterIP = errors.New("ics.conf does not have the ICSCenter IP address specified")
 
+	// ErrInvalidICenterEndpoint is returned when an endpoint of an iCenter
+	// has no host.
+	ErrInvalidICenterEndpoint = errors.New("ics.conf has an iCenter endpoint without a host")
+
+	// ErrInvalidThumbprint is returned when a certificate thumbprint is not
+	// a SHA-1 or SHA-256 fingerprint in hex.
+	ErrInvalidThumbprint = errors.New("ics.conf has a thumbprint that is not a SHA-1 or SHA-256 fingerprint in hex")
+
+	// ErrInvalidProxyURL is returned when a proxy URL is not an absolute
+	// http, https or socks5 URL.
+	ErrInvalidProxyURL = errors.New("ics.conf has a proxy-url that is not an http, https or socks5 URL")
+
 	// ErrMissingICenter is returned when the provided configuration does not
 	// define any vCenters.
 	ErrMissingICenter = errors.New("No ICS Center hosts defined")
 
 	// ErrInvalidIPFamilyType is returned when an invalid IPFamily type is encountered
 	ErrInvalidIPFamilyType = errors.New("Invalid IP Family type")
+
+	// ErrAPITLSKeyPairIncomplete is returned when only one of the API TLS
+	// certificate and key is provided.
+	ErrAPITLSKeyPairIncomplete = errors.New("api-tls-cert-file and api-tls-key-file must be set together")
+
+	// ErrAPIClientCAWithoutTLS is returned when the API client CA is provided
+	// without the API TLS certificate and key.
+	ErrAPIClientCAWithoutTLS = errors.New("api-client-ca-file requires api-tls-cert-file and api-tls-key-file")
+
+	// ErrAPITokenWithoutTLS is returned when the API token file is provided
+	// without the API TLS certificate and key, which would send the token in
+	// plaintext.
+	ErrAPITokenWithoutTLS = errors.New("api-token-file requires api-tls-cert-file and api-tls-key-file")
 )
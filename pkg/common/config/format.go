@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/yaml.v2"
+	"k8s.io/klog"
+)
+
+// legacySection matches the headers of the vSphere style VirtualCenter
+// sections, gcfg section names being case insensitive.
+var legacySection = regexp.MustCompile(`(?im)^(\s*\[\s*)virtualcenter\b`)
+
+// ReadConfigInto reads the cloud config, in gcfg INI or in YAML or JSON, into
+// config, a struct with gcfg tags. The format is sniffed from the content.
+// YAML and JSON configs have a key per section, e.g. global or icsCenter,
+// holding the gcfg variables, and subsections as nested objects:
+//
+//	global:
+//	  insecure-flag: true
+//	icsCenter:
+//	  10.0.0.1:
+//	    datacenters: DC0
+//
+// The deprecated VirtualCenter section is read as ICSCenter.
+func ReadConfigInto(config interface{}, reader io.Reader) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if !isINI(data) {
+		if data, err = yamlToINI(data); err != nil {
+			return err
+		}
+	}
+	if legacySection.Match(data) {
+		klog.Warning("The VirtualCenter section of the cloud config is deprecated, use ICSCenter instead")
+		data = legacySection.ReplaceAll(data, []byte("${1}ICSCenter"))
+	}
+	return gcfg.FatalOnly(gcfg.ReadStringInto(config, string(data)))
+}
+
+// isINI returns true if the first line of the config which is neither blank
+// nor a comment is a section header.
+func isINI(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		return strings.HasPrefix(line, "[")
+	}
+	return true
+}
+
+// yamlValue is a YAML value whose scalars are kept as written, e.g. yes or
+// 0123, rather than resolved to the bool or number YAML 1.1 reads them as.
+// gcfg parses the values itself, and a password or a datacenter name must be
+// read as is.
+type yamlValue struct {
+	scalar   *string
+	sequence []yamlValue
+	mapping  map[string]yamlValue
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *yamlValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var resolved interface{}
+	if err := unmarshal(&resolved); err != nil {
+		return err
+	}
+	switch resolved.(type) {
+	case nil:
+		return nil
+	case map[interface{}]interface{}:
+		return unmarshal(&v.mapping)
+	case []interface{}:
+		return unmarshal(&v.sequence)
+	}
+
+	// Unmarshalling a resolved scalar into a string yields its text.
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	v.scalar = &text
+	return nil
+}
+
+// yamlToINI converts a YAML or JSON config to gcfg INI, so both formats share
+// the gcfg field mapping and conversions.
+func yamlToINI(data []byte) ([]byte, error) {
+	var sections map[string]yamlValue
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("invalid cloud config: %v", err)
+	}
+
+	var buf bytes.Buffer
+	for _, section := range sortedKeys(sections) {
+		value := sections[section]
+		if value.scalar == nil && value.sequence == nil && value.mapping == nil {
+			continue
+		}
+		if value.mapping == nil {
+			return nil, fmt.Errorf("invalid cloud config: section %s is not an object", section)
+		}
+
+		// Sections holding only subsections, e.g. icsCenter, have no header
+		// of their own.
+		var sectionBuf bytes.Buffer
+		subsections := make(map[string]yamlValue)
+		if err := writeVariables(&sectionBuf, section, value.mapping, subsections); err != nil {
+			return nil, err
+		}
+		if sectionBuf.Len() > 0 || len(subsections) == 0 {
+			fmt.Fprintf(&buf, "[%s]\n", section)
+			buf.Write(sectionBuf.Bytes())
+		}
+		for _, subsection := range sortedKeys(subsections) {
+			fmt.Fprintf(&buf, "[%s %s]\n", section, quote(subsection))
+			err := writeVariables(&buf, section+"."+subsection, subsections[subsection].mapping, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeVariables writes the variables of a section as gcfg INI, collecting
+// the nested objects into subsections if it is not nil. Every value is
+// quoted, gcfg converting the text to the type of the field.
+func writeVariables(buf *bytes.Buffer, section string, variables map[string]yamlValue,
+	subsections map[string]yamlValue) error {
+	for _, name := range sortedKeys(variables) {
+		value := variables[name]
+		switch {
+		case value.mapping != nil:
+			if subsections == nil {
+				return fmt.Errorf("invalid cloud config: %s.%s is an object", section, name)
+			}
+			subsections[name] = value
+		case value.sequence != nil:
+			for _, item := range value.sequence {
+				if item.scalar == nil {
+					return fmt.Errorf("invalid cloud config: %s.%s: list items must be scalars", section, name)
+				}
+				fmt.Fprintf(buf, "%s = %s\n", name, quote(*item.scalar))
+			}
+		case value.scalar != nil:
+			fmt.Fprintf(buf, "%s = %s\n", name, quote(*value.scalar))
+		}
+	}
+	return nil
+}
+
+// quote returns s as a gcfg quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
+
+func sortedKeys(m map[string]yamlValue) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -16,6 +16,10 @@ limitations under the License.
 
 package config
 
+import (
+	"net"
+)
+
 // Config is used to read and store information from the cloud configuration file
 type Config struct {
 	Global struct {
@@ -30,6 +34,19 @@ type Config struct {
 		ICenterPort string `gcfg:"port"`
 		// True if iCenter uses self-signed cert.
 		InsecureFlag bool `gcfg:"insecure-flag"`
+		// CA bundle the iCenter certificates must be signed by, as a PEM
+		// file and as inline PEM data, raw or base64 encoded.
+		CAFile string `gcfg:"ca-file"`
+		CAData string `gcfg:"ca-data"`
+		// SHA-1 or SHA-256 fingerprint the iCenter certificate must have,
+		// e.g. "AB:CD:...". Takes precedence over the CA bundle.
+		Thumbprint string `gcfg:"thumbprint"`
+		// URL of the HTTP, HTTPS or SOCKS5 proxy iCenter is reached
+		// through, and the comma separated hosts, domains and CIDRs reached
+		// directly, as in NO_PROXY. Default to the HTTPS_PROXY and NO_PROXY
+		// environment variables.
+		ProxyURL string `gcfg:"proxy-url"`
+		NoProxy  string `gcfg:"no-proxy"`
 		// Datacenter in which VMs are located.
 		Datacenters string `gcfg:"datacenters"`
 		// Name of the secret were iCenter credentials are present.
@@ -47,6 +64,26 @@ type Config struct {
 		// Configurable inCloud Sphere CCM API port
 		// Default: 43001
 		APIBinding string `gcfg:"api-binding"`
+		// TLS certificate and key served by the inCloud Sphere CCM API.
+		// The API is served in plaintext unless both are set. Both files
+		// are reloaded when they change.
+		APITLSCertFile string `gcfg:"api-tls-cert-file"`
+		APITLSKeyFile  string `gcfg:"api-tls-key-file"`
+		// CA bundle the client certificates of the API must be signed by.
+		// Requires the TLS certificate and key.
+		APIClientCAFile string `gcfg:"api-client-ca-file"`
+		// File holding the bearer token the API clients must present.
+		// Requires the TLS certificate and key.
+		APITokenFile string `gcfg:"api-token-file"`
+		// Disable gRPC reflection on the inCloud Sphere CCM API
+		APIDisableReflection bool `gcfg:"api-disable-reflection"`
+		// Configurable ADDRESS:PORT of the /healthz and /readyz endpoints
+		// Default: 43002
+		HealthBinding string `gcfg:"health-binding"`
+		// Fail the initialization when an iCenter fails verification,
+		// instead of starting without it until it recovers.
+		// Default: false
+		VerifyFailFast bool `gcfg:"verify-fail-fast"`
 		// IP Family enables the ability to support IPv4 or IPv6
 		// Supported values are:
 		// ipv4 - IPv4 addresses only (Default)
@@ -81,8 +118,23 @@ type ICSCenterConfig struct {
 	ICenterIP string `gcfg:"server"`
 	// iCenter port.
 	ICenterPort string `gcfg:"port"`
+	// Comma separated HOST[:PORT] list of the redundant endpoints of the
+	// iCenter, e.g. an active/standby pair, in order of preference. The port
+	// defaults to the port above. If set, server defaults to the first one.
+	Endpoints string `gcfg:"endpoints"`
+	// ICenterEndpoints (intentionally not exposed via the config) the parsed
+	// endpoints, or only server and port if Endpoints is not set
+	ICenterEndpoints []ICSCenterEndpoint
 	// True if iCenter uses self-signed cert.
 	InsecureFlag bool `gcfg:"insecure-flag"`
+	// CA bundle and certificate thumbprint, see Global. Default to the
+	// Global ones.
+	CAFile     string `gcfg:"ca-file"`
+	CAData     string `gcfg:"ca-data"`
+	Thumbprint string `gcfg:"thumbprint"`
+	// Proxy, see Global. Default to the Global ones.
+	ProxyURL string `gcfg:"proxy-url"`
+	NoProxy  string `gcfg:"no-proxy"`
 	// Datacenter in which VMs are located.
 	Datacenters string `gcfg:"datacenters"`
 	// SecretRef (intentionally not exposed via the config) is a key to identify which
@@ -100,3 +152,14 @@ type ICSCenterConfig struct {
 	// IPFamilyPriority (intentionally not exposed via the config) the list/priority of IP versions
 	IPFamilyPriority []string
 }
+
+// ICSCenterEndpoint is an address an iCenter is served at.
+type ICSCenterEndpoint struct {
+	Host string
+	Port string
+}
+
+// String returns the endpoint as HOST:PORT.
+func (e ICSCenterEndpoint) String() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
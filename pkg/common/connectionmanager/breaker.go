@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+	"k8s.io/klog"
+)
+
+// circuitState is the state of a circuitBreaker. The values are the ones
+// reported by the circuit_breaker_state metric.
+type circuitState int
+
+const (
+	// Connections are attempted.
+	circuitClosed circuitState = iota
+	// Connections fail fast with ErrCircuitOpen.
+	circuitOpen
+	// A single connection is attempted to decide whether to close again.
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// circuitBreaker stops the connections to an iCenter after repeated
+// failures, so that a dead iCenter fails fast instead of slowing down every
+// lookup. After openDuration, the next connection is let through to find out
+// whether the iCenter is back.
+type circuitBreaker struct {
+	sync.Mutex
+
+	tenantRef string
+	// Number of consecutive failures opening the circuit.
+	threshold int
+	// How long the circuit stays open before letting a connection through.
+	openDuration time.Duration
+
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(tenantRef string) *circuitBreaker {
+	b := &circuitBreaker{
+		tenantRef:    tenantRef,
+		threshold:    CircuitBreakerThreshold,
+		openDuration: CircuitBreakerOpenDuration,
+	}
+	metrics.SetCircuitBreakerState(tenantRef, int(circuitClosed))
+	return b
+}
+
+// setState changes the state of the circuit. Callers must hold the lock.
+func (b *circuitBreaker) setState(state circuitState) {
+	if b.state == state {
+		return
+	}
+	switch state {
+	case circuitOpen:
+		klog.Warningf("Circuit breaker of iCenter %s is open after %d failures, retrying in %v",
+			b.tenantRef, b.failures, b.openDuration)
+	default:
+		klog.Infof("Circuit breaker of iCenter %s is %s", b.tenantRef, state)
+	}
+	b.state = state
+	metrics.SetCircuitBreakerState(b.tenantRef, int(state))
+}
+
+// allow returns ErrCircuitOpen if connections to the iCenter must fail fast.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state != circuitOpen {
+		return nil
+	}
+	if time.Since(b.openedAt) < b.openDuration {
+		return ErrCircuitOpen
+	}
+	b.setState(circuitHalfOpen)
+	return nil
+}
+
+// record records the outcome of a connection to the iCenter.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
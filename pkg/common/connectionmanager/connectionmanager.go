@@ -27,6 +27,7 @@ import (
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/credentialmanager"
 	k8s "github.com/inspur-ics/cloud-provider-ics/pkg/common/kubernetes"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 	icsgo "github.com/inspur-ics/ics-go-sdk"
 )
 
@@ -40,6 +41,9 @@ func NewConnectionManager(cfg *icfg.Config, informMgr *k8s.InformerManager, clie
 		ICSInstanceMap: generateInstanceMap(cfg),
 		credentialManagers: make(map[string]*cm.CredentialManager),
 		informerManagers:   make(map[string]*k8s.InformerManager),
+		inventory:          newVMInventory(),
+		statuses:           make(map[string]*ConnectionStatus),
+		backoff:            DefaultBackoff,
 	}
 
 	if informMgr != nil {
@@ -71,39 +75,71 @@ func NewConnectionManager(cfg *icfg.Config, informMgr *k8s.InformerManager, clie
 func generateInstanceMap(cfg *icfg.Config) map[string]*ICSInstance {
 	icsInstanceMap := make(map[string]*ICSInstance)
 	for _, icsConfig := range cfg.ICSCenter {
-		icsConn := icsgo.ICSConnection{
-			Username:          icsConfig.User,
-			Password:          icsConfig.Password,
-			Hostname:          icsConfig.ICenterIP,
-			Insecure:          icsConfig.InsecureFlag,
-			Port:              icsConfig.ICenterPort,
-		}
-		icsIns := ICSInstance{
-			Conn: &icsConn,
-			Cfg:  icsConfig,
-		}
-		icsInstanceMap[icsConfig.TenantRef] = &icsIns
+		icsInstanceMap[icsConfig.TenantRef] = newICSInstance(icsConfig)
 	}
 
 	return icsInstanceMap
 }
 
+// newICSInstance creates the connection object of an iCenter, not connected
+// yet.
+func newICSInstance(icsConfig *icfg.ICSCenterConfig) *ICSInstance {
+	icsConn := icsgo.ICSConnection{
+		Username:          icsConfig.User,
+		Password:          icsConfig.Password,
+		Hostname:          icsConfig.ICenterIP,
+		Insecure:          icsConfig.InsecureFlag,
+		Port:              icsConfig.ICenterPort,
+	}
+	icsIns := ICSInstance{
+		Conn:      &icsConn,
+		Cfg:       icsConfig,
+		breaker:   newCircuitBreaker(icsConfig.TenantRef),
+		endpoints: instanceEndpoints(icsConfig),
+	}
+	icsConn.Hostname = icsIns.endpoints[0].Host
+	icsConn.Port = icsIns.endpoints[0].Port
+	icsIns.proxy = newProxyFunc(icsConfig)
+	icsIns.tlsConfig, icsIns.tlsErr = newTLSConfig(icsConfig)
+	if icsIns.tlsErr != nil {
+		klog.Errorf("Invalid TLS configuration of iCenter %s: %v", icsConfig.TenantRef, icsIns.tlsErr)
+	}
+	metrics.SetActiveEndpoint(icsConfig.TenantRef, "", icsIns.endpoints[0].String())
+	return &icsIns
+}
+
+// Instances returns the ICSInstance of every iCenter by tenantRef. The map is
+// replaced, never modified, when the configuration is reloaded.
+func (connMgr *ConnectionManager) Instances() map[string]*ICSInstance {
+	connMgr.instancesLock.RLock()
+	defer connMgr.instancesLock.RUnlock()
+	return connMgr.ICSInstanceMap
+}
+
 // InitializeSecretLister initializes the individual secret listers that are NOT
 // handled through the Default/Global lister tied to the default service account.
+// It may be called again after a reload, for the secrets not seen before.
 func (connMgr *ConnectionManager) InitializeSecretLister() {
+	connMgr.instancesLock.Lock()
+	defer connMgr.instancesLock.Unlock()
+
 	// For each vsi that has a Secret set createManagersPerTenant
 	for _, instance := range connMgr.ICSInstanceMap {
-		klog.V(3).Infof("Checking icsServer=%s SecretRef=%s", instance.Cfg.ICenterIP, instance.Cfg.SecretRef)
-		if strings.EqualFold(instance.Cfg.SecretRef, icfg.DefaultCredentialManager) {
-			klog.V(3).Infof("Skipping. iCenter %s is configured using global service account/secret.", instance.Cfg.ICenterIP)
+		klog.V(3).Infof("Checking icsServer=%s SecretRef=%s", instance.Config().ICenterIP, instance.Config().SecretRef)
+		if strings.EqualFold(instance.Config().SecretRef, icfg.DefaultCredentialManager) {
+			klog.V(3).Infof("Skipping. iCenter %s is configured using global service account/secret.", instance.Config().ICenterIP)
+			continue
+		}
+		if _, ok := connMgr.credentialManagers[instance.Config().SecretRef]; ok {
+			klog.V(3).Infof("Skipping. credMgr/informMgr for SecretRef=%s already exist.", instance.Config().SecretRef)
 			continue
 		}
 
-		klog.V(3).Infof("Adding credMgr/informMgr for icsServer=%s", instance.Cfg.ICenterIP)
-		credsMgr, informMgr := connMgr.createManagersPerTenant(instance.Cfg.SecretName,
-			instance.Cfg.SecretNamespace, "", connMgr.client)
-		connMgr.credentialManagers[instance.Cfg.SecretRef] = credsMgr
-		connMgr.informerManagers[instance.Cfg.SecretRef] = informMgr
+		klog.V(3).Infof("Adding credMgr/informMgr for icsServer=%s", instance.Config().ICenterIP)
+		credsMgr, informMgr := connMgr.createManagersPerTenant(instance.Config().SecretName,
+			instance.Config().SecretNamespace, "", connMgr.client)
+		connMgr.credentialManagers[instance.Config().SecretRef] = credsMgr
+		connMgr.informerManagers[instance.Config().SecretRef] = informMgr
 	}
 }
 
@@ -126,15 +162,42 @@ func (connMgr *ConnectionManager) createManagersPerTenant(secretName string, sec
 	return credMgr, informMgr
 }
 
-// Connect connects to iCenter with existing credentials
+// Connect connects to iCenter with existing credentials, recording the
+// outcome in the ConnectionStatus of the iCenter.
 // If credentials are invalid:
 // 		1. It will fetch credentials from credentialManager
 //      2. Update the credentials
 //		3. Connects again to iCenter with fetched credentials
+// Only one connection per iCenter is in progress at a time, shared by every
+// caller. Connect returns when it is done or ctx is, whichever comes first,
+// and never waits for the connections to other iCenters.
+// After repeated failures, Connect fails fast with ErrCircuitOpen for a while.
 func (connMgr *ConnectionManager) Connect(ctx context.Context, icsInstance *ICSInstance) error {
-	connMgr.Lock()
-	defer connMgr.Unlock()
-	err := icsInstance.Conn.Connect(ctx)
+	if err := icsInstance.breaker.allow(); err != nil {
+		return err
+	}
+
+	call := icsInstance.connectOnce(func() error {
+		// Shared by the callers, so not bound to the context of any of them.
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+		defer cancel()
+		err := connMgr.connect(ctx, icsInstance)
+		icsInstance.breaker.record(err)
+		connMgr.recordConnect(icsInstance, err)
+		metrics.RecordConnect(icsInstance.Config().TenantRef, err)
+		return err
+	})
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (connMgr *ConnectionManager) connect(ctx context.Context, icsInstance *ICSInstance) error {
+	err := icsInstance.login(ctx)
 	if err == nil {
 		return nil
 	}
@@ -145,62 +208,74 @@ func (connMgr *ConnectionManager) Connect(ctx context.Context, icsInstance *ICSI
 	}
 
 	klog.V(2).Infof("Invalid credentials. Fetching credentials from secrets. icsServer=%s credentialHolder=%s",
-		icsInstance.Cfg.ICenterIP, icsInstance.Cfg.SecretRef)
+		icsInstance.Config().ICenterIP, icsInstance.Config().SecretRef)
 
-	credMgr := connMgr.credentialManagers[icsInstance.Cfg.SecretRef]
+	connMgr.instancesLock.RLock()
+	credMgr := connMgr.credentialManagers[icsInstance.Config().SecretRef]
+	connMgr.instancesLock.RUnlock()
 	if credMgr == nil {
-		klog.Errorf("Unable to find credential manager for icsServer=%s credentialHolder=%s", icsInstance.Cfg.ICenterIP, icsInstance.Cfg.SecretRef)
+		klog.Errorf("Unable to find credential manager for icsServer=%s credentialHolder=%s", icsInstance.Config().ICenterIP, icsInstance.Config().SecretRef)
 		return ErrUnableToFindCredentialManager
 	}
-	credentials, err := credMgr.GetCredential(icsInstance.Cfg.ICenterIP)
+	credentials, err := credMgr.GetCredential(icsInstance.Config().ICenterIP)
 	if err != nil {
 		klog.Error("Failed to get credentials from Secret Credential Manager with err:", err)
 		return err
 	}
-	icsInstance.Conn.UpdateCredentials(credentials.User, credentials.Password)
-	return icsInstance.Conn.Connect(ctx)
+	icsInstance.setCredentials(credentials.User, credentials.Password)
+	return icsInstance.login(ctx)
+}
+
+// ResolveCredentials sets the credentials of the iCenters configured with a
+// secret, rather than a user and password, from their credential managers.
+// It returns the errors of the iCenters whose credentials were not found, by
+// tenantRef.
+func (connMgr *ConnectionManager) ResolveCredentials() map[string]error {
+	errs := make(map[string]error)
+	for tenantRef, icsInstance := range connMgr.Instances() {
+		if icsInstance.Config().User != "" && icsInstance.Config().Password != "" {
+			continue
+		}
+
+		connMgr.instancesLock.RLock()
+		credMgr := connMgr.credentialManagers[icsInstance.Config().SecretRef]
+		connMgr.instancesLock.RUnlock()
+		if credMgr == nil {
+			klog.Errorf("Unable to find credential manager for icsServer=%s credentialHolder=%s", icsInstance.Config().ICenterIP, icsInstance.Config().SecretRef)
+			errs[tenantRef] = ErrUnableToFindCredentialManager
+			continue
+		}
+		credentials, err := credMgr.GetCredential(icsInstance.Config().ICenterIP)
+		if err != nil {
+			klog.Errorf("Failed to get the credentials of iCenter %s: %v", tenantRef, err)
+			errs[tenantRef] = err
+			continue
+		}
+		icsInstance.setCredentials(credentials.User, credentials.Password)
+	}
+	return errs
 }
 
 // Logout closes existing connections to remote iCenter endpoints.
 func (connMgr *ConnectionManager) Logout() {
-	for _, icsIns := range connMgr.ICSInstanceMap {
-		connMgr.Lock()
-		c := icsIns.Conn.Client
-		connMgr.Unlock()
-		if c != nil {
-			icsIns.Conn.Logout(context.TODO())
+	for _, icsIns := range connMgr.Instances() {
+		if session := icsIns.Connection(); session != nil {
+			session.Logout(context.TODO())
 		}
 	}
 }
 
 // Verify validates the configuration by attempting to connect to the
-// configured, remote iCenter endpoints.
+// configured, remote iCenter endpoints. It returns the errors of every
+// iCenter that failed, see VerifyAll for the details.
 func (connMgr *ConnectionManager) Verify() error {
-	for _, icsInstance := range connMgr.ICSInstanceMap {
-		err := connMgr.Connect(context.Background(), icsInstance)
-		if err == nil {
-			klog.V(3).Infof("iCenter connect %s succeeded.", icsInstance.Cfg.ICenterIP)
-		} else {
-			klog.Errorf("iCenter %s failed. Err: %q", icsInstance.Cfg.ICenterIP, err)
-			return err
-		}
-	}
-	return nil
+	return connMgr.VerifyWithContext(context.Background())
 }
 
 // VerifyWithContext is the same as Verify but allows a Go Context
 // to control the lifecycle of the connection event.
 func (connMgr *ConnectionManager) VerifyWithContext(ctx context.Context) error {
-	for _, icsInstance := range connMgr.ICSInstanceMap {
-		err := connMgr.Connect(ctx, icsInstance)
-		if err == nil {
-			klog.V(3).Infof("iCenter connect %s succeeded.", icsInstance.Cfg.ICenterIP)
-		} else {
-			klog.Errorf("iCenter %s failed. Err: %q", icsInstance.Cfg.ICenterIP, err)
-			return err
-		}
-	}
-	return nil
+	return connMgr.VerifyAll(ctx, "", "").Err()
 }
 
 // APIVersion returns the version of the iCenter API
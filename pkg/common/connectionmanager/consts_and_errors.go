@@ -18,6 +18,7 @@ package connectionmanager
 
 import (
 	"errors"
+	"time"
 )
 
 // FindVM is the type that represents the types of searches used to
@@ -31,6 +32,8 @@ const (
 	FindVMByName // 1
 	// FindVMByIP finds VMs with the provided IP adress.
 	FindVMByIP // 2
+	// FindVMByMAC finds VMs with the provided MAC address.
+	FindVMByMAC // 3
 
 	// PoolSize is the number of goroutines used in parallel to find a VM.
 	PoolSize int = 8
@@ -43,9 +46,51 @@ const (
 	// before an error is returned.
 	NumConnectionAttempts int = 3
 
-	// RetryAttemptDelaySecs is the number of seconds to wait between
-	// connection attempts.
-	RetryAttemptDelaySecs int = 1
+	// CircuitBreakerThreshold is the number of consecutive connection
+	// failures after which the connections to an iCenter fail fast.
+	CircuitBreakerThreshold int = 5
+)
+
+const (
+	// InventoryRefreshInterval is how often the VM inventory of every
+	// iCenter is rebuilt in the background.
+	InventoryRefreshInterval time.Duration = 5 * time.Minute
+
+	// InventoryMissRefreshInterval is the minimum time between two
+	// refreshes of the VM inventory of an iCenter triggered by lookups
+	// missing in the index.
+	InventoryMissRefreshInterval time.Duration = 30 * time.Second
+
+	// InventoryNegativeCacheTTL is how long a node that was not found is
+	// reported missing without refreshing the VM inventory again.
+	InventoryNegativeCacheTTL time.Duration = 1 * time.Minute
+
+	// ConnectionCheckInterval is how often the connection to every
+	// iCenter is checked in the background.
+	ConnectionCheckInterval time.Duration = 30 * time.Second
+
+	// ConnectTimeout is how long a connection to an iCenter, shared by the
+	// callers of Connect, may take.
+	ConnectTimeout time.Duration = 1 * time.Minute
+
+	// DialTimeout is how long to wait for an iCenter to accept a TCP
+	// connection before logging in.
+	DialTimeout time.Duration = 10 * time.Second
+
+	// RetryAttemptDelay is how long to wait after the first failed
+	// connection attempt. The delay doubles after each next failure.
+	RetryAttemptDelay time.Duration = 1 * time.Second
+
+	// MaxRetryAttemptDelay caps the delay between connection attempts.
+	MaxRetryAttemptDelay time.Duration = 8 * time.Second
+
+	// CircuitBreakerOpenDuration is how long the connections to an iCenter
+	// fail fast before one is attempted again.
+	CircuitBreakerOpenDuration time.Duration = 30 * time.Second
+
+	// FailbackInterval is how often the preferred endpoint of an iCenter is
+	// probed while the connection uses another one.
+	FailbackInterval time.Duration = 1 * time.Minute
 )
 
 // Error Messages
@@ -56,6 +101,10 @@ const (
 	MultiDCRequiresZonesErrMsg     = "The use of multiple Datacenters within a iCenter require the use of zones"
 	UnsupportedConfigurationErrMsg = "Unsupported configuration"
 	UnableToFindCredentialManager  = "Unable to find Credential Manager"
+	NotConnectedErrMsg             = "No connection to iCenter was attempted yet"
+	CircuitOpenErrMsg              = "Too many failed connections to iCenter, not retrying yet"
+	TagCategoryNotFoundErrMsg      = "No tag of the zone or region category found"
+	InvalidCAErrMsg                = "No PEM encoded certificate found"
 )
 
 // Error constants
@@ -66,4 +115,8 @@ var (
 	ErrMultiDCRequiresZones          = errors.New(MultiDCRequiresZonesErrMsg)
 	ErrUnsupportedConfiguration      = errors.New(UnsupportedConfigurationErrMsg)
 	ErrUnableToFindCredentialManager = errors.New(UnableToFindCredentialManager)
+	ErrNotConnected                  = errors.New(NotConnectedErrMsg)
+	ErrCircuitOpen                   = errors.New(CircuitOpenErrMsg)
+	ErrTagCategoryNotFound           = errors.New(TagCategoryNotFoundErrMsg)
+	ErrInvalidCA                     = errors.New(InvalidCAErrMsg)
 )
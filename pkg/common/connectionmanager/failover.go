@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"time"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+	"k8s.io/klog"
+)
+
+// instanceEndpoints returns the endpoints of the iCenter in order of
+// preference.
+func instanceEndpoints(cfg *icfg.ICSCenterConfig) []icfg.ICSCenterEndpoint {
+	if len(cfg.ICenterEndpoints) > 0 {
+		return cfg.ICenterEndpoints
+	}
+	return []icfg.ICSCenterEndpoint{{Host: cfg.ICenterIP, Port: cfg.ICenterPort}}
+}
+
+// ActiveEndpoint returns the endpoint the connection to the iCenter uses.
+func (icsInstance *ICSInstance) ActiveEndpoint() icfg.ICSCenterEndpoint {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+
+	if len(icsInstance.endpoints) == 0 {
+		return icfg.ICSCenterEndpoint{Host: icsInstance.Conn.Hostname, Port: icsInstance.Conn.Port}
+	}
+	return icsInstance.endpoints[icsInstance.active]
+}
+
+// useEndpoint points the connection at endpoint i. Changing the endpoint
+// drops the session, which belongs to the previous one.
+func (icsInstance *ICSInstance) useEndpoint(i int) {
+	icsInstance.lock.Lock()
+	previous := icsInstance.active
+	icsInstance.active = i
+	endpoint := icsInstance.endpoints[i]
+	conn := icsInstance.Conn
+	from := icfg.ICSCenterEndpoint{Host: conn.Hostname, Port: conn.Port}
+	if from == endpoint {
+		icsInstance.lock.Unlock()
+		return
+	}
+	conn.Hostname = endpoint.Host
+	conn.Port = endpoint.Port
+	icsInstance.session = nil
+	icsInstance.lock.Unlock()
+
+	tenantRef := icsInstance.Config().TenantRef
+	if i < previous {
+		klog.Infof("iCenter %s fails back from %s to %s", tenantRef, from, endpoint)
+	} else {
+		klog.Warningf("iCenter %s fails over from %s to %s", tenantRef, from, endpoint)
+	}
+	metrics.SetActiveEndpoint(tenantRef, from.String(), endpoint.String())
+}
+
+// failbackDue returns true if the connection uses a less preferred endpoint
+// and the preferred one was not probed for FailbackInterval.
+func (icsInstance *ICSInstance) failbackDue() bool {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+
+	if icsInstance.active == 0 || time.Since(icsInstance.lastFailbackProbe) < FailbackInterval {
+		return false
+	}
+	icsInstance.lastFailbackProbe = time.Now()
+	return true
+}
+
+// login makes sure the connection has a valid session. Without one, it logs
+// in to the first endpoint accepting TCP connections, in order of
+// preference. With one on a less preferred endpoint, it fails back once the
+// preferred endpoint is reachable again.
+func (icsInstance *ICSInstance) login(ctx context.Context) error {
+	if validSession(ctx, icsInstance.Client()) {
+		if !icsInstance.failbackDue() {
+			return nil
+		}
+		if err := icsInstance.dial(ctx, icsInstance.endpoints[0]); err != nil {
+			return nil
+		}
+	}
+
+	var err error
+	for i, endpoint := range icsInstance.endpoints {
+		if err = icsInstance.dial(ctx, endpoint); err != nil {
+			continue
+		}
+		icsInstance.useEndpoint(i)
+		// The credentials are the same for every endpoint.
+		if err = icsInstance.connectEndpoint(ctx); err == nil || isCredentialError(err) {
+			return err
+		}
+	}
+	return err
+}
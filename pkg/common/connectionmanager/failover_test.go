@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+func TestFailover(t *testing.T) {
+	cfg, primaryModel, primaryCleanup := configFromSim(false)
+	standbyCfg, _, standbyCleanup := configFromSim(false)
+	defer standbyCleanup()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	down := icfg.ICSCenterEndpoint{Host: host, Port: port}
+	l.Close()
+
+	primary := icfg.ICSCenterEndpoint{Host: cfg.Global.ICenterIP, Port: cfg.Global.ICenterPort}
+	standby := icfg.ICSCenterEndpoint{Host: standbyCfg.Global.ICenterIP, Port: standbyCfg.Global.ICenterPort}
+	cfg.ICSCenter[cfg.Global.ICenterIP].ICenterEndpoints = []icfg.ICSCenterEndpoint{down, standby}
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+	ctx := context.Background()
+
+	expectEndpoint := func(step string, expected icfg.ICSCenterEndpoint) {
+		t.Helper()
+		if err := connMgr.Connect(ctx, icsInstance); err != nil {
+			t.Fatalf("%s: Connect err=%v", step, err)
+		}
+		if endpoint := icsInstance.ActiveEndpoint(); endpoint != expected {
+			t.Errorf("%s: active endpoint %s, expected %s", step, endpoint, expected)
+		}
+		if endpoint := connMgr.ConnectionStatuses()[0].Endpoint; endpoint != expected.String() {
+			t.Errorf("%s: status endpoint %s, expected %s", step, endpoint, expected)
+		}
+	}
+
+	expectEndpoint("primary down", standby)
+
+	// The primary is back: fail back even though the standby session is valid.
+	icsInstance.endpoints[0] = primary
+	expectEndpoint("primary back", primary)
+	if logins := primaryModel.Logins(); logins != 1 {
+		t.Errorf("%d logins to the primary, expected 1", logins)
+	}
+
+	// The primary goes down for good.
+	primaryCleanup()
+	expectEndpoint("primary down again", standby)
+}
+
+func TestFailoverCredentials(t *testing.T) {
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+	standbyCfg, standbyModel, standbyCleanup := configFromSim(false)
+	defer standbyCleanup()
+
+	primary := icfg.ICSCenterEndpoint{Host: cfg.Global.ICenterIP, Port: cfg.Global.ICenterPort}
+	standby := icfg.ICSCenterEndpoint{Host: standbyCfg.Global.ICenterIP, Port: standbyCfg.Global.ICenterPort}
+	cfg.ICSCenter[cfg.Global.ICenterIP].ICenterEndpoints = []icfg.ICSCenterEndpoint{primary, standby}
+	cfg.ICSCenter[cfg.Global.ICenterIP].Password = "wrong"
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+
+	// Rejected credentials would be rejected by every endpoint.
+	if err := connMgr.Connect(context.Background(), icsInstance); err == nil || !isCredentialError(err) {
+		t.Errorf("Connect with the wrong password err=%v, expected a credential error", err)
+	}
+	if endpoint := icsInstance.ActiveEndpoint(); endpoint != primary {
+		t.Errorf("active endpoint %s, expected %s", endpoint, primary)
+	}
+	if model.Logins() != 0 || standbyModel.Logins() != 0 {
+		t.Errorf("unexpected logins %d, %d", model.Logins(), standbyModel.Logins())
+	}
+}
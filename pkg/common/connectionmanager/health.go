@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// isCredentialError returns true if err means iCenter rejected, or could not
+// be given, the credentials.
+func isCredentialError(err error) bool {
+	if err == ErrUnableToFindCredentialManager {
+		return true
+	}
+	if sdkErr, ok := err.(*types.SDKError); ok {
+		return sdkErr.Code == "401" || sdkErr.Code == "403"
+	}
+	return false
+}
+
+// recordConnect records the outcome of a Connect to the iCenter.
+func (connMgr *ConnectionManager) recordConnect(icsInstance *ICSInstance, err error) {
+	connMgr.statusLock.Lock()
+	defer connMgr.statusLock.Unlock()
+
+	if connMgr.statuses == nil {
+		connMgr.statuses = make(map[string]*ConnectionStatus)
+	}
+	status := connMgr.statuses[icsInstance.Config().TenantRef]
+	if status == nil {
+		status = &ConnectionStatus{
+			TenantRef: icsInstance.Config().TenantRef,
+			ICenterIP: icsInstance.Config().ICenterIP,
+		}
+		connMgr.statuses[icsInstance.Config().TenantRef] = status
+	}
+
+	status.LastAttempt = time.Now()
+	status.Endpoint = icsInstance.ActiveEndpoint().String()
+	status.LastError = err
+	status.CredentialsValid = !isCredentialError(err)
+	if err == nil {
+		status.LastSuccess = status.LastAttempt
+	}
+}
+
+// Ready returns nil if the last connection to the iCenter succeeded, the
+// reason it is not ready otherwise.
+func (status *ConnectionStatus) Ready() error {
+	switch {
+	case status.LastAttempt.IsZero():
+		return ErrNotConnected
+	case status.LastError != nil && !status.CredentialsValid:
+		return fmt.Errorf("invalid credentials: %v", status.LastError)
+	case status.LastError != nil:
+		return status.LastError
+	}
+	return nil
+}
+
+// ConnectionStatuses returns the status of the connection to every
+// configured iCenter, sorted by tenantRef.
+func (connMgr *ConnectionManager) ConnectionStatuses() []ConnectionStatus {
+	connMgr.statusLock.RLock()
+	defer connMgr.statusLock.RUnlock()
+
+	instances := connMgr.Instances()
+	statuses := make([]ConnectionStatus, 0, len(instances))
+	for tenantRef, icsInstance := range instances {
+		if status := connMgr.statuses[tenantRef]; status != nil {
+			statuses = append(statuses, *status)
+			continue
+		}
+		statuses = append(statuses, ConnectionStatus{
+			TenantRef:        tenantRef,
+			ICenterIP:        icsInstance.Config().ICenterIP,
+			CredentialsValid: true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].TenantRef < statuses[j].TenantRef
+	})
+	return statuses
+}
+
+// CheckConnections connects to every iCenter, recording the outcome in
+// their ConnectionStatus. Unlike Verify, it does not stop at the first
+// failure.
+func (connMgr *ConnectionManager) CheckConnections(ctx context.Context) {
+	for _, icsInstance := range connMgr.Instances() {
+		if err := connMgr.Connect(ctx, icsInstance); err != nil {
+			klog.Warningf("iCenter %s is unreachable. Err: %q", icsInstance.Config().ICenterIP, err)
+		}
+	}
+}
+
+// RunConnectionChecks checks the connections every ConnectionCheckInterval
+// until stopCh is closed, calling checked after each round. It does not
+// block.
+func (connMgr *ConnectionManager) RunConnectionChecks(stopCh <-chan struct{}, checked func()) {
+	go wait.Until(func() {
+		connMgr.CheckConnections(context.Background())
+		if checked != nil {
+			checked()
+		}
+	}, ConnectionCheckInterval, stopCh)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnectionStatuses(t *testing.T) {
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	statuses := connMgr.ConnectionStatuses()
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 status, got %v", statuses)
+	}
+	if err := statuses[0].Ready(); err != ErrNotConnected {
+		t.Errorf("Ready() before any connection err=%v, expected %v", err, ErrNotConnected)
+	}
+
+	connMgr.CheckConnections(context.Background())
+	connected := connMgr.ConnectionStatuses()[0]
+	if err := connected.Ready(); err != nil {
+		t.Errorf("Ready() after a successful connection err=%v", err)
+	}
+	if connected.LastSuccess.IsZero() || connected.LastSuccess != connected.LastAttempt {
+		t.Errorf("unexpected status after a successful connection %+v", connected)
+	}
+
+	// Rejected credentials make the iCenter not ready, and are reported as such.
+	icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+	icsInstance.Conn.Password = "wrong"
+	model.ExpireSessions()
+	connMgr.CheckConnections(context.Background())
+	failed := connMgr.ConnectionStatuses()[0]
+	if failed.LastError == nil || failed.CredentialsValid || failed.Ready() == nil {
+		t.Errorf("unexpected status after rejected credentials %+v", failed)
+	}
+	if failed.LastSuccess != connected.LastSuccess {
+		t.Errorf("LastSuccess=%v, expected %v", failed.LastSuccess, connected.LastSuccess)
+	}
+
+	icsInstance.Conn.Password = model.Password
+	connMgr.CheckConnections(context.Background())
+	if err := connMgr.ConnectionStatuses()[0].Ready(); err != nil {
+		t.Errorf("Ready() after recovering err=%v", err)
+	}
+}
@@ -0,0 +1,355 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+// vmInventory is an index of the VMs found in every configured iCenter and
+// datacenter, keyed by UUID, name, IP and MAC address. Each tenant is
+// refreshed independently so one slow iCenter never holds back the others.
+type vmInventory struct {
+	sync.RWMutex
+
+	// Maps the tenantRef to the last successful snapshot of that iCenter.
+	tenants map[string]*tenantInventory
+	// Serializes refreshes per tenantRef.
+	refreshLocks map[string]*sync.Mutex
+	// Maps the lookup key of a node that was not found to when it missed.
+	misses map[string]time.Time
+
+	// The minimum time between two refreshes of a tenant triggered by
+	// misses, and how long a miss is remembered.
+	missRefreshInterval time.Duration
+	negativeTTL         time.Duration
+}
+
+// tenantInventory is the VM index of a single iCenter.
+type tenantInventory struct {
+	// When the refresh that built this snapshot started.
+	refreshed time.Time
+	index     map[FindVM]map[string]*VMDiscoveryInfo
+}
+
+func newVMInventory() *vmInventory {
+	return &vmInventory{
+		tenants:      make(map[string]*tenantInventory),
+		refreshLocks: make(map[string]*sync.Mutex),
+		misses:       make(map[string]time.Time),
+
+		missRefreshInterval: InventoryMissRefreshInterval,
+		negativeTTL:         InventoryNegativeCacheTTL,
+	}
+}
+
+// inventoryKey normalizes a lookup key so that the index is case insensitive.
+func inventoryKey(nodeID string) string {
+	return strings.ToLower(strings.TrimSpace(nodeID))
+}
+
+// find returns the indexed VM matching nodeID or nil if there is none.
+func (inv *vmInventory) find(nodeID string, searchBy FindVM) *VMDiscoveryInfo {
+	key := inventoryKey(nodeID)
+
+	inv.RLock()
+	defer inv.RUnlock()
+
+	for _, tenant := range inv.tenants {
+		if info, ok := tenant.index[searchBy][key]; ok {
+			return info
+		}
+	}
+	return nil
+}
+
+func missKey(nodeID string, searchBy FindVM) string {
+	return searchBy.String() + "/" + inventoryKey(nodeID)
+}
+
+// missedRecently returns true if nodeID was not found within the negative
+// cache TTL.
+func (inv *vmInventory) missedRecently(nodeID string, searchBy FindVM) bool {
+	inv.RLock()
+	defer inv.RUnlock()
+
+	missed, ok := inv.misses[missKey(nodeID, searchBy)]
+	return ok && time.Since(missed) < inv.negativeTTL
+}
+
+// recordMiss remembers that nodeID was not found, and forgets the misses
+// older than the negative cache TTL.
+func (inv *vmInventory) recordMiss(nodeID string, searchBy FindVM) {
+	inv.Lock()
+	defer inv.Unlock()
+
+	now := time.Now()
+	for key, missed := range inv.misses {
+		if now.Sub(missed) >= inv.negativeTTL {
+			delete(inv.misses, key)
+		}
+	}
+	inv.misses[missKey(nodeID, searchBy)] = now
+}
+
+// refreshedAt returns when the last successful refresh of the tenant started.
+func (inv *vmInventory) refreshedAt(tenantRef string) time.Time {
+	inv.RLock()
+	defer inv.RUnlock()
+
+	if tenant, ok := inv.tenants[tenantRef]; ok {
+		return tenant.refreshed
+	}
+	return time.Time{}
+}
+
+// vms returns the VMs of the last snapshot of the tenant.
+func (inv *vmInventory) vms(tenantRef string) []*VMDiscoveryInfo {
+	inv.RLock()
+	defer inv.RUnlock()
+
+	tenant, ok := inv.tenants[tenantRef]
+	if !ok {
+		return nil
+	}
+	vms := make([]*VMDiscoveryInfo, 0, len(tenant.index[FindVMByUUID]))
+	for _, info := range tenant.index[FindVMByUUID] {
+		vms = append(vms, info)
+	}
+	return vms
+}
+
+func (inv *vmInventory) refreshLock(tenantRef string) *sync.Mutex {
+	inv.Lock()
+	defer inv.Unlock()
+
+	lock, ok := inv.refreshLocks[tenantRef]
+	if !ok {
+		lock = &sync.Mutex{}
+		inv.refreshLocks[tenantRef] = lock
+	}
+	return lock
+}
+
+func (inv *vmInventory) store(tenantRef string, tenant *tenantInventory) {
+	inv.Lock()
+	defer inv.Unlock()
+
+	inv.tenants[tenantRef] = tenant
+}
+
+// remove drops the snapshot of the tenant, e.g. once it is no longer
+// configured.
+func (inv *vmInventory) remove(tenantRef string) {
+	inv.Lock()
+	defer inv.Unlock()
+
+	delete(inv.tenants, tenantRef)
+}
+
+// newTenantInventory indexes the VMs of the given datacenters.
+func newTenantInventory(instance *ICSInstance, started time.Time, vmsByDC map[*icslib.Datacenter][]*icslib.VirtualMachine) *tenantInventory {
+	tenant := &tenantInventory{
+		refreshed: started,
+		index: map[FindVM]map[string]*VMDiscoveryInfo{
+			FindVMByUUID: make(map[string]*VMDiscoveryInfo),
+			FindVMByName: make(map[string]*VMDiscoveryInfo),
+			FindVMByIP:   make(map[string]*VMDiscoveryInfo),
+			FindVMByMAC:  make(map[string]*VMDiscoveryInfo),
+		},
+	}
+
+	add := func(searchBy FindVM, key string, info *VMDiscoveryInfo) {
+		key = inventoryKey(key)
+		if key == "" {
+			return
+		}
+		if _, ok := tenant.index[searchBy][key]; ok {
+			klog.V(4).Infof("Duplicate VM %s %s in ics=%s, keeping the first match", searchBy, key, instance.Config().ICenterIP)
+			return
+		}
+		tenant.index[searchBy][key] = info
+	}
+
+	for datacenter, vms := range vmsByDC {
+		for _, vm := range vms {
+			info := &VMDiscoveryInfo{
+				TenantRef:  instance.Config().TenantRef,
+				DataCenter: datacenter,
+				VM:         vm,
+				IcsServer:  instance.Config().ICenterIP,
+				UUID:       inventoryKey(vm.UUID),
+				NodeName:   vm.Name,
+			}
+			add(FindVMByUUID, vm.UUID, info)
+			add(FindVMByName, vm.Name, info)
+			for _, nic := range vm.Nics {
+				add(FindVMByIP, nic.IP, info)
+				add(FindVMByMAC, nic.Mac, info)
+			}
+		}
+	}
+
+	return tenant
+}
+
+// RefreshInventory rebuilds the VM index of every configured iCenter in
+// parallel. Errors are logged, and the last error is returned, but a failing
+// iCenter keeps its previous snapshot.
+func (cm *ConnectionManager) RefreshInventory(ctx context.Context) error {
+	return cm.refreshInventorySince(ctx, time.Time{}, nil)
+}
+
+// refreshInventorySince refreshes every iCenter whose snapshot is older than
+// since. Concurrent callers that missed in the index at the same time share a
+// single refresh per iCenter, and an iCenter refreshed less than
+// InventoryMissRefreshInterval ago is not refreshed again.
+//
+// When found is not nil it is checked as each iCenter finishes, and
+// refreshInventorySince returns as soon as it reports true. The remaining
+// iCenters keep refreshing in the background, so a caller looking for one VM
+// does not wait on the slowest iCenter.
+func (cm *ConnectionManager) refreshInventorySince(ctx context.Context, since time.Time, found func() bool) error {
+	instances := cm.Instances()
+	errs := make(chan error, len(instances))
+
+	for _, instance := range instances {
+		go func(instance *ICSInstance) {
+			errs <- cm.refreshTenantInventory(ctx, instance, since)
+		}(instance)
+	}
+
+	var lastErr error
+	for range instances {
+		if err := <-errs; err != nil {
+			lastErr = err
+			continue
+		}
+		if found != nil && found() {
+			return nil
+		}
+	}
+
+	return lastErr
+}
+
+// RefreshTenantInventory rebuilds the VM index of the iCenter, with a single
+// paged VM list per datacenter, and returns its VMs. The index holds copies
+// shared with the other callers, they must not be modified.
+func (cm *ConnectionManager) RefreshTenantInventory(ctx context.Context, tenantRef string) ([]*VMDiscoveryInfo, error) {
+	instance := cm.Instances()[tenantRef]
+	if instance == nil {
+		return nil, ErrConnectionNotFound
+	}
+	if err := cm.refreshTenantInventory(ctx, instance, time.Time{}); err != nil {
+		return nil, err
+	}
+	return cm.inventory.vms(tenantRef), nil
+}
+
+func (cm *ConnectionManager) refreshTenantInventory(ctx context.Context, instance *ICSInstance, since time.Time) error {
+	tenantRef := instance.Config().TenantRef
+
+	lock := cm.inventory.refreshLock(tenantRef)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !since.IsZero() {
+		refreshed := cm.inventory.refreshedAt(tenantRef)
+		if refreshed.After(since) {
+			klog.V(4).Infof("VM inventory of ics=%s already refreshed", instance.Config().ICenterIP)
+			return nil
+		}
+		if time.Since(refreshed) < cm.inventory.missRefreshInterval {
+			klog.V(4).Infof("VM inventory of ics=%s refreshed less than %v ago, skipping",
+				instance.Config().ICenterIP, cm.inventory.missRefreshInterval)
+			return nil
+		}
+	}
+	started := time.Now()
+
+	err := cm.connectWithRetry(ctx, instance)
+	if err != nil {
+		klog.Errorf("VM inventory refresh failed to connect to ics=%s: %v", instance.Config().ICenterIP, err)
+		return err
+	}
+
+	datacenterObjs, err := cm.getDatacenters(ctx, instance)
+	if err != nil {
+		klog.Errorf("VM inventory refresh failed to list datacenters in ics=%s: %v", instance.Config().ICenterIP, err)
+		return err
+	}
+
+	numOfVMs := 0
+	vmsByDC := make(map[*icslib.Datacenter][]*icslib.VirtualMachine, len(datacenterObjs))
+	for _, datacenterObj := range datacenterObjs {
+		vms, err := datacenterObj.GetAllVMs(ctx)
+		if err != nil {
+			klog.Errorf("VM inventory refresh failed to list VMs in ics=%s and datacenter=%s: %v",
+				instance.Config().ICenterIP, datacenterObj.Name, err)
+			return err
+		}
+		vmsByDC[datacenterObj] = vms
+		numOfVMs += len(vms)
+	}
+
+	cm.inventory.store(tenantRef, newTenantInventory(instance, started, vmsByDC))
+	klog.V(3).Infof("VM inventory of ics=%s refreshed with %d VMs in %d datacenters",
+		instance.Config().ICenterIP, numOfVMs, len(datacenterObjs))
+
+	return nil
+}
+
+// getDatacenters returns the configured datacenters of the iCenter, or all of
+// them if none are configured.
+func (cm *ConnectionManager) getDatacenters(ctx context.Context, instance *ICSInstance) ([]*icslib.Datacenter, error) {
+	if instance.Config().Datacenters == "" {
+		return icslib.GetAllDatacenter(ctx, instance)
+	}
+
+	var datacenterObjs []*icslib.Datacenter
+	for _, dc := range strings.Split(instance.Config().Datacenters, ",") {
+		dc = strings.TrimSpace(dc)
+		if dc == "" {
+			continue
+		}
+		datacenterObj, err := icslib.GetDatacenter(ctx, instance, dc)
+		if err != nil {
+			return nil, err
+		}
+		datacenterObjs = append(datacenterObjs, datacenterObj)
+	}
+	return datacenterObjs, nil
+}
+
+// RunInventoryRefresh refreshes the VM index every InventoryRefreshInterval
+// until stopCh is closed. It does not block.
+func (cm *ConnectionManager) RunInventoryRefresh(stopCh <-chan struct{}) {
+	go wait.Until(func() {
+		if err := cm.RefreshInventory(context.Background()); err != nil {
+			klog.Warningf("Periodic VM inventory refresh failed: %v", err)
+		}
+	}, InventoryRefreshInterval, stopCh)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+func TestWhichICSandDCByNodeIdByIPAndMAC(t *testing.T) {
+	config, model, cleanup := configFromSim(true)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+
+	// setup
+	vms := model.VMs()
+	vm := vms[len(vms)-1]
+	IP := vm.Nics[0].IP
+	MAC := strings.ToUpper(vm.Nics[0].Mac)
+
+	// context
+	ctx := context.Background()
+
+	info, err := connMgr.WhichICSandDCByNodeID(ctx, IP, FindVMByIP)
+	if err != nil {
+		t.Fatalf("WhichICSandDCByNodeID err=%v", err)
+	}
+	if info.NodeName != IP {
+		t.Errorf("NodeName should be overridden by the IP %s=%s", IP, info.NodeName)
+	}
+	if info.DataCenter.ID != vm.DataCenterID {
+		t.Errorf("Datacenter mismatch %s=%s", vm.DataCenterID, info.DataCenter.ID)
+	}
+
+	info, err = connMgr.WhichICSandDCByNodeID(ctx, MAC, FindVMByMAC)
+	if err != nil {
+		t.Fatalf("WhichICSandDCByNodeID err=%v", err)
+	}
+	if info.NodeName != vm.Name {
+		t.Errorf("VM name mismatch %s=%s", vm.Name, info.NodeName)
+	}
+	if !strings.EqualFold(vm.UUID, info.UUID) {
+		t.Errorf("VM UUID mismatch %s=%s", vm.UUID, info.UUID)
+	}
+}
+
+func TestInventoryRefresh(t *testing.T) {
+	config, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	// Page through the datacenter VM lists.
+	model.PageSize = 1
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+	connMgr.inventory.missRefreshInterval = 0
+
+	// context
+	ctx := context.Background()
+
+	if err := connMgr.RefreshInventory(ctx); err != nil {
+		t.Fatalf("RefreshInventory err=%v", err)
+	}
+	for _, existing := range model.VMs() {
+		if info := connMgr.inventory.find(existing.Name, FindVMByName); info == nil {
+			t.Fatalf("VM %s missing from the inventory", existing.Name)
+		}
+	}
+	existing := model.VMs()[0]
+
+	// A VM created after the refresh is found through a targeted refresh.
+	created := model.AddVM(types.VirtualMachine{
+		Name:         "created-vm",
+		HostID:       existing.HostID,
+		DataCenterID: existing.DataCenterID,
+	})
+	info, err := connMgr.WhichICSandDCByNodeID(ctx, "CREATED-VM", FindVMByName)
+	if err != nil {
+		t.Fatalf("WhichICSandDCByNodeID err=%v", err)
+	}
+	if info.VM.ID != created {
+		t.Errorf("VM ID mismatch %s=%s", created, info.VM.ID)
+	}
+
+	// A removed VM is dropped by the next refresh.
+	if err := model.RemoveVM(created); err != nil {
+		t.Fatal(err)
+	}
+	if err := connMgr.RefreshInventory(ctx); err != nil {
+		t.Fatalf("RefreshInventory err=%v", err)
+	}
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, "created-vm", FindVMByName); err != icslib.ErrNoVMFound {
+		t.Errorf("WhichICSandDCByNodeID err=%v, expected %v", err, icslib.ErrNoVMFound)
+	}
+}
+
+func TestInventoryNegativeCache(t *testing.T) {
+	config, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+	connMgr.inventory.missRefreshInterval = 0
+
+	// context
+	ctx := context.Background()
+
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, "late-vm", FindVMByName); err != icslib.ErrNoVMFound {
+		t.Fatalf("WhichICSandDCByNodeID err=%v, expected %v", err, icslib.ErrNoVMFound)
+	}
+
+	// A VM created after the miss stays missing until the miss expires.
+	existing := model.VMs()[0]
+	model.AddVM(types.VirtualMachine{
+		Name:         "late-vm",
+		HostID:       existing.HostID,
+		DataCenterID: existing.DataCenterID,
+	})
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, "late-vm", FindVMByName); err != icslib.ErrNoVMFound {
+		t.Fatalf("WhichICSandDCByNodeID err=%v, expected the cached %v", err, icslib.ErrNoVMFound)
+	}
+
+	// Misses refresh an iCenter at most once per missRefreshInterval.
+	connMgr.inventory.negativeTTL = 0
+	connMgr.inventory.missRefreshInterval = time.Hour
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, "late-vm", FindVMByName); err != icslib.ErrNoVMFound {
+		t.Fatalf("WhichICSandDCByNodeID err=%v, expected the rate limited %v", err, icslib.ErrNoVMFound)
+	}
+
+	connMgr.inventory.missRefreshInterval = 0
+	if _, err := connMgr.WhichICSandDCByNodeID(ctx, "late-vm", FindVMByName); err != nil {
+		t.Fatalf("WhichICSandDCByNodeID err=%v", err)
+	}
+}
+
+func TestInventoryRefreshSlowICenter(t *testing.T) {
+	config, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	// A second iCenter whose refresh does not finish while the lock is held.
+	slow := *config.ICSCenter[config.Global.ICenterIP]
+	slow.TenantRef = "slow"
+	config.ICSCenter["slow"] = &slow
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+	connMgr.inventory.missRefreshInterval = 0
+
+	lock := connMgr.inventory.refreshLock(slow.TenantRef)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// context
+	ctx := context.Background()
+
+	vm := model.VMs()[0]
+	found := make(chan error, 1)
+	go func() {
+		_, err := connMgr.WhichICSandDCByNodeID(ctx, vm.Name, FindVMByName)
+		found <- err
+	}()
+
+	select {
+	case err := <-found:
+		if err != nil {
+			t.Fatalf("WhichICSandDCByNodeID err=%v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("WhichICSandDCByNodeID waited on the slow iCenter")
+	}
+}
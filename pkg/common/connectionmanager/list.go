@@ -20,7 +20,6 @@ import (
 	"context"
 	"sort"
 	"strings"
-	"time"
 
 	"k8s.io/klog"
 
@@ -33,37 +32,29 @@ func (cm *ConnectionManager) ListAllICSandDCPairs(ctx context.Context) ([]*ListD
 
 	listOfICSAndDCPairs := make([]*ListDiscoveryInfo, 0)
 
-	for _, vsi := range cm.ICSInstanceMap {
+	for _, vsi := range cm.Instances() {
 		var datacenterObjs []*icslib.Datacenter
 
-		var err error
-		for i := 0; i < NumConnectionAttempts; i++ {
-			err = cm.Connect(ctx, vsi)
-			if err == nil {
-				break
-			}
-			time.Sleep(time.Duration(RetryAttemptDelaySecs) * time.Second)
-		}
-
+		err := cm.connectWithRetry(ctx, vsi)
 		if err != nil {
 			klog.Error("Connect error ics:", err)
 			continue
 		}
 
-		if vsi.Cfg.Datacenters == "" {
-			datacenterObjs, err = icslib.GetAllDatacenter(ctx, vsi.Conn)
+		if vsi.Config().Datacenters == "" {
+			datacenterObjs, err = icslib.GetAllDatacenter(ctx, vsi)
 			if err != nil {
 				klog.Error("GetAllDatacenter error dc:", err)
 				continue
 			}
 		} else {
-			datacenters := strings.Split(vsi.Cfg.Datacenters, ",")
+			datacenters := strings.Split(vsi.Config().Datacenters, ",")
 			for _, dc := range datacenters {
 				dc = strings.TrimSpace(dc)
 				if dc == "" {
 					continue
 				}
-				datacenterObj, err := icslib.GetDatacenter(ctx, vsi.Conn, dc)
+				datacenterObj, err := icslib.GetDatacenter(ctx, vsi, dc)
 				if err != nil {
 					klog.Error("GetDatacenter error dc:", err)
 					continue
@@ -74,8 +65,8 @@ func (cm *ConnectionManager) ListAllICSandDCPairs(ctx context.Context) ([]*ListD
 
 		for _, datacenterObj := range datacenterObjs {
 			listOfICSAndDCPairs = append(listOfICSAndDCPairs, &ListDiscoveryInfo{
-				TenantRef:  vsi.Cfg.TenantRef,
-				IcsServer:   vsi.Cfg.ICenterIP,
+				TenantRef:  vsi.Config().TenantRef,
+				IcsServer:   vsi.Config().ICenterIP,
 				DataCenter: datacenterObj,
 			})
 		}
@@ -23,27 +23,36 @@ import (
 	"testing"
 
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icssim"
 )
 
-// configFromSim starts a vcsim instance and returns config for use against the vcsim instance.
-// The vcsim instance is configured with an empty tls.Config.
-func configFromSim(multiDc bool) (*icfg.Config, func()) {
+// configFromSim starts an icssim instance with the default inventory and returns config for use
+// against it. The icssim instance is configured with an empty tls.Config.
+func configFromSim(multiDc bool) (*icfg.Config, *icssim.Model, func()) {
 	return configFromSimWithTLS(new(tls.Config), true, multiDc)
 }
 
-// configFromSimWithTLS starts a vcsim instance and returns config for use against the vcsim instance.
-// The vcsim instance is configured with a tls.Config. The returned client
+// configFromSimWithTLS starts an icssim instance and returns config for use against it.
+// The icssim instance is configured with a tls.Config. The returned client
 // config can be configured to allow/decline insecure connections.
-func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc bool) (*icfg.Config, func()) {
+func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc bool) (*icfg.Config, *icssim.Model, func()) {
 	cfg := &icfg.Config{}
-	var HostName = "127.0.0.1"
+	model := icssim.VPX()
+	if multiDc {
+		model.Datacenter = 2
+	}
+	model.Create()
+
+	s := icssim.NewUnstartedServer(model)
+	s.TLS = tlsConfig
+	s.StartTLS()
 
 	cfg.Global.InsecureFlag = insecureAllowed
 
-	cfg.Global.ICenterIP = HostName
-	cfg.Global.ICenterPort = "443"
-	cfg.Global.User = "admin"
-	cfg.Global.Password = "123456"
+	cfg.Global.ICenterIP = s.Host()
+	cfg.Global.ICenterPort = s.Port()
+	cfg.Global.User = model.Username
+	cfg.Global.Password = model.Password
 
 	if multiDc {
 		cfg.Global.Datacenters = "DC0,DC1"
@@ -51,27 +60,30 @@ func configFromSimWithTLS(tlsConfig *tls.Config, insecureAllowed bool, multiDc b
 		cfg.Global.Datacenters = "DC0"
 	}
 	cfg.ICSCenter = make(map[string]*icfg.ICSCenterConfig)
-	cfg.ICSCenter[HostName] = &icfg.ICSCenterConfig{
-		User:         cfg.Global.User,
-		Password:     cfg.Global.Password,
-		TenantRef:    cfg.Global.ICenterIP,
-		ICenterIP:    cfg.Global.ICenterIP,
-		ICenterPort:  cfg.Global.ICenterPort,
-		InsecureFlag: cfg.Global.InsecureFlag,
-		Datacenters:  cfg.Global.Datacenters,
+	cfg.ICSCenter[s.Host()] = &icfg.ICSCenterConfig{
+		User:             cfg.Global.User,
+		Password:         cfg.Global.Password,
+		TenantRef:        cfg.Global.ICenterIP,
+		ICenterIP:        cfg.Global.ICenterIP,
+		ICenterPort:      cfg.Global.ICenterPort,
+		InsecureFlag:     cfg.Global.InsecureFlag,
+		Datacenters:      cfg.Global.Datacenters,
+		IPFamilyPriority: []string{icfg.DefaultIPFamily},
 	}
 
 	// Configure region and zone categories
 	cfg.Labels.Region = "k8s-region"
 	cfg.Labels.Zone = "k8s-zone"
 
-	return cfg, func() {}
+	return cfg, model, s.Close
 }
 
+// configFromEnvOrSim returns config from configFromEnv if set, otherwise returns configFromSim.
 func configFromEnvOrSim(multiDc bool) (*icfg.Config, func()) {
 	cfg := &icfg.Config{}
 	if err := cfg.FromEnv(); err != nil {
-		return configFromSim(multiDc)
+		cfg, _, cleanup := configFromSim(multiDc)
+		return cfg, cleanup
 	}
 	return cfg, func() {}
 }
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+// proxyFunc returns the URL of the proxy of the requests to the URL, nil to
+// reach it directly.
+type proxyFunc func(*url.URL) (*url.URL, error)
+
+// newProxyFunc returns the proxyFunc of the connections to the iCenter. The
+// proxy-url and no-proxy options override the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables. As with those, requests to localhost are
+// never proxied.
+func newProxyFunc(cfg *icfg.ICSCenterConfig) proxyFunc {
+	proxyConfig := httpproxy.FromEnvironment()
+	if cfg.ProxyURL != "" {
+		proxyConfig.HTTPProxy = cfg.ProxyURL
+		proxyConfig.HTTPSProxy = cfg.ProxyURL
+	}
+	if cfg.NoProxy != "" {
+		proxyConfig.NoProxy = cfg.NoProxy
+	}
+	return proxyConfig.ProxyFunc()
+}
+
+// proxyFor returns the URL of the proxy of the connections to the endpoint,
+// nil if it is reached directly.
+func (icsInstance *ICSInstance) proxyFor(endpoint icfg.ICSCenterEndpoint) (*url.URL, error) {
+	if icsInstance.proxy == nil {
+		return nil, nil
+	}
+	return icsInstance.proxy(&url.URL{Scheme: "https", Host: endpoint.String()})
+}
+
+// httpProxy adapts the proxyFunc to http.Transport.
+func (f proxyFunc) httpProxy() func(*http.Request) (*url.URL, error) {
+	if f == nil {
+		return nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		return f(req.URL)
+	}
+}
+
+// proxyAddr returns the HOST:PORT address of the proxy, with the default
+// port of its scheme if it has none.
+func proxyAddr(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "80"
+	switch proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5":
+		port = "1080"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+// proxyHost is a host only the test proxy resolves, to 127.0.0.1.
+const proxyHost = "icenter.test"
+
+// newConnectProxy starts an HTTP proxy tunneling CONNECT requests to
+// proxyHost, counting the tunnels.
+func newConnectProxy(tunnels *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, _ := net.SplitHostPort(r.Host)
+		if r.Method != http.MethodConnect || host != proxyHost {
+			http.Error(w, "unexpected request", http.StatusBadGateway)
+			return
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestProxy(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+	var tunnels int32
+	proxy := newConnectProxy(&tunnels)
+	defer proxy.Close()
+
+	// The iCenter can only be reached through the proxy.
+	icsConfig := cfg.ICSCenter[cfg.Global.ICenterIP]
+	icsConfig.ICenterIP = proxyHost
+	icsConfig.ICenterEndpoints = []icfg.ICSCenterEndpoint{{Host: proxyHost, Port: icsConfig.ICenterPort}}
+	icsConfig.ProxyURL = proxy.URL
+	icsConfig.NoProxy = "10.0.0.0/8"
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+
+	if err := connMgr.Connect(context.Background(), icsInstance); err != nil {
+		t.Fatalf("Connect through the proxy err=%v", err)
+	}
+	if atomic.LoadInt32(&tunnels) == 0 {
+		t.Error("Connect did not go through the proxy")
+	}
+	if result := connMgr.VerifyAll(context.Background(), "", "")[0]; result.Err != nil || !result.Reachable ||
+		!result.TLS || !result.CredentialsValid {
+		t.Errorf("unexpected result through the proxy %+v", result)
+	}
+
+	// The proxy is down.
+	proxy.Close()
+	icsInstance.setClient(nil)
+	if err := connMgr.Connect(context.Background(), icsInstance); err == nil {
+		t.Error("Connect succeeded with the proxy down")
+	}
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+		} else {
+			defer os.Unsetenv(name)
+		}
+		os.Unsetenv(name)
+	}
+	os.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	os.Setenv("NO_PROXY", "10.0.0.2")
+
+	for _, test := range []struct {
+		name     string
+		cfg      icfg.ICSCenterConfig
+		host     string
+		expected string
+	}{
+		{"environment", icfg.ICSCenterConfig{}, "10.0.0.1", "http://env-proxy:3128"},
+		{"environment no proxy", icfg.ICSCenterConfig{}, "10.0.0.2", ""},
+		{"proxy-url", icfg.ICSCenterConfig{ProxyURL: "socks5://proxy:1080"}, "10.0.0.1", "socks5://proxy:1080"},
+		{"no-proxy", icfg.ICSCenterConfig{NoProxy: ".example.com, 10.0.0.0/8"}, "10.0.0.2", ""},
+		{"no-proxy domain", icfg.ICSCenterConfig{NoProxy: ".example.com"}, "icenter.example.com", ""},
+		{"no-proxy replaces NO_PROXY", icfg.ICSCenterConfig{NoProxy: ".example.com"}, "10.0.0.2", "http://env-proxy:3128"},
+		{"localhost", icfg.ICSCenterConfig{ProxyURL: "http://proxy"}, "127.0.0.1", ""},
+	} {
+		icsInstance := &ICSInstance{Cfg: &test.cfg, proxy: newProxyFunc(&test.cfg)}
+		proxyURL, err := icsInstance.proxyFor(icfg.ICSCenterEndpoint{Host: test.host, Port: "443"})
+		if err != nil {
+			t.Errorf("%s: err=%v", test.name, err)
+			continue
+		}
+		actual := ""
+		if proxyURL != nil {
+			actual = proxyURL.String()
+		}
+		if actual != test.expected {
+			t.Errorf("%s: proxy of %s = %q, expected %q", test.name, test.host, actual, test.expected)
+		}
+	}
+}
+
+func TestProxyAddr(t *testing.T) {
+	for proxyURL, expected := range map[string]string{
+		"http://proxy":        "proxy:80",
+		"https://proxy":       "proxy:443",
+		"socks5://proxy":      "proxy:1080",
+		"http://proxy:3128":   "proxy:3128",
+		"http://[fd00::1]":    "[fd00::1]:80",
+		"socks5://proxy:1081": "proxy:1081",
+	} {
+		u, _ := url.Parse(proxyURL)
+		if addr := proxyAddr(u); addr != expected {
+			t.Errorf("proxyAddr(%s) = %s, expected %s", proxyURL, addr, expected)
+		}
+	}
+}
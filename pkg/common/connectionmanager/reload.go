@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"k8s.io/klog"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+)
+
+// ReloadResult lists the tenantRefs of the iCenters a reload changed.
+type ReloadResult struct {
+	Added   []string
+	Removed []string
+	// Updated iCenters keep their instance and session, only their
+	// credentials, datacenters or other settings unrelated to the connection
+	// changed.
+	Updated []string
+	// Reconnected iCenters were replaced by a new, not yet connected,
+	// instance as their endpoints, TLS or proxy settings changed.
+	Reconnected []string
+}
+
+// Changed returns true if the reload changed any iCenter.
+func (result ReloadResult) Changed() bool {
+	return len(result.Added)+len(result.Removed)+len(result.Updated)+len(result.Reconnected) > 0
+}
+
+// Reload reconciles the iCenters with the new configuration, validated by
+// ReadConfig. The sessions of the removed and reconnected iCenters are
+// logged out, and their VM inventory and ConnectionStatus dropped. Call
+// InitializeSecretLister afterwards for the secrets not seen before.
+func (connMgr *ConnectionManager) Reload(cfg *icfg.Config) ReloadResult {
+	var result ReloadResult
+	var stale []*ICSInstance
+
+	connMgr.instancesLock.Lock()
+	instances := make(map[string]*ICSInstance, len(cfg.ICSCenter))
+	for _, icsConfig := range cfg.ICSCenter {
+		tenantRef := icsConfig.TenantRef
+		previous, ok := connMgr.ICSInstanceMap[tenantRef]
+		switch {
+		case !ok:
+			instances[tenantRef] = newICSInstance(icsConfig)
+			result.Added = append(result.Added, tenantRef)
+		case reflect.DeepEqual(previous.Config(), icsConfig):
+			instances[tenantRef] = previous
+		case sameConnection(previous.Config(), icsConfig):
+			previous.updateConfig(icsConfig)
+			instances[tenantRef] = previous
+			result.Updated = append(result.Updated, tenantRef)
+		default:
+			// The endpoints may have changed, so the series start over.
+			metrics.ForgetTenant(tenantRef)
+			instances[tenantRef] = newICSInstance(icsConfig)
+			stale = append(stale, previous)
+			result.Reconnected = append(result.Reconnected, tenantRef)
+		}
+	}
+	for tenantRef, previous := range connMgr.ICSInstanceMap {
+		if _, ok := instances[tenantRef]; !ok {
+			stale = append(stale, previous)
+			result.Removed = append(result.Removed, tenantRef)
+			metrics.ForgetTenant(tenantRef)
+		}
+	}
+	connMgr.ICSInstanceMap = instances
+	connMgr.instancesLock.Unlock()
+
+	for _, icsInstance := range stale {
+		tenantRef := icsInstance.Config().TenantRef
+		connMgr.inventory.remove(tenantRef)
+		connMgr.statusLock.Lock()
+		delete(connMgr.statuses, tenantRef)
+		connMgr.statusLock.Unlock()
+		if session := icsInstance.Connection(); session != nil {
+			if err := session.Logout(context.TODO()); err != nil {
+				klog.Warningf("Failed to log out of iCenter %s: %v", tenantRef, err)
+			}
+		}
+	}
+
+	for _, tenantRefs := range [][]string{result.Added, result.Removed, result.Updated, result.Reconnected} {
+		sort.Strings(tenantRefs)
+	}
+	klog.Infof("Reloaded iCenters, added %v, removed %v, updated %v, reconnected %v",
+		result.Added, result.Removed, result.Updated, result.Reconnected)
+	return result
+}
+
+// sameConnection returns true if the connections to the iCenter are the same
+// with both configurations, if only the credentials may differ.
+func sameConnection(a *icfg.ICSCenterConfig, b *icfg.ICSCenterConfig) bool {
+	return reflect.DeepEqual(instanceEndpoints(a), instanceEndpoints(b)) &&
+		a.InsecureFlag == b.InsecureFlag &&
+		a.CAFile == b.CAFile &&
+		a.CAData == b.CAData &&
+		a.Thumbprint == b.Thumbprint &&
+		a.ProxyURL == b.ProxyURL &&
+		a.NoProxy == b.NoProxy
+}
+
+// updateConfig replaces the configuration of icsInstance, keeping its
+// session and the endpoint it uses. The credentials set in the
+// configuration, rather than in a secret, are updated for the next login.
+func (icsInstance *ICSInstance) updateConfig(cfg *icfg.ICSCenterConfig) {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+
+	if cfg.User != icsInstance.Cfg.User || cfg.Password != icsInstance.Cfg.Password {
+		if cfg.User != "" && cfg.Password != "" {
+			icsInstance.Conn.UpdateCredentials(cfg.User, cfg.Password)
+		}
+	}
+	icsInstance.Cfg = cfg
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+// copyConfig returns a copy of cfg whose iCenter configs can be changed.
+func copyConfig(cfg *icfg.Config) *icfg.Config {
+	copied := *cfg
+	copied.ICSCenter = make(map[string]*icfg.ICSCenterConfig)
+	for key, icsConfig := range cfg.ICSCenter {
+		c := *icsConfig
+		copied.ICSCenter[key] = &c
+	}
+	return &copied
+}
+
+func TestReload(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+	otherCfg, _, otherCleanup := configFromSim(false)
+	defer otherCleanup()
+	anotherCfg, _, anotherCleanup := configFromSim(false)
+	defer anotherCleanup()
+	updated := cfg.Global.ICenterIP
+	reconnected := addICenter(cfg, net.JoinHostPort(otherCfg.Global.ICenterIP, otherCfg.Global.ICenterPort))
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	for _, icsInstance := range connMgr.Instances() {
+		if err := connMgr.Connect(context.Background(), icsInstance); err != nil {
+			t.Fatalf("Connect to %s err=%v", icsInstance.Cfg.TenantRef, err)
+		}
+	}
+	updatedInstance := connMgr.Instances()[updated]
+	reconnectedInstance := connMgr.Instances()[reconnected]
+
+	// Unchanged
+	if result := connMgr.Reload(copyConfig(cfg)); result.Changed() {
+		t.Errorf("Reload of the same config changed %+v", result)
+	}
+	if connMgr.Instances()[updated] != updatedInstance {
+		t.Error("Reload of the same config replaced the instance")
+	}
+
+	newCfg := copyConfig(cfg)
+	newCfg.ICSCenter[updated].Datacenters = "DC0, DC1"
+	newCfg.ICSCenter[updated].Password = "rotated"
+	newCfg.ICSCenter[reconnected].InsecureFlag = false
+	added := addICenter(newCfg, net.JoinHostPort(anotherCfg.Global.ICenterIP, anotherCfg.Global.ICenterPort))
+
+	result := connMgr.Reload(newCfg)
+	expected := ReloadResult{Added: []string{added}, Updated: []string{updated}, Reconnected: []string{reconnected}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Reload result %+v, expected %+v", result, expected)
+	}
+
+	instances := connMgr.Instances()
+	if len(instances) != 3 || instances[added] == nil {
+		t.Errorf("instances %v, expected %s to be added", instances, added)
+	}
+
+	// The updated iCenter keeps its instance and session, the new password
+	// is used on the next login.
+	instance := instances[updated]
+	if instance != updatedInstance || instance.Config().Datacenters != "DC0, DC1" {
+		t.Errorf("updated instance %+v was replaced or kept the old config", instance)
+	}
+	if !validSession(context.Background(), instance.Client()) {
+		t.Error("the session of the updated iCenter was lost")
+	}
+	if instance.Conn.Password != "rotated" {
+		t.Errorf("password %s, expected the rotated one", instance.Conn.Password)
+	}
+
+	// The reconnected iCenter has a new, secure, connection.
+	instance = instances[reconnected]
+	if instance == reconnectedInstance || instance.Client() != nil || instance.Conn.Insecure {
+		t.Errorf("reconnected instance %+v, expected a new secure connection", instance)
+	}
+	if validSession(context.Background(), reconnectedInstance.Client()) {
+		t.Error("the session of the reconnected iCenter was not logged out")
+	}
+
+	// Removed
+	if err := connMgr.Connect(context.Background(), instances[added]); err != nil {
+		t.Fatalf("Connect to %s err=%v", added, err)
+	}
+	delete(newCfg.ICSCenter, added)
+	result = connMgr.Reload(newCfg)
+	if !reflect.DeepEqual(result, ReloadResult{Removed: []string{added}}) {
+		t.Errorf("Reload result %+v, expected %s to be removed", result, added)
+	}
+	if _, ok := connMgr.Instances()[added]; ok {
+		t.Errorf("%s was not removed", added)
+	}
+	for _, status := range connMgr.ConnectionStatuses() {
+		if status.TenantRef == added {
+			t.Errorf("the status of %s was not removed", added)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// DefaultBackoff is the retry policy of the connections to iCenter:
+// NumConnectionAttempts attempts, waiting RetryAttemptDelay after the first
+// failure and twice as long after each next one, up to MaxRetryAttemptDelay.
+var DefaultBackoff = wait.Backoff{
+	Duration: RetryAttemptDelay,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    NumConnectionAttempts,
+	Cap:      MaxRetryAttemptDelay,
+}
+
+// retry calls f up to backoff.Steps times until it succeeds, sleeping for
+// the backoff between attempts. It stops early when ctx is done or when
+// ErrCircuitOpen makes retrying pointless.
+func retry(ctx context.Context, backoff wait.Backoff, f func() error) error {
+	attempts := backoff.Steps
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || err == ErrCircuitOpen || attempt >= attempts {
+			return err
+		}
+
+		delay := backoff.Step()
+		klog.V(4).Infof("Attempt %d of %d failed, retrying in %v: %v", attempt, attempts, delay, err)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// connectWithRetry connects to the iCenter, retrying with the backoff of
+// the ConnectionManager.
+func (connMgr *ConnectionManager) connectWithRetry(ctx context.Context, icsInstance *ICSInstance) error {
+	return retry(ctx, connMgr.backoff, func() error {
+		return connMgr.Connect(ctx, icsInstance)
+	})
+}
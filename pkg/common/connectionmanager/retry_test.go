@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestRetry(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3}
+	failed := errors.New("failed")
+
+	for _, test := range []struct {
+		name     string
+		errs     []error
+		expected error
+		calls    int
+	}{
+		{"success", []error{nil}, nil, 1},
+		{"success after failures", []error{failed, failed, nil}, nil, 3},
+		{"attempts exhausted", []error{failed, failed, failed, nil}, failed, 3},
+		{"circuit open", []error{ErrCircuitOpen, nil}, ErrCircuitOpen, 1},
+	} {
+		calls := 0
+		err := retry(context.Background(), backoff, func() error {
+			calls++
+			return test.errs[calls-1]
+		})
+		if err != test.expected || calls != test.calls {
+			t.Errorf("%s: err=%v after %d calls, expected %v after %d", test.name, err, calls, test.expected, test.calls)
+		}
+	}
+
+	// A done context stops waiting for the next attempt.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := retry(ctx, wait.Backoff{Duration: time.Hour, Steps: 3}, func() error {
+		calls++
+		return failed
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("err=%v after %d calls with a done context, expected %v after 1", err, calls, context.Canceled)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := addICenter(cfg, l.Addr().String())
+	l.Close()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	ctx := context.Background()
+
+	icsInstance := connMgr.ICSInstanceMap[unreachable]
+	breaker := icsInstance.breaker
+	breaker.threshold = 2
+	breaker.openDuration = 100 * time.Millisecond
+
+	for i := 0; i < breaker.threshold; i++ {
+		if err := connMgr.Connect(ctx, icsInstance); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Connect #%d to an unreachable iCenter err=%v", i, err)
+		}
+	}
+	if err := connMgr.Connect(ctx, icsInstance); err != ErrCircuitOpen {
+		t.Errorf("Connect after %d failures err=%v, expected %v", breaker.threshold, err, ErrCircuitOpen)
+	}
+	// Retrying is pointless while the circuit is open.
+	start := time.Now()
+	if err := connMgr.connectWithRetry(ctx, icsInstance); err != ErrCircuitOpen || time.Since(start) > time.Second {
+		t.Errorf("connectWithRetry err=%v after %v, expected %v at once", err, time.Since(start), ErrCircuitOpen)
+	}
+
+	// Once open long enough, a single failed attempt opens the circuit again.
+	time.Sleep(breaker.openDuration)
+	if err := connMgr.Connect(ctx, icsInstance); err == nil || err == ErrCircuitOpen {
+		t.Errorf("Connect with a half-open circuit err=%v", err)
+	}
+	if err := connMgr.Connect(ctx, icsInstance); err != ErrCircuitOpen {
+		t.Errorf("Connect after a failed half-open attempt err=%v, expected %v", err, ErrCircuitOpen)
+	}
+
+	// The iCenter is back, at the address of the simulator.
+	icsInstance.endpoints = instanceEndpoints(cfg.ICSCenter[cfg.Global.ICenterIP])
+	time.Sleep(breaker.openDuration)
+	if err := connMgr.Connect(ctx, icsInstance); err != nil {
+		t.Fatalf("Connect to a recovered iCenter err=%v", err)
+	}
+	if breaker.state != circuitClosed || breaker.failures != 0 {
+		t.Errorf("circuit %v with %d failures after recovering, expected closed", breaker.state, breaker.failures)
+	}
+}
@@ -36,190 +36,81 @@ func (f FindVM) String() string {
 		return "byName"
 	case FindVMByIP:
 		return "byIP"
+	case FindVMByMAC:
+		return "byMAC"
 	default:
 		return "byUnknown"
 	}
 }
 
-// WhichICSandDCByNodeID finds the ICS/DC combo that owns a particular VM
+// WhichICSandDCByNodeID finds the ICS/DC combo that owns a particular VM.
+// The VM is looked up in the inventory index. A miss triggers a single
+// refresh of the index, shared with any concurrent misses, before giving up.
+// A node that was not found is reported missing without refreshing again
+// for InventoryNegativeCacheTTL.
 func (cm *ConnectionManager) WhichICSandDCByNodeID(ctx context.Context, nodeID string, searchBy FindVM) (*VMDiscoveryInfo, error) {
 	if nodeID == "" {
 		klog.V(3).Info("WhichICSandDCByNodeID called but nodeID is empty")
 		return nil, icslib.ErrNoVMFound
 	}
-	type vmSearch struct {
-		tenantRef  string
-		ics        string
-		datacenter *icslib.Datacenter
-	}
 
-	var mutex = &sync.Mutex{}
-	var globalErrMutex = &sync.Mutex{}
-	var queueChannel chan *vmSearch
-	var wg sync.WaitGroup
-	var globalErr *error
-
-	queueChannel = make(chan *vmSearch, QueueSize)
-
-	myNodeID := nodeID
+	myNodeID := strings.TrimSpace(nodeID)
 	switch searchBy {
 	case FindVMByUUID:
 		klog.V(3).Info("WhichICSandDCByNodeID by UUID")
-		myNodeID = strings.TrimSpace(strings.ToLower(nodeID))
+		myNodeID = strings.ToLower(myNodeID)
 	case FindVMByIP:
 		klog.V(3).Info("WhichICSandDCByNodeID by IP")
+	case FindVMByMAC:
+		klog.V(3).Info("WhichICSandDCByNodeID by MAC")
 	default:
 		klog.V(3).Info("WhichICSandDCByNodeID by Name")
+		searchBy = FindVMByName
 	}
 	klog.V(2).Info("WhichICSandDCByNodeID nodeID: ", myNodeID)
 
-	vmFound := false
-	globalErr = nil
-
-	setGlobalErr := func(err error) {
-		globalErrMutex.Lock()
-		globalErr = &err
-		globalErrMutex.Unlock()
-	}
-
-	setVMFound := func(found bool) {
-		mutex.Lock()
-		vmFound = found
-		mutex.Unlock()
+	missed := time.Now()
+	vmInfo := cm.inventory.find(myNodeID, searchBy)
+	if vmInfo == nil && cm.inventory.missedRecently(myNodeID, searchBy) {
+		klog.V(4).Infof("WhichICSandDCByNodeID: %q vm recently not found", myNodeID)
+		return nil, icslib.ErrNoVMFound
 	}
-
-	getVMFound := func() bool {
-		mutex.Lock()
-		found := vmFound
-		mutex.Unlock()
-		return found
+	var err error
+	if vmInfo == nil {
+		klog.V(2).Infof("Node %s(%s) not in the VM inventory, refreshing", myNodeID, searchBy)
+		err = cm.refreshInventorySince(ctx, missed, func() bool {
+			vmInfo = cm.inventory.find(myNodeID, searchBy)
+			return vmInfo != nil
+		})
+		if vmInfo == nil {
+			vmInfo = cm.inventory.find(myNodeID, searchBy)
+		}
 	}
 
-	go func() {
-		for _, instance := range cm.ICSInstanceMap {
-			var datacenterObjs []*icslib.Datacenter
-
-			if getVMFound() {
-				break
-			}
-
-			var err error
-			for i := 0; i < NumConnectionAttempts; i++ {
-				err = cm.Connect(ctx, instance)
-				if err == nil {
-					break
-				}
-				time.Sleep(time.Duration(RetryAttemptDelaySecs) * time.Second)
-			}
-
-			if err != nil {
-				klog.Errorf("WhichICSandDCByNodeID error ics:%+v  err:%v\n", instance.Cfg, err)
-				setGlobalErr(err)
-				continue
-			}
-
-			if instance.Cfg.Datacenters == "" {
-				datacenterObjs, err = icslib.GetAllDatacenter(ctx, instance.Conn)
-				if err != nil {
-					klog.Error("WhichICSandDCByNodeID error dc:", err)
-					setGlobalErr(err)
-					continue
-				}
-			} else {
-				datacenters := strings.Split(instance.Cfg.Datacenters, ",")
-				for _, dc := range datacenters {
-					dc = strings.TrimSpace(dc)
-					if dc == "" {
-						continue
-					}
-					datacenterObj, err := icslib.GetDatacenter(ctx, instance.Conn, dc)
-					if err != nil {
-						klog.Error("WhichICSandDCByNodeID error dc:", err)
-						setGlobalErr(err)
-						continue
-					}
-					datacenterObjs = append(datacenterObjs, datacenterObj)
-				}
-			}
-
-			for _, datacenterObj := range datacenterObjs {
-				if getVMFound() {
-					break
-				}
-
-				klog.V(4).Infof("Finding node %s in ics=%s and datacenter=%s", myNodeID, instance.Cfg.ICenterIP, datacenterObj.Name)
-				queueChannel <- &vmSearch{
-					tenantRef:  instance.Cfg.TenantRef,
-					ics:        instance.Cfg.ICenterIP,
-					datacenter: datacenterObj,
-				}
-			}
+	if vmInfo == nil {
+		if err != nil {
+			return nil, err
 		}
-		close(queueChannel)
-	}()
-
-	var vmInfo *VMDiscoveryInfo
-	for i := 0; i < PoolSize; i++ {
-		wg.Add(1)
-		go func() {
-			for res := range queueChannel {
-				var vm *icslib.VirtualMachine
-				var err error
-
-				switch searchBy {
-				case FindVMByUUID:
-					vm, err = res.datacenter.GetVMByUUID(ctx, myNodeID)
-				case FindVMByIP:
-					vm, err = res.datacenter.GetVMByIP(ctx, myNodeID)
-				default:
-					vm, err = res.datacenter.GetVMByDNSName(ctx, myNodeID)
-				}
-
-				if err != nil || vm == nil {
-					klog.Errorf("Error while looking for vm=%s(%s) in ics=%s and datacenter=%s: %v",
-						myNodeID, searchBy, res.ics, res.datacenter.Name, err)
-					if err != icslib.ErrNoVMFound {
-						setGlobalErr(err)
-					} else {
-						klog.V(2).Infof("Did not find node %s in ics=%s and datacenter=%s",
-							myNodeID, res.ics, res.datacenter.Name)
-					}
-					continue
-				}
-
-				klog.V(5).Infof("ICS GetVMBy %s, vm=%+v and datacenter=%+v",
-					searchBy, vm.VirtualMachine, vm.Datacenter)
-
-				hostName := vm.VirtualMachine.Name
-				if searchBy == FindVMByIP {
-					klog.V(2).Infof("WhichICSandDCByNodeID by IP. Overriding VMName from=%s to to=%s", vm.VirtualMachine.Name, myNodeID)
-					hostName = myNodeID
-				}
-
-				UUID := strings.ToLower(strings.TrimSpace(vm.VirtualMachine.UUID))
+		cm.inventory.recordMiss(myNodeID, searchBy)
+		klog.V(4).Infof("WhichICSandDCByNodeID: %q vm not found", myNodeID)
+		return nil, icslib.ErrNoVMFound
+	}
 
-				klog.V(2).Infof("Found node %s as vm=%+v in ics=%s and datacenter=%s",
-					nodeID, vm.VirtualMachine, res.ics, res.datacenter.Name)
-				klog.V(2).Infof("Hostname: %s, UUID: %s", hostName, UUID)
+	klog.V(5).Infof("ICS GetVMBy %s, vm=%+v and datacenter=%+v",
+		searchBy, vmInfo.VM.VirtualMachine, vmInfo.DataCenter)
 
-				vmInfo = &VMDiscoveryInfo{TenantRef: res.tenantRef, DataCenter: res.datacenter, VM: vm, IcsServer: res.ics,
-					UUID: UUID, NodeName: hostName}
-				setVMFound(true)
-				break
-			}
-			wg.Done()
-		}()
-	}
-	wg.Wait()
-	if vmFound {
-		return vmInfo, nil
-	}
-	if globalErr != nil {
-		return nil, *globalErr
+	// The index is shared, hand out a copy.
+	found := *vmInfo
+	if searchBy == FindVMByIP {
+		klog.V(2).Infof("WhichICSandDCByNodeID by IP. Overriding VMName from=%s to to=%s", found.NodeName, myNodeID)
+		found.NodeName = myNodeID
 	}
 
-	klog.V(4).Infof("WhichICSandDCByNodeID: %q vm not found", myNodeID)
-	return nil, icslib.ErrNoVMFound
+	klog.V(2).Infof("Found node %s as vm=%+v in ics=%s and datacenter=%s",
+		nodeID, found.VM.VirtualMachine, found.IcsServer, found.DataCenter.Name)
+	klog.V(2).Infof("Hostname: %s, UUID: %s", found.NodeName, found.UUID)
+
+	return &found, nil
 }
 
 // WhichICSandDCByFCDId searches for an FCD using the provided ID.
@@ -268,43 +159,35 @@ func (cm *ConnectionManager) WhichICSandDCByFCDId(ctx context.Context, fcdID str
 	}
 
 	go func() {
-		for _, instance := range cm.ICSInstanceMap {
+		for _, instance := range cm.Instances() {
 			var datacenterObjs []*icslib.Datacenter
 
 			if getFCDFound() {
 				break
 			}
 
-			var err error
-			for i := 0; i < NumConnectionAttempts; i++ {
-				err = cm.Connect(ctx, instance)
-				if err == nil {
-					break
-				}
-				time.Sleep(time.Duration(RetryAttemptDelaySecs) * time.Second)
-			}
-
+			err := cm.connectWithRetry(ctx, instance)
 			if err != nil {
 				klog.Error("WhichICSandDCByFCDId error ics:", err)
 				setGlobalErr(err)
 				continue
 			}
 
-			if instance.Cfg.Datacenters == "" {
-				datacenterObjs, err = icslib.GetAllDatacenter(ctx, instance.Conn)
+			if instance.Config().Datacenters == "" {
+				datacenterObjs, err = icslib.GetAllDatacenter(ctx, instance)
 				if err != nil {
 					klog.Error("WhichICSandDCByFCDId error dc:", err)
 					setGlobalErr(err)
 					continue
 				}
 			} else {
-				datacenters := strings.Split(instance.Cfg.Datacenters, ",")
+				datacenters := strings.Split(instance.Config().Datacenters, ",")
 				for _, dc := range datacenters {
 					dc = strings.TrimSpace(dc)
 					if dc == "" {
 						continue
 					}
-					datacenterObj, err := icslib.GetDatacenter(ctx, instance.Conn, dc)
+					datacenterObj, err := icslib.GetDatacenter(ctx, instance, dc)
 					if err != nil {
 						klog.Error("WhichICSandDCByFCDId error dc:", err)
 						setGlobalErr(err)
@@ -319,10 +202,10 @@ func (cm *ConnectionManager) WhichICSandDCByFCDId(ctx context.Context, fcdID str
 					break
 				}
 
-				klog.V(4).Infof("Finding FCD %s in ics=%s and datacenter=%s", fcdID, instance.Cfg.ICenterIP, datacenterObj.Name)
+				klog.V(4).Infof("Finding FCD %s in ics=%s and datacenter=%s", fcdID, instance.Config().ICenterIP, datacenterObj.Name)
 				queueChannel <- &fcdSearch{
-					tenantRef:  instance.Cfg.TenantRef,
-					ics:        instance.Cfg.ICenterIP,
+					tenantRef:  instance.Config().TenantRef,
+					ics:        instance.Config().ICenterIP,
 					datacenter: datacenterObj,
 				}
 			}
@@ -0,0 +1,254 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+	icsgo "github.com/inspur-ics/ics-go-sdk"
+	rest "github.com/inspur-ics/ics-go-sdk/client"
+	"github.com/inspur-ics/ics-go-sdk/client/restful"
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	"github.com/inspur-ics/ics-go-sdk/session"
+	"k8s.io/klog"
+)
+
+// connectCall is a connection to an iCenter in progress, shared by every
+// caller of Connect until it is done.
+type connectCall struct {
+	done chan struct{}
+	err  error
+}
+
+// connectOnce starts connect unless a connection to the iCenter is already
+// in progress, and returns the call in progress.
+func (icsInstance *ICSInstance) connectOnce(connect func() error) *connectCall {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+
+	if icsInstance.connecting != nil {
+		return icsInstance.connecting
+	}
+
+	call := &connectCall{done: make(chan struct{})}
+	icsInstance.connecting = call
+	go func() {
+		call.err = connect()
+
+		icsInstance.lock.Lock()
+		icsInstance.connecting = nil
+		icsInstance.lock.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// Config returns the current configuration of the iCenter. Reload may
+// replace it, but never modifies it.
+func (icsInstance *ICSInstance) Config() *icfg.ICSCenterConfig {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+	return icsInstance.Cfg
+}
+
+// Connection returns the connection of the current session to the iCenter,
+// nil if there is none. It is never modified, so calls made with it are not
+// affected by the next login.
+func (icsInstance *ICSInstance) Connection() *icsgo.ICSConnection {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+	return icsInstance.session
+}
+
+// Client returns the client of the current session to the iCenter, nil if
+// there is none.
+func (icsInstance *ICSInstance) Client() *rest.Client {
+	if session := icsInstance.Connection(); session != nil {
+		return session.Client
+	}
+	return nil
+}
+
+// setClient replaces the session with the one of client, or drops it if
+// client is nil, and returns the client of the previous session.
+func (icsInstance *ICSInstance) setClient(client *rest.Client) *rest.Client {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+
+	var previous *rest.Client
+	if icsInstance.session != nil {
+		previous = icsInstance.session.Client
+	}
+	icsInstance.session = nil
+	if client != nil {
+		conn := icsInstance.Conn
+		icsInstance.session = &icsgo.ICSConnection{
+			Client:   client,
+			Hostname: conn.Hostname,
+			Port:     conn.Port,
+			Insecure: conn.Insecure,
+		}
+		metrics.SetTenantRef(icsInstance.session, icsInstance.Cfg.TenantRef)
+	}
+	return previous
+}
+
+// validSession returns true if iCenter still accepts the session of the
+// client. Unlike the SDK Connect, it does not take the SDK lock that
+// serializes the logins to every iCenter.
+func validSession(ctx context.Context, client *rest.Client) bool {
+	if client == nil {
+		return false
+	}
+	userSession, err := session.NewManager(client).UserSession(ctx)
+	return err == nil && userSession != nil
+}
+
+// dial checks that the iCenter endpoint, or its proxy, accepts TCP
+// connections, so that an unreachable iCenter fails before logging in.
+func (icsInstance *ICSInstance) dial(ctx context.Context, endpoint icfg.ICSCenterEndpoint) error {
+	addr := endpoint.String()
+	proxyURL, err := icsInstance.proxyFor(endpoint)
+	if err != nil {
+		klog.Errorf("Cannot find the proxy of iCenter %s. err: %v", endpoint, err)
+		return err
+	}
+	if proxyURL != nil {
+		addr = proxyAddr(proxyURL)
+	}
+
+	dialer := net.Dialer{Timeout: DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if proxyURL != nil {
+			klog.Errorf("Cannot reach the proxy %s of iCenter %s. err: %v", proxyURL.Host, endpoint, err)
+		} else {
+			klog.Errorf("Cannot reach iCenter %s. err: %v", endpoint, err)
+		}
+		return err
+	}
+	return conn.Close()
+}
+
+// setCredentials sets the credentials of the next login.
+func (icsInstance *ICSInstance) setCredentials(user string, password string) {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+	icsInstance.Conn.UpdateCredentials(user, password)
+}
+
+// loginTarget returns the endpoint the connection points at, and the
+// credentials to log in to it with. Both are read at once, as failing over
+// and reloading the credentials change them concurrently.
+func (icsInstance *ICSInstance) loginTarget() (icfg.ICSCenterEndpoint, *url.Userinfo) {
+	icsInstance.lock.Lock()
+	defer icsInstance.lock.Unlock()
+	conn := icsInstance.Conn
+	return icfg.ICSCenterEndpoint{Host: conn.Hostname, Port: conn.Port}, url.UserPassword(conn.Username, conn.Password)
+}
+
+// newClient logs in to the endpoint the connection points at. The SDK
+// hardcodes the transport of its clients, so the client is built here with
+// the TLS configuration and the proxy of the iCenter.
+func (icsInstance *ICSInstance) newClient(ctx context.Context) (*rest.Client, error) {
+	conn := icsInstance.Conn
+	endpoint, user := icsInstance.loginTarget()
+	u, err := restful.ParseURL(endpoint.String())
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := icsInstance.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: conn.Insecure}
+	}
+	sc := restful.NewClient(u, conn.Insecure)
+	sc.HttpClient.SetTransport(&http.Transport{
+		Proxy:               icsInstance.proxy.httpProxy(),
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: DialTimeout,
+	})
+
+	client, err := rest.NewClient(ctx, sc)
+	if err != nil {
+		return nil, describeCertificateError(icsInstance.Config().TenantRef, err)
+	}
+	err = session.NewManager(client).Login(ctx, user)
+	if err != nil {
+		return nil, describeCertificateError(icsInstance.Config().TenantRef, err)
+	}
+	return client, nil
+}
+
+// connectEndpoint logs in to the endpoint the connection points at.
+func (icsInstance *ICSInstance) connectEndpoint(ctx context.Context) error {
+	if icsInstance.tlsErr != nil {
+		return icsInstance.tlsErr
+	}
+	client, err := icsInstance.newClient(ctx)
+	if err != nil {
+		klog.Errorf("Failed to create ics-go-sdk client. err: %+v", err)
+		return err
+	}
+	if previous := icsInstance.setClient(client); previous != nil {
+		metrics.RecordRelogin(icsInstance.Config().TenantRef)
+	}
+	return nil
+}
+
+// isSessionExpired returns true if err means iCenter no longer accepts the
+// session the call was made with.
+func isSessionExpired(err error) bool {
+	if sdkErr, ok := err.(*types.SDKError); ok {
+		return sdkErr.Code == "401"
+	}
+	return false
+}
+
+// withClient calls f with the client of the session shared by every call to
+// the iCenter, connecting first if there is none. If iCenter expired the
+// session, it logs in again and calls f once more.
+func (connMgr *ConnectionManager) withClient(ctx context.Context, icsInstance *ICSInstance, f func(c *rest.Client) error) error {
+	client := icsInstance.Client()
+	if client == nil {
+		if err := connMgr.Connect(ctx, icsInstance); err != nil {
+			return err
+		}
+		if client = icsInstance.Client(); client == nil {
+			return ErrNotConnected
+		}
+	}
+
+	err := f(client)
+	if !isSessionExpired(err) {
+		return err
+	}
+
+	klog.V(3).Infof("Session to iCenter %s expired, logging in again", icsInstance.Config().ICenterIP)
+	if err := connMgr.Connect(ctx, icsInstance); err != nil {
+		return err
+	}
+	if client = icsInstance.Client(); client == nil {
+		return ErrNotConnected
+	}
+	return f(client)
+}
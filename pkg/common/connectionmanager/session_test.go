@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+// addICenter adds an iCenter listening on addr to cfg.
+func addICenter(cfg *icfg.Config, addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	tenantRef := "tenant-" + port
+	cfg.ICSCenter[tenantRef] = &icfg.ICSCenterConfig{
+		User:         cfg.Global.User,
+		Password:     cfg.Global.Password,
+		TenantRef:    tenantRef,
+		ICenterIP:    host,
+		ICenterPort:  port,
+		InsecureFlag: true,
+		Datacenters:  "DC0",
+	}
+	return tenantRef
+}
+
+func TestConnectUnreachable(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+
+	// Nothing listens on the port once the listener is closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := addICenter(cfg, l.Addr().String())
+	l.Close()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	if err := connMgr.Connect(context.Background(), connMgr.ICSInstanceMap[unreachable]); err == nil {
+		t.Error("Connect to an unreachable iCenter succeeded")
+	}
+	if err := connMgr.Connect(context.Background(), connMgr.ICSInstanceMap[cfg.Global.ICenterIP]); err != nil {
+		t.Errorf("Connect to a healthy iCenter err=%v", err)
+	}
+}
+
+func TestConnectNonBlocking(t *testing.T) {
+	cfg, _, cleanup := configFromSim(false)
+	defer cleanup()
+
+	// An iCenter accepting connections without ever answering them.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accepted []net.Conn
+	var acceptedLock sync.Mutex
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			acceptedLock.Lock()
+			accepted = append(accepted, conn)
+			acceptedLock.Unlock()
+		}
+	}()
+	hanging := addICenter(cfg, l.Addr().String())
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	defer func() {
+		l.Close()
+		acceptedLock.Lock()
+		for _, conn := range accepted {
+			conn.Close()
+		}
+		acceptedLock.Unlock()
+		// Wait for the connection in progress to fail before logging out.
+		connMgr.Connect(context.Background(), connMgr.ICSInstanceMap[hanging])
+	}()
+	healthy := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+	if err := connMgr.Connect(context.Background(), healthy); err != nil {
+		t.Fatalf("Connect to a healthy iCenter err=%v", err)
+	}
+
+	// Concurrent callers share the connection in progress, and give up on it
+	// when their context is done.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			if err := connMgr.Connect(ctx, connMgr.ICSInstanceMap[hanging]); err != context.DeadlineExceeded {
+				t.Errorf("Connect to a hanging iCenter err=%v, expected %v", err, context.DeadlineExceeded)
+			}
+		}()
+	}
+
+	// The healthy iCenter does not wait for the hanging one.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if err := connMgr.Connect(ctx, healthy); err != nil {
+			t.Errorf("Connect to a healthy iCenter while another hangs err=%v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestConnectOnce(t *testing.T) {
+	icsInstance := &ICSInstance{}
+	release := make(chan struct{})
+	calls := 0
+	connect := func() error {
+		calls++
+		<-release
+		return nil
+	}
+
+	first := icsInstance.connectOnce(connect)
+	for i := 0; i < 3; i++ {
+		if call := icsInstance.connectOnce(connect); call != first {
+			t.Errorf("connectOnce #%d started a new connection while one is in progress", i)
+		}
+	}
+	close(release)
+	<-first.done
+
+	next := icsInstance.connectOnce(func() error { return nil })
+	<-next.done
+	if next == first || calls != 1 {
+		t.Errorf("unexpected connections: calls=%d, reused=%t", calls, next == first)
+	}
+}
+
+func TestSessionRelogin(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	instance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+	if instance.Connection() != nil || instance.Client() != nil {
+		t.Fatal("a session before the first login")
+	}
+	if err := connMgr.Connect(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	first := instance.Connection()
+	client := first.Client
+	datacenters, err := icslib.GetAllDatacenter(ctx, instance)
+	if err != nil || len(datacenters) == 0 {
+		t.Fatalf("GetAllDatacenter() = %v, %v", datacenters, err)
+	}
+
+	// A new login replaces the session without modifying the previous one.
+	model.ExpireSessions()
+	if err := connMgr.Connect(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Connection() == first || first.Client != client {
+		t.Error("the login modified the connection of the previous session")
+	}
+
+	// The datacenters read with the previous session use the new one.
+	if _, err := datacenters[0].GetAllVMs(ctx); err != nil {
+		t.Errorf("GetAllVMs() after the new login err=%v", err)
+	}
+}
+
+func TestLoginWhileUpdatingCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	instance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+
+	// Logging in while the credentials are set again, as reloading them does,
+	// must neither race nor log in with half of them.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				instance.setCredentials(model.Username, model.Password)
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		if _, err := instance.newClient(ctx); err != nil {
+			t.Errorf("login #%d err=%v", i, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"k8s.io/klog"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+// newTLSConfig returns the TLS configuration of the connections to the
+// iCenter, nil if only insecure-flag applies, the certificate being verified
+// against the system roots unless it is set. A thumbprint takes precedence
+// over the CA bundle, which takes precedence over insecure-flag.
+func newTLSConfig(cfg *icfg.ICSCenterConfig) (*tls.Config, error) {
+	if cfg.Thumbprint != "" {
+		if cfg.CAFile != "" || cfg.CAData != "" {
+			klog.V(2).Infof("iCenter %s pins the certificate thumbprint, ignoring the CA bundle", cfg.TenantRef)
+		}
+		return &tls.Config{
+			// The chain is not verified, the certificate is pinned instead.
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: verifyThumbprint(cfg.TenantRef, cfg.Thumbprint),
+		}, nil
+	}
+
+	if cfg.CAFile == "" && cfg.CAData == "" {
+		return nil, nil
+	}
+	if cfg.InsecureFlag {
+		klog.V(2).Infof("iCenter %s has a CA bundle, verifying its certificate despite insecure-flag", cfg.TenantRef)
+	}
+
+	pool := x509.NewCertPool()
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the CA file of iCenter %s: %v", cfg.TenantRef, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s of iCenter %s: %v", cfg.CAFile, cfg.TenantRef, ErrInvalidCA)
+		}
+	}
+	if cfg.CAData != "" {
+		if !pool.AppendCertsFromPEM(decodeCAData(cfg.CAData)) {
+			return nil, fmt.Errorf("CA data of iCenter %s: %v", cfg.TenantRef, ErrInvalidCA)
+		}
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// decodeCAData returns the PEM data of inline CA data, which may be base64
+// encoded.
+func decodeCAData(data string) []byte {
+	data = strings.TrimSpace(data)
+	if strings.HasPrefix(data, "-----BEGIN") {
+		return []byte(data)
+	}
+	pem, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return []byte(data)
+	}
+	return pem
+}
+
+// verifyThumbprint returns a tls.Config VerifyPeerCertificate function
+// accepting only the certificate with the SHA-1 or SHA-256 thumbprint, in
+// upper case hex.
+func verifyThumbprint(tenantRef string, thumbprint string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("iCenter %s presented no certificate", tenantRef)
+		}
+		var sum []byte
+		if len(thumbprint) == 2*sha1.Size {
+			s := sha1.Sum(rawCerts[0])
+			sum = s[:]
+		} else {
+			s := sha256.Sum256(rawCerts[0])
+			sum = s[:]
+		}
+		if actual := strings.ToUpper(hex.EncodeToString(sum)); actual != thumbprint {
+			return fmt.Errorf("certificate thumbprint %s of iCenter %s does not match the configured thumbprint %s",
+				actual, tenantRef, thumbprint)
+		}
+		return nil
+	}
+}
+
+// isCertificateError returns true if err means the iCenter certificate
+// failed verification. The SDK reports transport errors by message only.
+func isCertificateError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "x509: ") ||
+		strings.Contains(err.Error(), "does not match the configured thumbprint"))
+}
+
+// describeCertificateError explains the errors of iCenter certificates that
+// fail verification.
+func describeCertificateError(tenantRef string, err error) error {
+	if err == nil || !strings.Contains(err.Error(), "x509: ") {
+		return err
+	}
+	return fmt.Errorf("iCenter %s presented an untrusted certificate, set ca-file, ca-data or thumbprint: %v",
+		tenantRef, err)
+}
+
+// clientTLSConfig returns the TLS configuration of the connections to the
+// endpoint of the iCenter.
+func (icsInstance *ICSInstance) clientTLSConfig(endpoint string) *tls.Config {
+	config := &tls.Config{InsecureSkipVerify: icsInstance.Config().InsecureFlag}
+	if icsInstance.tlsConfig != nil {
+		config = icsInstance.tlsConfig.Clone()
+	}
+	config.ServerName, _, _ = net.SplitHostPort(endpoint)
+	return config
+}
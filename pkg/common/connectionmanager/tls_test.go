@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net"
+	"strings"
+	"testing"
+
+	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
+)
+
+// serverCertificate returns the DER encoded certificate served at addr.
+func serverCertificate(t *testing.T, addr string) []byte {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Raw
+}
+
+func TestTLSConfig(t *testing.T) {
+	cfg, _, cleanup := configFromSimWithTLS(new(tls.Config), false, false)
+	defer cleanup()
+	icsConfig := cfg.ICSCenter[cfg.Global.ICenterIP]
+	cert := serverCertificate(t, net.JoinHostPort(icsConfig.ICenterIP, icsConfig.ICenterPort))
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))
+	sha1Sum := sha1.Sum(cert)
+	sha256Sum := sha256.Sum256(cert)
+
+	for _, test := range []struct {
+		name        string
+		caData      string
+		thumbprint  string
+		expectedErr string
+	}{
+		{"system roots", "", "", "set ca-file, ca-data or thumbprint"},
+		{"CA data", caPEM, "", ""},
+		{"base64 CA data", base64.StdEncoding.EncodeToString([]byte(caPEM)), "", ""},
+		{"invalid CA data", "not a certificate", "", InvalidCAErrMsg},
+		{"SHA-1 thumbprint", "", strings.ToUpper(hex.EncodeToString(sha1Sum[:])), ""},
+		{"SHA-256 thumbprint", "", strings.ToUpper(hex.EncodeToString(sha256Sum[:])), ""},
+		{"wrong thumbprint", caPEM, strings.Repeat("AB", sha256.Size), "does not match the configured thumbprint"},
+	} {
+		icsConfig.CAData = test.caData
+		icsConfig.Thumbprint = test.thumbprint
+		connMgr := NewConnectionManager(cfg, nil, nil)
+		icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+
+		err := connMgr.Connect(context.Background(), icsInstance)
+		result := connMgr.VerifyAll(context.Background(), "", "")[0]
+		connMgr.Logout()
+
+		if test.expectedErr == "" {
+			if err != nil || result.Err != nil || !result.TLS {
+				t.Errorf("%s: Connect err=%v, verify result %+v", test.name, err, result)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+			t.Errorf("%s: Connect err=%v, expected %q", test.name, err, test.expectedErr)
+		}
+		if result.TLS || result.Err == nil {
+			t.Errorf("%s: unexpected verify result %+v", test.name, result)
+		}
+	}
+}
+
+func TestTLSConfigCAFile(t *testing.T) {
+	_, err := newTLSConfig(&icfg.ICSCenterConfig{TenantRef: "tenant", CAFile: "/does/not/exist"})
+	if err == nil || !strings.Contains(err.Error(), "cannot read the CA file of iCenter tenant") {
+		t.Errorf("newTLSConfig with a missing CA file err=%v", err)
+	}
+
+	config, err := newTLSConfig(&icfg.ICSCenterConfig{TenantRef: "tenant", InsecureFlag: true})
+	if config != nil || err != nil {
+		t.Errorf("newTLSConfig without CA nor thumbprint = %v, err=%v, expected the SDK default", config, err)
+	}
+}
@@ -17,24 +17,27 @@ limitations under the License.
 package connectionmanager
 
 import (
+	"crypto/tls"
 	"sync"
+	"time"
 
 	icfg "github.com/inspur-ics/cloud-provider-ics/pkg/common/config"
 	cm "github.com/inspur-ics/cloud-provider-ics/pkg/common/credentialmanager"
 	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
 	k8s "github.com/inspur-ics/cloud-provider-ics/pkg/common/kubernetes"
 	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 )
 
 // ConnectionManager encapsulates iCenter connections
 type ConnectionManager struct {
-	sync.Mutex
-
 	// The k8s client init from the cloud provider service account
 	client clientset.Interface
 
-	// Maps the ICS server to ICSInstance
+	// Guards ICSInstanceMap, credentialManagers and informerManagers
+	instancesLock sync.RWMutex
+	// Maps the ICS server to ICSInstance, see Instances
 	ICSInstanceMap map[string]*ICSInstance
 	// CredentialManager per ICS
 	// The global CredentialManager will have an entry in this map with the key of "Global"
@@ -42,12 +45,62 @@ type ConnectionManager struct {
 	// InformerManagers per ICS
 	// The global InformerManager will have an entry in this map with the key of "Global"
 	informerManagers map[string]*k8s.InformerManager
+	// VM index of every ICS used to discover nodes
+	inventory *vmInventory
+	// Retry policy of the connections to the ICSs
+	backoff wait.Backoff
+
+	statusLock sync.RWMutex
+	// Maps the tenantRef to the outcome of the connections to that ICS
+	statuses map[string]*ConnectionStatus
 }
 
 // ICSInstance represents a inCloud Sphere instance where one or more kubernetes nodes are running.
+// Each ICSInstance connects independently of the others.
 type ICSInstance struct {
+	// The endpoint and credentials of the logins. Its Client is not used,
+	// see Connection.
 	Conn *icsgo.ICSConnection
-	Cfg  *icfg.ICSCenterConfig
+	// The configuration of the ICS, replaced by Reload, see Config
+	Cfg *icfg.ICSCenterConfig
+
+	// Guards Cfg, session, connecting, active and lastFailbackProbe
+	lock sync.Mutex
+	// The connection of the current session, replaced but never modified
+	// at each login, nil if there is none
+	session *icsgo.ICSConnection
+	// The connection to the ICS in progress, nil if none
+	connecting *connectCall
+	// The endpoints of the ICS, in order of preference
+	endpoints []icfg.ICSCenterEndpoint
+	// Index of the endpoint Conn uses
+	active int
+	// When the preferred endpoint was last probed while failed over
+	lastFailbackProbe time.Time
+	// Fails the connections fast while the ICS is down
+	breaker *circuitBreaker
+	// The TLS configuration of the connections, nil for the SDK default,
+	// or why it could not be built
+	tlsConfig *tls.Config
+	tlsErr    error
+	// The proxy of the connections, nil if none
+	proxy proxyFunc
+}
+
+// ConnectionStatus is the outcome of the connections to an iCenter.
+type ConnectionStatus struct {
+	TenantRef string
+	ICenterIP string
+	// When Connect was last called, zero if it never was.
+	LastAttempt time.Time
+	// When Connect last succeeded, zero if it never did.
+	LastSuccess time.Time
+	// The error of the last attempt, nil if it succeeded.
+	LastError error
+	// False if iCenter rejected the credentials on the last attempt.
+	CredentialsValid bool
+	// The HOST:PORT endpoint of the last attempt.
+	Endpoint string
 }
 
 // VMDiscoveryInfo contains VM info about a discovered VM
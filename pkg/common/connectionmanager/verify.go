@@ -0,0 +1,257 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+// VerifyResult is the outcome of verifying the configuration of an iCenter.
+// The checks run in order and stop at the first failure, so a check that did
+// not run is reported as failed.
+type VerifyResult struct {
+	TenantRef string
+	ICenterIP string
+	// The HOST:PORT endpoint verified, the first reachable one.
+	Endpoint string
+	// True if an endpoint accepts TCP connections.
+	Reachable bool
+	// True if the TLS handshake succeeded, verifying the certificate unless
+	// insecure-flag is set.
+	TLS bool
+	// True if iCenter accepted the credentials.
+	CredentialsValid bool
+	// The configured datacenters iCenter has, and those it does not.
+	Datacenters        []string
+	MissingDatacenters []string
+	// The zone and region tag categories with tags, and those without.
+	TagCategories        []string
+	MissingTagCategories []string
+	// The first failed check, nil if every check passed.
+	Err error
+}
+
+// VerifyReport is the outcome of verifying every iCenter, sorted by
+// tenantRef.
+type VerifyReport []VerifyResult
+
+// Failed returns the results of the iCenters that failed verification.
+func (report VerifyReport) Failed() []VerifyResult {
+	var failed []VerifyResult
+	for _, result := range report {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns the errors of every iCenter that failed verification, nil if
+// none did.
+func (report VerifyReport) Err() error {
+	var errs []error
+	for _, result := range report.Failed() {
+		errs = append(errs, fmt.Errorf("iCenter %s: %v", result.TenantRef, result.Err))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// VerifyAll verifies the configuration of every iCenter in parallel, so that
+// it takes as long as the slowest iCenter. The zone and region tag
+// categories are only checked when set.
+func (connMgr *ConnectionManager) VerifyAll(ctx context.Context, zoneLabel string, regionLabel string) VerifyReport {
+	instances := connMgr.Instances()
+	report := make(VerifyReport, 0, len(instances))
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, icsInstance := range instances {
+		wg.Add(1)
+		go func(icsInstance *ICSInstance) {
+			defer wg.Done()
+			result := connMgr.verify(ctx, icsInstance, zoneLabel, regionLabel)
+			if result.Err == nil {
+				klog.V(3).Infof("iCenter %s verified on %s.", result.TenantRef, result.Endpoint)
+			} else {
+				klog.Errorf("iCenter %s failed verification. Err: %q", result.TenantRef, result.Err)
+			}
+
+			lock.Lock()
+			report = append(report, result)
+			lock.Unlock()
+		}(icsInstance)
+	}
+	wg.Wait()
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].TenantRef < report[j].TenantRef
+	})
+	return report
+}
+
+func (connMgr *ConnectionManager) verify(ctx context.Context, icsInstance *ICSInstance,
+	zoneLabel string, regionLabel string) VerifyResult {
+	result := VerifyResult{
+		TenantRef: icsInstance.Config().TenantRef,
+		ICenterIP: icsInstance.Config().ICenterIP,
+	}
+
+	endpoints := icsInstance.endpoints
+	if len(endpoints) == 0 {
+		endpoints = instanceEndpoints(icsInstance.Config())
+	}
+	endpoint := endpoints[0]
+	for _, endpoint = range endpoints {
+		if result.Err = icsInstance.dial(ctx, endpoint); result.Err == nil {
+			result.Reachable = true
+			break
+		}
+	}
+	result.Endpoint = endpoint.String()
+	if !result.Reachable {
+		return result
+	}
+
+	if result.Err = icsInstance.tlsErr; result.Err != nil {
+		return result
+	}
+	// Through a proxy, the TLS handshake is only checked by logging in.
+	proxyURL, _ := icsInstance.proxyFor(endpoint)
+	if proxyURL == nil {
+		if result.Err = handshake(ctx, result.Endpoint, icsInstance.clientTLSConfig(result.Endpoint)); result.Err != nil {
+			return result
+		}
+		result.TLS = true
+	}
+
+	if result.Err = connMgr.Connect(ctx, icsInstance); result.Err != nil {
+		result.TLS = result.TLS || (proxyURL != nil && !isCertificateError(result.Err))
+		return result
+	}
+	result.Endpoint = icsInstance.ActiveEndpoint().String()
+	result.TLS = true
+	result.CredentialsValid = true
+
+	if result.Err = verifyDatacenters(ctx, icsInstance, &result); result.Err != nil {
+		return result
+	}
+
+	if zoneLabel != "" || regionLabel != "" {
+		result.Err = connMgr.verifyTagCategories(ctx, icsInstance, []string{zoneLabel, regionLabel}, &result)
+	}
+	return result
+}
+
+// handshake checks that the iCenter endpoint completes a TLS handshake with
+// the TLS configuration.
+func handshake(ctx context.Context, endpoint string, config *tls.Config) error {
+	dialer := net.Dialer{Timeout: DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DialTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		klog.Errorf("TLS handshake with iCenter %s failed. err: %v", endpoint, err)
+		return err
+	}
+	return nil
+}
+
+// verifyDatacenters checks that iCenter has the configured datacenters, by
+// ID or name.
+func verifyDatacenters(ctx context.Context, icsInstance *ICSInstance, result *VerifyResult) error {
+	if icsInstance.Config().Datacenters == "" {
+		return nil
+	}
+
+	datacenters, err := icslib.GetAllDatacenter(ctx, icsInstance)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	for _, dc := range datacenters {
+		found[dc.ID] = true
+		found[dc.Name] = true
+	}
+
+	for _, dc := range strings.Split(icsInstance.Config().Datacenters, ",") {
+		dc = strings.TrimSpace(dc)
+		if dc == "" {
+			continue
+		}
+		if found[dc] {
+			result.Datacenters = append(result.Datacenters, dc)
+		} else {
+			result.MissingDatacenters = append(result.MissingDatacenters, dc)
+		}
+	}
+	if len(result.MissingDatacenters) > 0 {
+		return icslib.ErrNoDatacenterFound
+	}
+	return nil
+}
+
+// verifyTagCategories checks that iCenter has tags of every non empty
+// category.
+func (connMgr *ConnectionManager) verifyTagCategories(ctx context.Context, icsInstance *ICSInstance,
+	categories []string, result *VerifyResult) error {
+	tags, err := icslib.GetAllTags(ctx, icsInstance)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	for _, tag := range tags {
+		found[tag.Description] = true
+	}
+
+	for _, category := range categories {
+		if category == "" {
+			continue
+		}
+		if found[category] {
+			result.TagCategories = append(result.TagCategories, category)
+		} else {
+			result.MissingTagCategories = append(result.MissingTagCategories, category)
+		}
+	}
+	if len(result.MissingTagCategories) > 0 {
+		return ErrTagCategoryNotFound
+	}
+	return nil
+}
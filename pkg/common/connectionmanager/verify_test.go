@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
+)
+
+func TestVerifyAll(t *testing.T) {
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+	model.AddTag(types.Tag{Name: "zone-a", Description: cfg.Labels.Zone})
+
+	// Nothing listens on the port once the listener is closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := addICenter(cfg, l.Addr().String())
+	l.Close()
+
+	otherCfg, _, otherCleanup := configFromSim(false)
+	defer otherCleanup()
+	missingDC := addICenter(cfg, net.JoinHostPort(otherCfg.Global.ICenterIP, otherCfg.Global.ICenterPort))
+	cfg.ICSCenter[missingDC].Datacenters = "DC0, DC9"
+
+	anotherCfg, _, anotherCleanup := configFromSim(false)
+	defer anotherCleanup()
+	wrongPassword := addICenter(cfg, net.JoinHostPort(anotherCfg.Global.ICenterIP, anotherCfg.Global.ICenterPort))
+	cfg.ICSCenter[wrongPassword].Password = "wrong"
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	report := connMgr.VerifyAll(context.Background(), cfg.Labels.Zone, cfg.Labels.Region)
+	if len(report) != 4 {
+		t.Fatalf("%d results, expected 4", len(report))
+	}
+	results := make(map[string]VerifyResult)
+	for _, result := range report {
+		results[result.TenantRef] = result
+	}
+
+	result := results[cfg.Global.ICenterIP]
+	if !result.Reachable || !result.TLS || !result.CredentialsValid {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(result.Datacenters, []string{"DC0"}) || len(result.MissingDatacenters) != 0 {
+		t.Errorf("datacenters %v, missing %v, expected DC0", result.Datacenters, result.MissingDatacenters)
+	}
+	if !reflect.DeepEqual(result.TagCategories, []string{cfg.Labels.Zone}) ||
+		!reflect.DeepEqual(result.MissingTagCategories, []string{cfg.Labels.Region}) {
+		t.Errorf("tag categories %v, missing %v", result.TagCategories, result.MissingTagCategories)
+	}
+	if result.Err != ErrTagCategoryNotFound {
+		t.Errorf("err=%v, expected %v", result.Err, ErrTagCategoryNotFound)
+	}
+
+	if result := results[unreachable]; result.Reachable || result.TLS || result.Err == nil {
+		t.Errorf("unexpected result for the unreachable iCenter %+v", result)
+	}
+
+	result = results[missingDC]
+	if !result.CredentialsValid || result.Err != icslib.ErrNoDatacenterFound ||
+		!reflect.DeepEqual(result.MissingDatacenters, []string{"DC9"}) {
+		t.Errorf("unexpected result for the missing datacenter %+v", result)
+	}
+
+	result = results[wrongPassword]
+	if !result.Reachable || !result.TLS || result.CredentialsValid || !isCredentialError(result.Err) {
+		t.Errorf("unexpected result for the wrong password %+v", result)
+	}
+
+	// Verify reports every failure, not only the first one.
+	err = connMgr.Verify()
+	if err == nil {
+		t.Fatal("Verify succeeded, expected errors")
+	}
+	for _, tenantRef := range []string{unreachable, missingDC, wrongPassword} {
+		if !strings.Contains(err.Error(), "iCenter "+tenantRef+":") {
+			t.Errorf("Verify err=%v, expected an error for %s", err, tenantRef)
+		}
+	}
+	if strings.Contains(err.Error(), "iCenter "+cfg.Global.ICenterIP+":") {
+		t.Errorf("Verify err=%v, expected no error for %s without labels", err, cfg.Global.ICenterIP)
+	}
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	cfg, _, cleanup := configFromSimWithTLS(new(tls.Config), false, false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+
+	// The simulator certificate is not trusted.
+	result := connMgr.VerifyAll(context.Background(), "", "")[0]
+	if !result.Reachable || result.TLS || result.Err == nil {
+		t.Errorf("unexpected result with an untrusted certificate %+v", result)
+	}
+}
+
+func TestGetAllTagsPages(t *testing.T) {
+	cfg, model, cleanup := configFromSim(false)
+	defer cleanup()
+	model.PageSize = 1
+	model.AddTag(types.Tag{Name: "zone-a", Description: cfg.Labels.Zone})
+	model.AddTag(types.Tag{Name: "zone-b", Description: cfg.Labels.Zone})
+	model.AddTag(types.Tag{Name: "region-a", Description: cfg.Labels.Region})
+
+	connMgr := NewConnectionManager(cfg, nil, nil)
+	defer connMgr.Logout()
+	icsInstance := connMgr.ICSInstanceMap[cfg.Global.ICenterIP]
+
+	ctx := context.Background()
+	if err := connMgr.Connect(ctx, icsInstance); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+	tags, err := icslib.GetAllTags(ctx, icsInstance)
+	if err != nil {
+		t.Fatalf("GetAllTags err=%v", err)
+	}
+	if len(tags) != 3 {
+		t.Errorf("%d tags, expected 3", len(tags))
+	}
+}
@@ -24,7 +24,7 @@ import (
 	"time"
 
 	"github.com/inspur-ics/cloud-provider-ics/pkg/common/icslib"
-	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 	rest "github.com/inspur-ics/ics-go-sdk/client"
 	ht "github.com/inspur-ics/ics-go-sdk/host"
 	tags "github.com/inspur-ics/ics-go-sdk/tag"
@@ -47,7 +47,7 @@ func (cm *ConnectionManager) WhichICSandDCByZone(ctx context.Context,
 	klog.V(4).Infof("WhichICSandDCByZone called with zone: %s and region: %s", zoneLooking, regionLooking)
 
 	// Need at least one ICS
-	numOfICSs := len(cm.ICSInstanceMap)
+	numOfICSs := len(cm.Instances())
 	if numOfICSs == 0 {
 		err := ErrMustHaveAtLeastOneICSDC
 		klog.Errorf("%v", err)
@@ -67,7 +67,8 @@ func (cm *ConnectionManager) getDIFromSingleICS(ctx context.Context,
 	zoneLabel string, regionLabel string, zoneLooking string, regionLooking string) (*ZoneDiscoveryInfo, error) {
 	klog.V(4).Infof("getDIFromSingleICS called with zone: %s and region: %s", zoneLooking, regionLooking)
 
-	if len(cm.ICSInstanceMap) != 1 {
+	instances := cm.Instances()
+	if len(instances) != 1 {
 		err := ErrUnsupportedConfiguration
 		klog.Errorf("%v", err)
 		return nil, err
@@ -77,20 +78,16 @@ func (cm *ConnectionManager) getDIFromSingleICS(ctx context.Context,
 
 	// Get first inCloud Sphere Instance
 	var tmpVsi *ICSInstance
-	for _, tmpVsi = range cm.ICSInstanceMap {
+	for _, tmpVsi = range instances {
 		break //Grab the first one because there is only one
 	}
 
-	var err error
-	for i := 0; i < NumConnectionAttempts; i++ {
-		err = cm.Connect(ctx, tmpVsi)
-		if err == nil {
-			break
-		}
-		time.Sleep(time.Duration(RetryAttemptDelaySecs) * time.Second)
+	if err := cm.connectWithRetry(ctx, tmpVsi); err != nil {
+		klog.Errorf("getDIFromSingleICS error ics: %v", err)
+		return nil, err
 	}
 
-	numOfDc, err := icslib.GetNumberOfDatacenters(ctx, tmpVsi.Conn)
+	numOfDc, err := icslib.GetNumberOfDatacenters(ctx, tmpVsi)
 	if err != nil {
 		klog.Errorf("%v", err)
 		return nil, err
@@ -105,7 +102,7 @@ func (cm *ConnectionManager) getDIFromSingleICS(ctx context.Context,
 	// We are sure this is single ICS and DC
 	klog.Info("Single iCenter/Datacenter configuration detected")
 
-	datacenterObjs, err := icslib.GetAllDatacenter(ctx, tmpVsi.Conn)
+	datacenterObjs, err := icslib.GetAllDatacenter(ctx, tmpVsi)
 	if err != nil {
 		klog.Error("GetAllDatacenter failed. Err:", err)
 		return nil, err
@@ -167,43 +164,35 @@ func (cm *ConnectionManager) getDIFromMultiICSorDC(ctx context.Context,
 	}
 
 	go func() {
-		for _, vsi := range cm.ICSInstanceMap {
+		for _, vsi := range cm.Instances() {
 			var datacenterObjs []*icslib.Datacenter
 
 			if getZoneFound() {
 				break
 			}
 
-			var err error
-			for i := 0; i < NumConnectionAttempts; i++ {
-				err = cm.Connect(ctx, vsi)
-				if err == nil {
-					break
-				}
-				time.Sleep(time.Duration(RetryAttemptDelaySecs) * time.Second)
-			}
-
+			err := cm.connectWithRetry(ctx, vsi)
 			if err != nil {
 				klog.Error("getDIFromMultiICSorDC error ics:", err)
 				setGlobalErr(err)
 				continue
 			}
 
-			if vsi.Cfg.Datacenters == "" {
-				datacenterObjs, err = icslib.GetAllDatacenter(ctx, vsi.Conn)
+			if vsi.Config().Datacenters == "" {
+				datacenterObjs, err = icslib.GetAllDatacenter(ctx, vsi)
 				if err != nil {
 					klog.Error("getDIFromMultiICSorDC error dc:", err)
 					setGlobalErr(err)
 					continue
 				}
 			} else {
-				datacenters := strings.Split(vsi.Cfg.Datacenters, ",")
+				datacenters := strings.Split(vsi.Config().Datacenters, ",")
 				for _, dc := range datacenters {
 					dc = strings.TrimSpace(dc)
 					if dc == "" {
 						continue
 					}
-					datacenterObj, err := icslib.GetDatacenter(ctx, vsi.Conn, dc)
+					datacenterObj, err := icslib.GetDatacenter(ctx, vsi, dc)
 					if err != nil {
 						klog.Error("getDIFromMultiICSorDC error dc:", err)
 						setGlobalErr(err)
@@ -218,17 +207,17 @@ func (cm *ConnectionManager) getDIFromMultiICSorDC(ctx context.Context,
 					break
 				}
 
-				hostList, err := icslib.GetHostSystemListByDC(ctx, vsi.Conn, datacenterObj.ID)
+				hostList, err := icslib.GetHostSystemListByDC(ctx, vsi, datacenterObj.ID)
 				if err != nil {
 					klog.Errorf("HostSystemList failed: %v", err)
 					continue
 				}
 
 				for _, host := range hostList {
-					klog.V(3).Infof("Finding zone in ics=%s and datacenter=%s for host: %s", vsi.Cfg.ICenterIP, datacenterObj.Name, host.HostName)
+					klog.V(3).Infof("Finding zone in ics=%s and datacenter=%s for host: %s", vsi.Config().ICenterIP, datacenterObj.Name, host.HostName)
 					queueChannel <- &zoneSearch{
-						tenantRef:  vsi.Cfg.TenantRef,
-						ics:         vsi.Cfg.ICenterIP,
+						tenantRef:  vsi.Config().TenantRef,
+						ics:         vsi.Config().ICenterIP,
 						datacenter: datacenterObj,
 						host:       host,
 					}
@@ -282,37 +271,28 @@ func (cm *ConnectionManager) getDIFromMultiICSorDC(ctx context.Context,
 	return nil, icslib.ErrNoZoneRegionFound
 }
 
-func withTagsClient(ctx context.Context, connection *icsgo.ICSConnection, f func(c *rest.Client) error) error {
-	c, err := connection.GetClient()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := connection.Logout(ctx); err != nil {
-			klog.Errorf("failed to logout: %v", err)
-		}
-	}()
-	return f(c)
-}
-
 // LookupZoneByMoref searches for a zone using the provided managed object reference.
 func (cm *ConnectionManager) LookupZoneByMoref(ctx context.Context, tenantRef string,
 	hostRef string, zoneLabel string, regionLabel string) (map[string]string, error) {
 
-	result := make(map[string]string)
+	var result map[string]string
 
-	vsi := cm.ICSInstanceMap[tenantRef]
+	vsi := cm.Instances()[tenantRef]
 	if vsi == nil {
 		err := ErrConnectionNotFound
 		klog.Errorf("Unable to find Connection for tenantRef=%s", tenantRef)
 		return nil, err
 	}
 
-	err := withTagsClient(ctx, vsi.Conn, func(c *rest.Client) error {
+	err := cm.withClient(ctx, vsi, func(c *rest.Client) error {
 		client := tags.NewTagsService(c)
+		// Called again with a new session if the current one expired.
+		result = make(map[string]string)
 
 		hostService := ht.NewHostService(c)
+		start := time.Now()
 		host, err := hostService.GetHost(ctx, hostRef)
+		metrics.RecordTenantAPICall("get_host", tenantRef, start, err)
 		if err != nil {
 			klog.Errorf("Ancestors failed for %s with err %v", hostRef, err)
 			return err
@@ -325,13 +305,17 @@ func (cm *ConnectionManager) LookupZoneByMoref(ctx context.Context, tenantRef st
 		// search the hierarchy, example order: ["Host", "Cluster", "Datacenter", "Folder"]
 		for key, value := range objects {
 			klog.V(4).Infof("Name: %s, Type: %s", value, key)
+			start := time.Now()
 			tags, err := client.ListAttachedTags(ctx, key, value)
+			metrics.RecordTenantAPICall("list_attached_tags", tenantRef, start, err)
 			if err != nil {
 				klog.Errorf("Cannot list attached tags. Err: %v", err)
 				return err
 			}
 			for _, value := range tags {
+				start := time.Now()
 				tag, err := client.GetTag(ctx, value)
+				metrics.RecordTenantAPICall("get_tag", tenantRef, start, err)
 				if err != nil {
 					klog.Errorf("Zones Get tag %s: %s", value, err)
 					return err
@@ -374,3 +358,47 @@ func (cm *ConnectionManager) LookupZoneByMoref(ctx context.Context, tenantRef st
 	}
 	return result, nil
 }
+
+// LookupTagsByVM returns the names of the tags attached to the VM, keyed by
+// tag category. iCenter keeps the category of a tag in its description.
+func (cm *ConnectionManager) LookupTagsByVM(ctx context.Context, tenantRef string,
+	vmID string) (map[string][]string, error) {
+
+	var result map[string][]string
+
+	vsi := cm.Instances()[tenantRef]
+	if vsi == nil {
+		err := ErrConnectionNotFound
+		klog.Errorf("Unable to find Connection for tenantRef=%s", tenantRef)
+		return nil, err
+	}
+
+	err := cm.withClient(ctx, vsi, func(c *rest.Client) error {
+		client := tags.NewTagsService(c)
+		result = make(map[string][]string)
+
+		start := time.Now()
+		tagIDs, err := client.ListAttachedTags(ctx, "VM", vmID)
+		metrics.RecordTenantAPICall("list_attached_tags", tenantRef, start, err)
+		if err != nil {
+			klog.Errorf("Cannot list attached tags. Err: %v", err)
+			return err
+		}
+		for _, tagID := range tagIDs {
+			start := time.Now()
+			tag, err := client.GetTag(ctx, tagID)
+			metrics.RecordTenantAPICall("get_tag", tenantRef, start, err)
+			if err != nil {
+				klog.Errorf("Get tag %s: %s", tagID, err)
+				return err
+			}
+			result[tag.Description] = append(result[tag.Description], tag.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		klog.Errorf("Get tags for VM: %s: %s", vmID, err)
+		return nil, err
+	}
+	return result, nil
+}
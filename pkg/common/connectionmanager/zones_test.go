@@ -1,257 +1,205 @@
-///*
-//Copyright 2019 The Kubernetes Authors.
-//
-//Licensed under the Apache License, Version 2.0 (the "License");
-//you may not use this file except in compliance with the License.
-//You may obtain a copy of the License at
-//
-//  http://www.apache.org/licenses/LICENSE-2.0
-//
-//Unless required by applicable law or agreed to in writing, software
-//distributed under the License is distributed on an "AS IS" BASIS,
-//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-//See the License for the specific language governing permissions and
-//limitations under the License.
-//*/
-//
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
 package connectionmanager
-//
-//import (
-//	"context"
-//	"net/url"
-//	"strings"
-//	"testing"
-//)
-//
-//func TestWhichVCandDCByZoneSingleDC(t *testing.T) {
-//	config, cleanup := configFromEnvOrSim(false)
-//	defer cleanup()
-//
-//	connMgr := NewConnectionManager(config, nil, nil)
-//	defer connMgr.Logout()
-//
-//	// context
-//	ctx := context.Background()
-//
-//	zoneInfo, err := connMgr.WhichVCandDCByZone(ctx, config.Labels.Zone, config.Labels.Region, "", "")
-//	if err != nil {
-//		t.Fatalf("WhichVCandDCByZone failed err=%v", err)
-//	}
-//	if zoneInfo == nil {
-//		t.Fatalf("WhichVCandDCByZone zoneInfo=nil")
-//	}
-//
-//	if !strings.EqualFold("DC0", zoneInfo.DataCenter.Name()) {
-//		t.Errorf("Datacenter mismatch DC0 != %s", zoneInfo.DataCenter.Name())
-//	}
-//}
-//
-//func TestWhichVCandDCByZoneMultiDC(t *testing.T) {
-//	config, cleanup := configFromEnvOrSim(true)
-//	defer cleanup()
-//
-//	connMgr := NewConnectionManager(config, nil, nil)
-//	defer connMgr.Logout()
-//
-//	// context
-//	ctx := context.Background()
-//
-//	// Get the inCloud Sphere Instance
-//	vsi := connMgr.ICSInstanceMap[config.Global.ICenterIP]
-//
-//	err := connMgr.Connect(ctx, vsi)
-//	if err != nil {
-//		t.Errorf("Failed to Connect to inCloud Sphere: %s", err)
-//	}
-//
-//	// Tag manager instance
-//	restClient := rest.NewClient(vsi.Conn.Client)
-//	user := url.UserPassword(vsi.Conn.Username, vsi.Conn.Password)
-//	if err := restClient.Login(ctx, user); err != nil {
-//		t.Fatalf("Rest login failed. err=%v", err)
-//	}
-//
-//	m := tags.NewManager(restClient)
-//
-//	/*
-//	 * START SETUP
-//	 */
-//	// Create a region category
-//	regionID, err := m.CreateCategory(ctx, &tags.Category{Name: config.Labels.Region})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a region tag
-//	regionID, err = m.CreateTag(ctx, &tags.Tag{CategoryID: regionID, Name: "k8s-region-US"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a zone category
-//	zoneID, err := m.CreateCategory(ctx, &tags.Category{Name: config.Labels.Zone})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a zone tags
-//	zoneIDwest, err := m.CreateTag(ctx, &tags.Tag{CategoryID: zoneID, Name: "k8s-zone-US-west"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//	zoneIDeast, err := m.CreateTag(ctx, &tags.Tag{CategoryID: zoneID, Name: "k8s-zone-US-east"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Setup a multi-DC environment with zones!
-//	// Setup DC0
-//	dc0, err := vclib.GetDatacenter(ctx, vsi.Conn, "DC0")
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Attach tag to DC0
-//	if err = m.AttachTag(ctx, regionID, dc0); err != nil {
-//		t.Fatal(err)
-//	}
-//	if err = m.AttachTag(ctx, zoneIDwest, dc0); err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Setup DC1
-//	dc1, err := vclib.GetDatacenter(ctx, vsi.Conn, "DC1")
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Attach tag to DC1
-//	if err = m.AttachTag(ctx, regionID, dc1); err != nil {
-//		t.Fatal(err)
-//	}
-//	if err = m.AttachTag(ctx, zoneIDeast, dc1); err != nil {
-//		t.Fatal(err)
-//	}
-//	/*
-//	 * END SETUP
-//	 */
-//
-//	// Lookup DC by Zone
-//	lookupRegion := "k8s-region-US"
-//	lookupZone := "k8s-zone-US-east"
-//
-//	zoneInfo, err := connMgr.WhichVCandDCByZone(ctx, config.Labels.Zone, config.Labels.Region, lookupZone, lookupRegion)
-//	if err != nil {
-//		t.Fatalf("WhichVCandDCByZone failed err=%v", err)
-//	}
-//	if zoneInfo == nil {
-//		t.Fatalf("WhichVCandDCByZone zoneInfo=nil")
-//	}
-//
-//	if !strings.EqualFold("DC1", zoneInfo.DataCenter.Name()) {
-//		t.Errorf("Datacenter mismatch DC1 != %s", zoneInfo.DataCenter.Name())
-//	}
-//}
-//
-//func TestLookupZoneByMoref(t *testing.T) {
-//	config, cleanup := configFromEnvOrSim(false)
-//	defer cleanup()
-//
-//	connMgr := NewConnectionManager(config, nil, nil)
-//	defer connMgr.Logout()
-//
-//	// context
-//	ctx := context.Background()
-//
-//	// Get the inCloud Sphere Instance
-//	vsi := connMgr.ICSInstanceMap[config.Global.ICenterIP]
-//
-//	err := connMgr.Connect(ctx, vsi)
-//	if err != nil {
-//		t.Errorf("Failed to Connect to inCloud Sphere: %s", err)
-//	}
-//
-//	// Tag manager instance
-//	restClient := rest.NewClient(vsi.Conn.Client)
-//	user := url.UserPassword(vsi.Conn.Username, vsi.Conn.Password)
-//	if err := restClient.Login(ctx, user); err != nil {
-//		t.Fatalf("Rest login failed. err=%v", err)
-//	}
-//
-//	m := tags.NewManager(restClient)
-//
-//	/*
-//	 * START SETUP
-//	 */
-//	// Get a simulator Host
-//	myHost := simulator.Map.Any("HostSystem").(*simulator.HostSystem)
-//
-//	// Create a region category
-//	regionID, err := m.CreateCategory(ctx, &tags.Category{Name: config.Labels.Region})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a region tag
-//	regionID, err = m.CreateTag(ctx, &tags.Tag{CategoryID: regionID, Name: "k8s-region-US"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a zone category
-//	zoneID, err := m.CreateCategory(ctx, &tags.Category{Name: config.Labels.Zone})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Create a zone tags
-//	zoneIDwest, err := m.CreateTag(ctx, &tags.Tag{CategoryID: zoneID, Name: "k8s-zone-US-west"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//	zoneIDeast, err := m.CreateTag(ctx, &tags.Tag{CategoryID: zoneID, Name: "k8s-zone-US-east"})
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Setup a single-DC environment with zones!
-//	// Setup DC0
-//	dc0, err := vclib.GetDatacenter(ctx, vsi.Conn, "DC0")
-//	if err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Attach tag to DC0
-//	if err = m.AttachTag(ctx, regionID, dc0); err != nil {
-//		t.Fatal(err)
-//	}
-//	if err = m.AttachTag(ctx, zoneIDwest, dc0); err != nil {
-//		t.Fatal(err)
-//	}
-//
-//	// Attach tag to HostSystem
-//	if err = m.AttachTag(ctx, regionID, myHost); err != nil {
-//		t.Fatal(err)
-//	}
-//	if err = m.AttachTag(ctx, zoneIDeast, myHost); err != nil {
-//		t.Fatal(err)
-//	}
-//	/*
-//	 * END SETUP
-//	 */
-//
-//	// Get Host zone found directly on the Host object. Overrides Datacenter. Ancessor enabled but won't use.
-//	kv, err := connMgr.LookupZoneByMoref(ctx, config.Global.ICenterIP, myHost.Reference(), config.Labels.Zone, config.Labels.Region)
-//	if err != nil {
-//		t.Fatalf("[HOST] LookupZoneByMoref failed err=%v", err)
-//	}
-//
-//	region := kv[RegionLabel]
-//	zone := kv[ZoneLabel]
-//	if !strings.EqualFold("k8s-region-US", region) {
-//		t.Errorf("Region value mismatch k8s-region-US != %s", region)
-//	}
-//	if !strings.EqualFold("k8s-zone-US-east", zone) {
-//		t.Errorf("Region value mismatch k8s-zone-US-east != %s", zone)
-//	}
-//}
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+)
+
+func TestWhichICSandDCByZoneSingleDC(t *testing.T) {
+	config, _, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+
+	// context
+	ctx := context.Background()
+
+	zoneInfo, err := connMgr.WhichICSandDCByZone(ctx, config.Labels.Zone, config.Labels.Region, "", "")
+	if err != nil {
+		t.Fatalf("WhichICSandDCByZone failed err=%v", err)
+	}
+	if zoneInfo == nil {
+		t.Fatalf("WhichICSandDCByZone zoneInfo=nil")
+	}
+
+	if !strings.EqualFold("DC0", zoneInfo.DataCenter.Name) {
+		t.Errorf("Datacenter mismatch DC0 != %s", zoneInfo.DataCenter.Name)
+	}
+}
+
+func TestWhichICSandDCByZoneMultiDC(t *testing.T) {
+	config, model, cleanup := configFromSim(true)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+
+	// context
+	ctx := context.Background()
+
+	/*
+	 * START SETUP
+	 */
+	// Create a region tag
+	regionID := model.AddTag(types.Tag{Name: "k8s-region-US", Description: config.Labels.Region})
+
+	// Create zone tags
+	zoneIDwest := model.AddTag(types.Tag{Name: "k8s-zone-US-west", Description: config.Labels.Zone})
+	zoneIDeast := model.AddTag(types.Tag{Name: "k8s-zone-US-east", Description: config.Labels.Zone})
+
+	// Setup a multi-DC environment with zones!
+	// Attach tags to DC0 and DC1
+	for _, dc := range model.Datacenters() {
+		zoneID := zoneIDwest
+		if dc.Name == "DC1" {
+			zoneID = zoneIDeast
+		}
+		if err := model.AttachTag(regionID, dc.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := model.AttachTag(zoneID, dc.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	/*
+	 * END SETUP
+	 */
+
+	// Lookup DC by Zone
+	lookupRegion := "k8s-region-US"
+	lookupZone := "k8s-zone-US-east"
+
+	zoneInfo, err := connMgr.WhichICSandDCByZone(ctx, config.Labels.Zone, config.Labels.Region, lookupZone, lookupRegion)
+	if err != nil {
+		t.Fatalf("WhichICSandDCByZone failed err=%v", err)
+	}
+	if zoneInfo == nil {
+		t.Fatalf("WhichICSandDCByZone zoneInfo=nil")
+	}
+
+	if !strings.EqualFold("DC1", zoneInfo.DataCenter.Name) {
+		t.Errorf("Datacenter mismatch DC1 != %s", zoneInfo.DataCenter.Name)
+	}
+
+	// The hosts of both datacenters were looked up with a single session.
+	if logins := model.Logins(); logins != 1 {
+		t.Errorf("%d logins, expected 1", logins)
+	}
+}
+
+func TestLookupZoneByMoref(t *testing.T) {
+	config, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+
+	// context
+	ctx := context.Background()
+
+	/*
+	 * START SETUP
+	 */
+	// Get a simulator Host
+	myHost := model.Hosts()[0]
+
+	// Create a region tag
+	regionID := model.AddTag(types.Tag{Name: "k8s-region-US", Description: config.Labels.Region})
+
+	// Create a zone tag
+	zoneIDeast := model.AddTag(types.Tag{Name: "k8s-zone-US-east", Description: config.Labels.Zone})
+
+	// Attach the region tag to DC0
+	if err := model.AttachTag(regionID, myHost.DataCenterID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Attach zone tag to the Host
+	if err := model.AttachTag(zoneIDeast, myHost.ID); err != nil {
+		t.Fatal(err)
+	}
+	/*
+	 * END SETUP
+	 */
+
+	// Get Host zone found directly on the Host object.
+	// Region is inherited from the Datacenter.
+	kv, err := connMgr.LookupZoneByMoref(ctx, config.Global.ICenterIP, myHost.ID, config.Labels.Zone, config.Labels.Region)
+	if err != nil {
+		t.Fatalf("[HOST] LookupZoneByMoref failed err=%v", err)
+	}
+
+	region := kv[RegionLabel]
+	zone := kv[ZoneLabel]
+	if !strings.EqualFold("k8s-region-US", region) {
+		t.Errorf("Region value mismatch k8s-region-US != %s", region)
+	}
+	if !strings.EqualFold("k8s-zone-US-east", zone) {
+		t.Errorf("Zone value mismatch k8s-zone-US-east != %s", zone)
+	}
+}
+
+func TestLookupZoneByMorefSessionExpired(t *testing.T) {
+	config, model, cleanup := configFromSim(false)
+	defer cleanup()
+
+	connMgr := NewConnectionManager(config, nil, nil)
+	defer connMgr.Logout()
+
+	// context
+	ctx := context.Background()
+
+	myHost := model.Hosts()[0]
+	regionID := model.AddTag(types.Tag{Name: "k8s-region-US", Description: config.Labels.Region})
+	zoneID := model.AddTag(types.Tag{Name: "k8s-zone-US-west", Description: config.Labels.Zone})
+	for _, tagID := range []string{regionID, zoneID} {
+		if err := model.AttachTag(tagID, myHost.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lookup := func() {
+		kv, err := connMgr.LookupZoneByMoref(ctx, config.Global.ICenterIP, myHost.ID, config.Labels.Zone, config.Labels.Region)
+		if err != nil {
+			t.Fatalf("LookupZoneByMoref failed err=%v", err)
+		}
+		if kv[ZoneLabel] != "k8s-zone-US-west" || kv[RegionLabel] != "k8s-region-US" {
+			t.Errorf("unexpected zone and region %v", kv)
+		}
+	}
+
+	// Lookups share the session instead of logging in and out every time.
+	for i := 0; i < 3; i++ {
+		lookup()
+	}
+	if logins := model.Logins(); logins != 1 {
+		t.Errorf("%d logins, expected 1", logins)
+	}
+
+	// An expired session is replaced transparently.
+	model.ExpireSessions()
+	lookup()
+	if logins := model.Logins(); logins != 2 {
+		t.Errorf("%d logins after the session expired, expected 2", logins)
+	}
+}
@@ -18,23 +18,39 @@ package icslib
 
 import (
 	icsgo "github.com/inspur-ics/ics-go-sdk"
-	"github.com/inspur-ics/ics-go-sdk/client"
-	"k8s.io/klog"
 )
 
+// Session is the session to an iCenter that the objects are read with.
+type Session interface {
+	// Connection returns the connection of the current session, nil if
+	// there is none.
+	Connection() *icsgo.ICSConnection
+}
+
 // Common contains the fields and functions common to all objects.
 type Common struct {
-	Con *icsgo.ICSConnection
+	Session Session
 }
 
-func NewCommon(con *icsgo.ICSConnection) Common {
-	return Common{Con: con}
+func NewCommon(session Session) Common {
+	return Common{Session: session}
 }
 
-func (c Common) Client() *client.Client {
-	client, err := c.Con.GetClient()
-	if err != nil {
-		klog.Errorf("Cannot get  ics token. error: %q", err)
+// connection returns the connection of the current session, so that the
+// objects outlive the session they were read with.
+func (c Common) connection() (*icsgo.ICSConnection, error) {
+	return sessionConnection(c.Session)
+}
+
+// sessionConnection returns the connection of the current session, or
+// ErrNoSession if there is none.
+func sessionConnection(session Session) (*icsgo.ICSConnection, error) {
+	if session == nil {
+		return nil, ErrNoSession
+	}
+	connection := session.Connection()
+	if connection == nil || connection.Client == nil {
+		return nil, ErrNoSession
 	}
-	return client
+	return connection, nil
 }
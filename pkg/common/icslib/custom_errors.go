@@ -29,6 +29,7 @@ const (
 	NoDatastoreFoundErrMsg         = "Datastore not found"
 	NoDatacenterFoundErrMsg        = "Datacenter not found"
 	NoDataStoreClustersFoundErrMsg = "No DatastoreClusters Found"
+	NoSessionErrMsg                = "No session to iCenter"
 )
 
 // Error constants
@@ -42,4 +43,5 @@ var (
 	ErrNoDatastoreFound         = errors.New(NoDatastoreFoundErrMsg)
 	ErrNoDatacenterFound        = errors.New(NoDatacenterFoundErrMsg)
 	ErrNoDataStoreClustersFound = errors.New(NoDataStoreClustersFoundErrMsg)
+	ErrNoSession                = errors.New(NoSessionErrMsg)
 )
@@ -18,16 +18,24 @@ package icslib
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
 
-	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/inspur-ics/ics-go-sdk/client/methods"
 	"github.com/inspur-ics/ics-go-sdk/client/types"
 	dc "github.com/inspur-ics/ics-go-sdk/datacenter"
 	st "github.com/inspur-ics/ics-go-sdk/storage"
 	"github.com/inspur-ics/ics-go-sdk/vm"
 	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 )
 
+// vmPageSize is the number of VMs requested per page.
+const vmPageSize = 100
+
 // Datacenter extends the govmomi Datacenter object
 type Datacenter struct {
 	Common
@@ -36,49 +44,58 @@ type Datacenter struct {
 
 // GetDatacenter returns the DataCenter Object for the given datacenterPath
 // If datacenter is located in a folder, include full path to datacenter else just provide the datacenter name
-func GetDatacenter(ctx context.Context, connection *icsgo.ICSConnection, datacenterPath string) (*Datacenter, error) {
-	client, err := connection.GetClient()
+func GetDatacenter(ctx context.Context, session Session, datacenterPath string) (*Datacenter, error) {
+	connection, err := sessionConnection(session)
 	if err != nil {
 		return nil, err
 	}
+	client := connection.Client
 	datacenterService := dc.NewDatacenterService(client)
+	start := time.Now()
 	datacenter, err := datacenterService.GetDatacenter(ctx, datacenterPath)
+	metrics.RecordAPICall("get_datacenter", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find the datacenter: %s. err: %+v", datacenterPath, err)
 		return nil, err
 	}
-	dc := Datacenter{Common{connection}, datacenter}
+	dc := Datacenter{Common{session}, datacenter}
 	return &dc, nil
 }
 
 // GetAllDatacenter returns all the DataCenter Objects
-func GetAllDatacenter(ctx context.Context, connection *icsgo.ICSConnection) ([]*Datacenter, error) {
+func GetAllDatacenter(ctx context.Context, session Session) ([]*Datacenter, error) {
 	var dcs []*Datacenter
-	client, err := connection.GetClient()
+	connection, err := sessionConnection(session)
 	if err != nil {
 		return nil, err
 	}
+	client := connection.Client
 	datacenterService := dc.NewDatacenterService(client)
+	start := time.Now()
 	datacenters, err := datacenterService.GetAllDatacenters(ctx)
+	metrics.RecordAPICall("list_datacenters", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find the datacenter. err: %+v", err)
 		return nil, err
 	}
 	for _, datacenter := range datacenters {
-		dcs = append(dcs, &(Datacenter{Common{connection}, datacenter}))
+		dcs = append(dcs, &(Datacenter{Common{session}, datacenter}))
 	}
 
 	return dcs, nil
 }
 
 // GetNumberOfDatacenters returns the number of DataCenters in this vCenter
-func GetNumberOfDatacenters(ctx context.Context, connection *icsgo.ICSConnection) (int, error) {
-	client, err := connection.GetClient()
+func GetNumberOfDatacenters(ctx context.Context, session Session) (int, error) {
+	connection, err := sessionConnection(session)
 	if err != nil {
 		return 0, err
 	}
+	client := connection.Client
 	datacenterService := dc.NewDatacenterService(client)
+	start := time.Now()
 	datacenters, err := datacenterService.GetAllDatacenters(ctx)
+	metrics.RecordAPICall("list_datacenters", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find the datacenter. err: %+v", err)
 		return 0, err
@@ -88,74 +105,167 @@ func GetNumberOfDatacenters(ctx context.Context, connection *icsgo.ICSConnection
 
 // GetVMByIP gets the VM object from the given IP address
 func (dc *Datacenter) GetVMByIP(ctx context.Context, ipAddy string) (*VirtualMachine, error) {
-	vmService := vm.NewVirtualMachineService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	vmService := vm.NewVirtualMachineService(connection.Client)
 	ipAddy = strings.ToLower(strings.TrimSpace(ipAddy))
+	start := time.Now()
 	vm, err := vmService.GetVMByIP(ctx, ipAddy)
+	metrics.RecordAPICall("get_vm_by_ip", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find VM by IP. VM IP: %s, err: %+v", ipAddy, err)
 		return nil, err
 	}
-	if vm == nil {
+	if !dc.owns(vm) {
 		klog.Errorf("Unable to find VM by IP. VM IP: %s", ipAddy)
 		return nil, ErrNoVMFound
 	}
-	virtualMachine := VirtualMachine{Common{dc.Con}, vm, dc}
+	virtualMachine := VirtualMachine{dc.Common, vm, dc}
 	return &virtualMachine, nil
 }
 
 // GetVMByDNSName gets the VM object from the given dns name
 func (dc *Datacenter) GetVMByDNSName(ctx context.Context, dnsName string) (*VirtualMachine, error) {
-	vmService := vm.NewVirtualMachineService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	vmService := vm.NewVirtualMachineService(connection.Client)
 	dnsName = strings.ToLower(strings.TrimSpace(dnsName))
+	start := time.Now()
 	vm, err := vmService.GetVMByName(ctx, dnsName)
+	metrics.RecordAPICall("get_vm_by_name", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find VM by DNS Name. VM DNS Name: %s, err: %+v", dnsName, err)
 		return nil, err
 	}
-	if vm == nil {
+	if !dc.owns(vm) {
 		klog.Errorf("Unable to find VM by DNS Name. VM DNS Name: %s", dnsName)
 		return nil, ErrNoVMFound
 	}
-	virtualMachine := VirtualMachine{Common{dc.Con}, vm, dc}
+	virtualMachine := VirtualMachine{dc.Common, vm, dc}
 	return &virtualMachine, nil
 }
 
 // GetVMByUUID gets the VM object from the given vmUUID
 func (dc *Datacenter) GetVMByUUID(ctx context.Context, vmUUID string) (*VirtualMachine, error) {
-	vmService := vm.NewVirtualMachineService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	vmService := vm.NewVirtualMachineService(connection.Client)
 	vmUUID = strings.ToLower(strings.TrimSpace(vmUUID))
+	start := time.Now()
 	vm, err := vmService.GetVMByUUID(ctx, vmUUID)
+	metrics.RecordAPICall("get_vm_by_uuid", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find VM by UUID. VM UUID: %s, err: %+v", vmUUID, err)
 		return nil, err
 	}
-	if vm == nil {
+	if !dc.owns(vm) {
 		klog.Errorf("Unable to find VM by UUID. VM UUID: %s", vmUUID)
 		return nil, ErrNoVMFound
 	}
-	virtualMachine := VirtualMachine{Common{dc.Con}, vm, dc}
+	virtualMachine := VirtualMachine{dc.Common, vm, dc}
 	return &virtualMachine, nil
 }
 
+// owns reports whether vm is a VM found in this datacenter. The iCenter VM
+// lookups are not scoped to a datacenter and return an empty VM on a miss.
+func (dc *Datacenter) owns(vm *types.VirtualMachine) bool {
+	return vm != nil && vm.ID != "" && vm.DataCenterID == dc.ID
+}
+
 // GetVMByPath gets the VM object from the given vmPath
 // vmPath should be the full path to VM and not just the name
 func (dc *Datacenter) GetVMByPath(ctx context.Context, vmPath string) (*VirtualMachine, error) {
-	vmService := vm.NewVirtualMachineService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	vmService := vm.NewVirtualMachineService(connection.Client)
 	vmPath = strings.ToLower(strings.TrimSpace(vmPath))
+	start := time.Now()
 	vm, err := vmService.GetVMByPath(ctx, vmPath)
+	metrics.RecordAPICall("get_vm_by_path", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to find VM by Path. VM Path: %s, err: %+v", vmPath, err)
 		return nil, err
 	}
-	virtualMachine := VirtualMachine{Common{dc.Con}, vm, dc}
+	virtualMachine := VirtualMachine{dc.Common, vm, dc}
 	return &virtualMachine, nil
 }
 
+// GetAllVMs gets all the VM objects in the datacenter, a page at a time.
+func (dc *Datacenter) GetAllVMs(ctx context.Context) ([]*VirtualMachine, error) {
+	vms, err := getDatacenterVMList(ctx, dc)
+	if err != nil {
+		klog.Errorf("Failed to list VMs in datacenter: %s. err: %+v", dc.Name, err)
+		return nil, err
+	}
+	virtualMachines := make([]*VirtualMachine, 0, len(vms))
+	for _, vm := range vms {
+		virtualMachines = append(virtualMachines, &VirtualMachine{dc.Common, vm, dc})
+	}
+	return virtualMachines, nil
+}
+
+// getDatacenterVMList pages through the VMs of the datacenter, iCenter only
+// returns the first page of a list when no page is requested.
+func getDatacenterVMList(ctx context.Context, datacenter *Datacenter) ([]*types.VirtualMachine, error) {
+	var vms []*types.VirtualMachine
+	for page := 1; ; page++ {
+		resp, err := getDatacenterVMPage(ctx, datacenter, vmPageSize, page)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range resp.Items {
+			vms = append(vms, &resp.Items[idx])
+		}
+		if len(resp.Items) < vmPageSize || len(vms) >= resp.TotalSize || page >= resp.TotalPage {
+			return vms, nil
+		}
+	}
+}
+
+func getDatacenterVMPage(ctx context.Context, datacenter *Datacenter, pageSize int, currentPage int) (*types.VMPageResponse, error) {
+	var reqBody *types.Common
+	api := types.ICSApi{
+		Api:   fmt.Sprintf("/datacenters/%s/vms?pageSize=%d&currentPage=%d", datacenter.ID, pageSize, currentPage),
+		Token: true,
+	}
+	connection, err := datacenter.connection()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := connection.Client.RestAPITripper.GetTrip(ctx, api, reqBody)
+	respBody, err := methods.HandleResponse(resp, err)
+	metrics.RecordAPICall("list_datacenter_vms", connection, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var page types.VMPageResponse
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
 // GetAllDatastores gets the datastore URL to DatastoreInfo map for all the datastores in
 // the datacenter.
 func (dc *Datacenter) GetAllDatastores(ctx context.Context) (map[string]*DatastoreInfo, error) {
-	sts := st.NewStorageService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	sts := st.NewStorageService(connection.Client)
+	start := time.Now()
 	datastores, err := sts.GetAllDatastores(ctx, dc.ID)
+	metrics.RecordAPICall("list_datastores", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed to get all the datastores. err: %+v", err)
 		return nil, err
@@ -163,7 +273,7 @@ func (dc *Datacenter) GetAllDatastores(ctx context.Context) (map[string]*Datasto
 	dsURLInfoMap := make(map[string]*DatastoreInfo)
 	for _, dsMo := range datastores {
 		dsURLInfoMap[dsMo.MountPath] = &DatastoreInfo{
-			&Datastore{Common{Con: dc.Con},
+			&Datastore{dc.Common,
 				dsMo,
 				dc}}
 	}
@@ -178,15 +288,21 @@ func (dc *Datacenter) GetDatastoreByPath(ctx context.Context, vmDiskPath string)
 
 // GetDatastoreByName gets the Datastore object for the given datastore name
 func (dc *Datacenter) GetDatastoreByName(ctx context.Context, name string) (*DatastoreInfo, error) {
-	sts := st.NewStorageService(dc.Client())
+	connection, err := dc.connection()
+	if err != nil {
+		return nil, err
+	}
+	sts := st.NewStorageService(connection.Client)
+	start := time.Now()
 	datastore, err := sts.GetStorageInfoByName(ctx, name)
+	metrics.RecordAPICall("get_datastore_by_name", connection, start, err)
 	if err != nil {
 		klog.Errorf("Failed while searching for datastore: %s. err: %+v", name, err)
 		return nil, err
 	}
 
 	return &DatastoreInfo{
-		&Datastore{Common{Con: dc.Con},
+		&Datastore{dc.Common,
 			datastore,
 			dc}}, nil
 }
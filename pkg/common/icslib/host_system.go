@@ -2,10 +2,12 @@ package icslib
 
 import (
     "context"
-    icsgo "github.com/inspur-ics/ics-go-sdk"
+    "time"
     "github.com/inspur-ics/ics-go-sdk/client/types"
     ht "github.com/inspur-ics/ics-go-sdk/host"
     "k8s.io/klog"
+
+    "github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
 )
 
 type HostSystem struct {
@@ -15,20 +17,23 @@ type HostSystem struct {
 
 // GetDatacenter returns the DataCenter Object for the given datacenterPath
 // If datacenter is located in a folder, include full path to datacenter else just provide the datacenter name
-func GetHostSystemListByDC(ctx context.Context, connection *icsgo.ICSConnection, datacenterPath string) ([]*HostSystem, error) {
+func GetHostSystemListByDC(ctx context.Context, session Session, datacenterPath string) ([]*HostSystem, error) {
     var hostSystems []*HostSystem
-    client, err := connection.GetClient()
+    connection, err := sessionConnection(session)
     if err != nil {
         return nil, err
     }
+    client := connection.Client
     hostService := ht.NewHostService(client)
+    start := time.Now()
     hosts, err := hostService.GetHostListByDC(ctx, datacenterPath)
+    metrics.RecordAPICall("list_hosts", connection, start, err)
     if err != nil {
         klog.Errorf("Failed to find the datacenter. err: %+v", err)
         return nil, err
     }
     for _, host := range hosts {
-        hostSystems = append(hostSystems, &(HostSystem{Common{connection}, host}))
+        hostSystems = append(hostSystems, &(HostSystem{Common{session}, host}))
     }
 
     return hostSystems, nil
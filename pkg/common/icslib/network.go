@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icslib
+
+import (
+	"context"
+	"time"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	"github.com/inspur-ics/ics-go-sdk/network"
+	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+)
+
+// GetAllNetworks returns all the networks of the iCenter.
+func GetAllNetworks(ctx context.Context, session Session) ([]types.Network, error) {
+	connection, err := sessionConnection(session)
+	if err != nil {
+		return nil, err
+	}
+	client := connection.Client
+	start := time.Now()
+	networks, err := network.NewNetworkService(client).GetNetworkList(ctx)
+	metrics.RecordAPICall("list_networks", connection, start, err)
+	if err != nil {
+		klog.Errorf("Failed to list the networks. err: %+v", err)
+		return nil, err
+	}
+	return networks, nil
+}
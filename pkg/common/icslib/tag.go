@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icslib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/inspur-ics/ics-go-sdk/client/methods"
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	"k8s.io/klog"
+
+	"github.com/inspur-ics/cloud-provider-ics/pkg/common/metrics"
+)
+
+// tagPageSize is the number of tags requested per page.
+const tagPageSize = 100
+
+type tagPageResponse struct {
+	types.PageResponse
+	Items []types.Tag `json:"items"`
+}
+
+// GetAllTags returns every tag of the iCenter, a page at a time. iCenter
+// keeps the category of a tag in its description.
+func GetAllTags(ctx context.Context, session Session) ([]types.Tag, error) {
+	connection, err := sessionConnection(session)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []types.Tag
+	for page := 1; ; page++ {
+		resp, err := getTagPage(ctx, connection, tagPageSize, page)
+		if err != nil {
+			klog.Errorf("Failed to list the tags. err: %+v", err)
+			return nil, err
+		}
+		tags = append(tags, resp.Items...)
+		if len(resp.Items) == 0 || len(tags) >= resp.TotalSize || page >= resp.TotalPage {
+			return tags, nil
+		}
+	}
+}
+
+func getTagPage(ctx context.Context, connection *icsgo.ICSConnection, pageSize int, currentPage int) (*tagPageResponse, error) {
+	var reqBody *types.Common
+	api := types.ICSApi{
+		Api:   fmt.Sprintf("/tags?pageSize=%d&currentPage=%d", pageSize, currentPage),
+		Token: true,
+	}
+	start := time.Now()
+	resp, err := connection.Client.RestAPITripper.GetTrip(ctx, api, reqBody)
+	respBody, err := methods.HandleResponse(resp, err)
+	metrics.RecordAPICall("list_tags", connection, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var page tagPageResponse
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icssim
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+)
+
+// Default credentials accepted by a Model.
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "123456"
+)
+
+// DefaultPageSize is the number of VMs a datacenter VM list, or of tags the
+// tag list, returns when the request asks for no page size.
+const DefaultPageSize = 10
+
+// Error constants
+var (
+	// ErrNotFound is returned when the requested inventory object does not
+	// exist in the Model.
+	ErrNotFound = errors.New("object not found")
+)
+
+// Model is an in-memory iCenter inventory. Tests populate it through the
+// Add* methods and then point an ICSConnection at a Server serving it.
+type Model struct {
+	sync.RWMutex
+
+	// Username and Password are the credentials accepted by /authentication.
+	Username string
+	Password string
+
+	// PageSize is the number of VMs a datacenter VM list, or of tags the tag
+	// list, returns when the request asks for no page size. Zero returns
+	// every one.
+	PageSize int
+
+	// Datacenter, Host and Machine size the inventory built by Create.
+	Datacenter int
+	Host       int
+	Machine    int
+
+	datacenters []*types.Datacenter
+	clusters    []*types.Cluster
+	hosts       []*types.Host
+	vms         []*types.VirtualMachine
+	storages    []*types.Storage
+	networks    []*types.Network
+	tags        []*types.Tag
+
+	// Maps source ID (host, cluster, datacenter, VM) to attached tag IDs.
+	bindings map[string]map[string]bool
+	// Maps session token to user name.
+	sessions map[string]string
+	// Number of successful logins.
+	logins int
+
+	seq int
+}
+
+// NewModel returns an empty Model accepting the default credentials.
+func NewModel() *Model {
+	return &Model{
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		PageSize: DefaultPageSize,
+		bindings: make(map[string]map[string]bool),
+		sessions: make(map[string]string),
+	}
+}
+
+// nextID returns a unique, iCenter style object ID. Callers must hold the lock.
+func (m *Model) nextID() string {
+	m.seq++
+	return fmt.Sprintf("%032x", m.seq)
+}
+
+// UUIDFromName returns a stable UUID derived from the given name.
+func UUIDFromName(name string) string {
+	sum := md5.Sum([]byte(name))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// AddDatacenter adds a datacenter to the inventory and returns its ID.
+// The ID is generated if dc.ID is empty.
+func (m *Model) AddDatacenter(dc types.Datacenter) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if dc.ID == "" {
+		dc.ID = m.nextID()
+	}
+	m.datacenters = append(m.datacenters, &dc)
+	return dc.ID
+}
+
+// AddCluster adds a cluster to the inventory and returns its ID.
+func (m *Model) AddCluster(cluster types.Cluster) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if cluster.Id == "" {
+		cluster.Id = m.nextID()
+	}
+	m.clusters = append(m.clusters, &cluster)
+	return cluster.Id
+}
+
+// AddHost adds a host to the inventory and returns its ID.
+func (m *Model) AddHost(host types.Host) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if host.ID == "" {
+		host.ID = m.nextID()
+	}
+	if host.Status == "" {
+		host.Status = "CONNECTED"
+	}
+	m.hosts = append(m.hosts, &host)
+	return host.ID
+}
+
+// AddVM adds a virtual machine to the inventory and returns its ID. The UUID
+// is derived from the VM name if vm.UUID is empty.
+func (m *Model) AddVM(vm types.VirtualMachine) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if vm.ID == "" {
+		vm.ID = m.nextID()
+	}
+	if vm.UUID == "" {
+		vm.UUID = UUIDFromName(vm.Name)
+	}
+	if vm.Status == "" {
+		vm.Status = "STARTED"
+	}
+	m.vms = append(m.vms, &vm)
+	return vm.ID
+}
+
+// AddStorage adds a datastore to the inventory and returns its ID.
+func (m *Model) AddStorage(storage types.Storage) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if storage.ID == "" {
+		storage.ID = m.nextID()
+	}
+	m.storages = append(m.storages, &storage)
+	return storage.ID
+}
+
+// AddNetwork adds a network to the inventory and returns its ID.
+func (m *Model) AddNetwork(network types.Network) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if network.ID == "" {
+		network.ID = m.nextID()
+	}
+	m.networks = append(m.networks, &network)
+	return network.ID
+}
+
+// AddTag adds a tag to the inventory and returns its ID. iCenter stores the
+// tag category in the tag description.
+func (m *Model) AddTag(tag types.Tag) string {
+	m.Lock()
+	defer m.Unlock()
+
+	if tag.ID == "" {
+		tag.ID = m.nextID()
+	}
+	m.tags = append(m.tags, &tag)
+	return tag.ID
+}
+
+// AttachTag binds the tag to the inventory object with the given ID.
+func (m *Model) AttachTag(tagID string, sourceID string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.findTag(tagID) == nil {
+		return ErrNotFound
+	}
+	if m.bindings[sourceID] == nil {
+		m.bindings[sourceID] = make(map[string]bool)
+	}
+	m.bindings[sourceID][tagID] = true
+	return nil
+}
+
+// DetachTag removes the binding between the tag and the inventory object.
+func (m *Model) DetachTag(tagID string, sourceID string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.bindings[sourceID][tagID] {
+		return ErrNotFound
+	}
+	delete(m.bindings[sourceID], tagID)
+	return nil
+}
+
+// UpdateVM applies f to the VM with the given ID while holding the lock.
+func (m *Model) UpdateVM(id string, f func(vm *types.VirtualMachine)) error {
+	m.Lock()
+	defer m.Unlock()
+
+	vm := m.findVM(id)
+	if vm == nil {
+		return ErrNotFound
+	}
+	f(vm)
+	return nil
+}
+
+// UpdateHost applies f to the host with the given ID while holding the lock.
+func (m *Model) UpdateHost(id string, f func(host *types.Host)) error {
+	m.Lock()
+	defer m.Unlock()
+
+	host := m.findHost(id)
+	if host == nil {
+		return ErrNotFound
+	}
+	f(host)
+	return nil
+}
+
+// UpdateCluster applies f to the cluster with the given ID while holding the
+// lock.
+func (m *Model) UpdateCluster(id string, f func(cluster *types.Cluster)) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, cluster := range m.clusters {
+		if cluster.Id == id {
+			f(cluster)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// RemoveVM deletes the VM with the given ID from the inventory.
+func (m *Model) RemoveVM(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for i, vm := range m.vms {
+		if vm.ID == id {
+			m.vms = append(m.vms[:i], m.vms[i+1:]...)
+			delete(m.bindings, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Datacenters returns a copy of all datacenters in the inventory.
+func (m *Model) Datacenters() []types.Datacenter {
+	m.RLock()
+	defer m.RUnlock()
+
+	dcs := make([]types.Datacenter, 0, len(m.datacenters))
+	for _, dc := range m.datacenters {
+		dcs = append(dcs, *dc)
+	}
+	return dcs
+}
+
+// Hosts returns a copy of all hosts in the inventory.
+func (m *Model) Hosts() []types.Host {
+	m.RLock()
+	defer m.RUnlock()
+
+	hosts := make([]types.Host, 0, len(m.hosts))
+	for _, host := range m.hosts {
+		hosts = append(hosts, *host)
+	}
+	return hosts
+}
+
+// VMs returns a copy of all virtual machines in the inventory.
+func (m *Model) VMs() []types.VirtualMachine {
+	m.RLock()
+	defer m.RUnlock()
+
+	vms := make([]types.VirtualMachine, 0, len(m.vms))
+	for _, vm := range m.vms {
+		vms = append(vms, *vm)
+	}
+	return vms
+}
+
+// FindVMByName returns a copy of the VM with the given name.
+func (m *Model) FindVMByName(name string) (types.VirtualMachine, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, vm := range m.vms {
+		if strings.EqualFold(vm.Name, name) {
+			return *vm, nil
+		}
+	}
+	return types.VirtualMachine{}, ErrNotFound
+}
+
+// Login validates the credentials and returns a new session token.
+func (m *Model) login(username string, password string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if username != m.Username || password != m.Password {
+		return "", false
+	}
+	token := m.nextID()
+	m.sessions[token] = username
+	m.logins++
+	return token, true
+}
+
+// Logins returns the number of successful logins.
+func (m *Model) Logins() int {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.logins
+}
+
+func (m *Model) logout(token string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.sessions, token)
+}
+
+func (m *Model) validSession(token string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	_, ok := m.sessions[token]
+	return ok
+}
+
+// ExpireSessions invalidates every active session, as if iCenter had been
+// restarted or the sessions had timed out.
+func (m *Model) ExpireSessions() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.sessions = make(map[string]string)
+}
+
+func (m *Model) findDatacenter(id string) *types.Datacenter {
+	for _, dc := range m.datacenters {
+		if dc.ID == id {
+			return dc
+		}
+	}
+	return nil
+}
+
+func (m *Model) findHost(id string) *types.Host {
+	for _, host := range m.hosts {
+		if host.ID == id {
+			return host
+		}
+	}
+	return nil
+}
+
+func (m *Model) findVM(id string) *types.VirtualMachine {
+	for _, vm := range m.vms {
+		if vm.ID == id {
+			return vm
+		}
+	}
+	return nil
+}
+
+func (m *Model) findTag(id string) *types.Tag {
+	for _, tag := range m.tags {
+		if tag.ID == id {
+			return tag
+		}
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icssim
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	"k8s.io/klog"
+)
+
+// Server is an iCenter REST endpoint backed by a Model. It serves the subset
+// of the iCenter API consumed by ics-go-sdk.
+type Server struct {
+	*httptest.Server
+
+	Model *Model
+}
+
+// NewServer starts a TLS server serving the given Model. Callers must Close
+// the server when done.
+func NewServer(m *Model) *Server {
+	s := NewUnstartedServer(m)
+	s.StartTLS()
+	return s
+}
+
+// NewUnstartedServer returns a Server serving the given Model without
+// starting it, so that the TLS configuration can be changed before calling
+// StartTLS.
+func NewUnstartedServer(m *Model) *Server {
+	s := &Server{Model: m}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Host returns the host the server is listening on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server is listening on.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return port
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// ics-go-sdk joins the base URL and the API path without trimming
+	// slashes, so requests arrive as "//vms" and the like.
+	p := path.Clean("/" + r.URL.Path)
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	query := r.URL.Query()
+
+	klog.V(6).Infof("icssim: %s %s", r.Method, r.URL.String())
+
+	if p == "/authentication" && r.Method == http.MethodPost {
+		s.login(w, r)
+		return
+	}
+
+	token := r.Header.Get("Authorization")
+	if !s.Model.validSession(token) {
+		writeError(w, http.StatusUnauthorized, "the session is not authenticated")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if p == "/logout" {
+		s.Model.logout(token)
+		writeJSON(w, types.Task{TaskId: "logout"})
+		return
+	}
+
+	m := s.Model
+	m.RLock()
+	defer m.RUnlock()
+
+	switch {
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "themes":
+		writeJSON(w, struct{}{})
+	case p == "/datacenters":
+		m.listDatacenters(w, query)
+	case len(parts) == 2 && parts[0] == "datacenters":
+		if dc := m.findDatacenter(parts[1]); dc != nil {
+			writeJSON(w, dc)
+			return
+		}
+		writeError(w, http.StatusNotFound, "datacenter not found")
+	case len(parts) == 3 && parts[0] == "datacenters" && parts[2] == "vms":
+		m.listVMs(w, query, m.PageSize, func(vm *types.VirtualMachine) bool {
+			return vm.DataCenterID == parts[1]
+		})
+	case len(parts) == 3 && parts[0] == "datacenters" && parts[2] == "hosts":
+		m.listHosts(w, func(host *types.Host) bool {
+			return host.DataCenterID == parts[1]
+		})
+	case p == "/hosts":
+		m.listHosts(w, func(*types.Host) bool { return true })
+	case len(parts) == 2 && parts[0] == "hosts":
+		if host := m.findHost(parts[1]); host != nil {
+			writeJSON(w, host)
+			return
+		}
+		writeError(w, http.StatusNotFound, "host not found")
+	case len(parts) == 3 && parts[0] == "hosts" && parts[2] == "healthperform":
+		if host := m.findHost(parts[1]); host != nil {
+			writeJSON(w, types.HostHealthInfo{ID: host.ID, IP: host.IP, Score: 100})
+			return
+		}
+		writeError(w, http.StatusNotFound, "host not found")
+	case p == "/clusters":
+		items := make([]types.Cluster, 0, len(m.clusters))
+		for _, cluster := range m.clusters {
+			items = append(items, *cluster)
+		}
+		writeJSON(w, types.ClusterListRsp{Items: items})
+	case p == "/vms":
+		m.listVMs(w, query, 0, func(vm *types.VirtualMachine) bool {
+			if name := query.Get("name"); name != "" && !strings.EqualFold(vm.Name, name) {
+				return false
+			}
+			if ip := query.Get("vnicIp"); ip != "" && !hasIP(vm, ip) {
+				return false
+			}
+			return true
+		})
+	case len(parts) == 2 && parts[0] == "vms":
+		if vm := m.findVM(parts[1]); vm != nil {
+			writeJSON(w, vm)
+			return
+		}
+		writeError(w, http.StatusNotFound, "vm not found")
+	case p == "/storages":
+		items := make([]types.Storage, 0, len(m.storages))
+		for _, storage := range m.storages {
+			items = append(items, *storage)
+		}
+		writeJSON(w, types.StoragePageResponse{
+			PageResponse: pageResponse(len(items), 0, 1),
+			Items:        items,
+		})
+	case len(parts) == 2 && parts[0] == "storages":
+		for _, storage := range m.storages {
+			if storage.ID == parts[1] {
+				writeJSON(w, storage)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "storage not found")
+	case p == "/networks":
+		items := make([]types.Network, 0, len(m.networks))
+		for _, network := range m.networks {
+			items = append(items, *network)
+		}
+		writeJSON(w, types.NetworkPageResponse{
+			TotalPage:   1,
+			CurrentPage: 1,
+			TotalSize:   len(items),
+			Items:       items,
+		})
+	case len(parts) == 2 && parts[0] == "networks":
+		for _, network := range m.networks {
+			if network.ID == parts[1] {
+				writeJSON(w, network)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "network not found")
+	case p == "/tags":
+		m.listTags(w, query)
+	case p == "/tags/bindings":
+		m.listBindings(w, query)
+	case len(parts) == 2 && parts[0] == "tags":
+		if tag := m.findTag(parts[1]); tag != nil {
+			writeJSON(w, tag)
+			return
+		}
+		writeError(w, http.StatusNotFound, "tag not found")
+	default:
+		writeError(w, http.StatusNotFound, "unknown API "+p)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req types.Login
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, ok := s.Model.login(req.Username, req.Password)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	writeJSON(w, types.LoginResponse{
+		UserId:    req.Username,
+		SessonId:  token,
+		Validated: true,
+		Username:  req.Username,
+		Locale:    req.Locale,
+		Domain:    req.Domain,
+	})
+}
+
+func (m *Model) listDatacenters(w http.ResponseWriter, query url.Values) {
+	// A lookup by name returns the datacenter itself rather than a page.
+	if name := query.Get("datacenterName"); name != "" {
+		for _, dc := range m.datacenters {
+			if dc.Name == name {
+				writeJSON(w, dc)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "datacenter not found")
+		return
+	}
+
+	items := make([]types.Datacenter, 0, len(m.datacenters))
+	for _, dc := range m.datacenters {
+		items = append(items, *dc)
+	}
+	writeJSON(w, types.DatacenterPageResponse{
+		PageResponse: pageResponse(len(items), 0, 1),
+		Items:        items,
+	})
+}
+
+func (m *Model) listHosts(w http.ResponseWriter, match func(*types.Host) bool) {
+	items := make([]types.Host, 0)
+	for _, host := range m.hosts {
+		if match(host) {
+			items = append(items, *host)
+		}
+	}
+	writeJSON(w, types.HostPageResponse{
+		TotalPage:   1,
+		CurrentPage: 1,
+		TotalSize:   len(items),
+		Items:       items,
+	})
+}
+
+// listVMs serves the matching VMs a page at a time. defaultPageSize is used
+// when the request sets no pageSize, zero selects everything.
+func (m *Model) listVMs(w http.ResponseWriter, query url.Values, defaultPageSize int,
+	match func(*types.VirtualMachine) bool) {
+	items := make([]types.VirtualMachine, 0)
+	for _, vm := range m.vms {
+		if match(vm) {
+			items = append(items, *vm)
+		}
+	}
+
+	// TotalSize always reports the number of matches, the SDK relies on it
+	// to detect ambiguous lookups by name or IP.
+	total := len(items)
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	currentPage, _ := strconv.Atoi(query.Get("currentPage"))
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	start, end := pageBounds(total, pageSize, currentPage)
+	items = items[start:end]
+
+	writeJSON(w, types.VMPageResponse{
+		PageResponse: pageResponse(total, pageSize, currentPage),
+		Items:        items,
+	})
+}
+
+// listTags serves the tags a page at a time. Pages hold at most PageSize
+// tags, whatever the request asks for, so clients have to follow TotalPage.
+func (m *Model) listTags(w http.ResponseWriter, query url.Values) {
+	items := make([]types.Tag, 0, len(m.tags))
+	for _, tag := range m.tags {
+		items = append(items, *tag)
+	}
+
+	total := len(items)
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if pageSize <= 0 || (m.PageSize > 0 && pageSize > m.PageSize) {
+		pageSize = m.PageSize
+	}
+	currentPage, _ := strconv.Atoi(query.Get("currentPage"))
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	start, end := pageBounds(total, pageSize, currentPage)
+
+	writeJSON(w, struct {
+		types.PageResponse
+		Items []types.Tag `json:"items"`
+	}{pageResponse(total, pageSize, currentPage), items[start:end]})
+}
+
+func (m *Model) listBindings(w http.ResponseWriter, query url.Values) {
+	sourceID := query.Get("sourceIds")
+
+	root := types.TreeItem{
+		ID:       sourceID,
+		Text:     query.Get("tagSourceType"),
+		Children: make([]types.TreeItem, 0, len(m.tags)),
+	}
+	for _, tag := range m.tags {
+		root.Children = append(root.Children, types.TreeItem{
+			ID:      tag.ID,
+			Text:    tag.Name,
+			Checked: m.bindings[sourceID][tag.ID],
+		})
+	}
+	writeJSON(w, []types.TreeItem{root})
+}
+
+func hasIP(vm *types.VirtualMachine, ip string) bool {
+	for _, nic := range vm.Nics {
+		if nic.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// pageBounds returns the slice bounds of the requested page. A zero pageSize
+// selects everything.
+func pageBounds(total int, pageSize int, currentPage int) (int, int) {
+	if pageSize <= 0 {
+		return 0, total
+	}
+	start := (currentPage - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func pageResponse(total int, pageSize int, currentPage int) types.PageResponse {
+	totalPage := 1
+	if pageSize > 0 {
+		totalPage = (total + pageSize - 1) / pageSize
+	}
+	return types.PageResponse{
+		TotalPage:   totalPage,
+		CurrentPage: currentPage,
+		TotalSize:   total,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("icssim: failed to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(types.ErrorMsg{
+		Code:    strconv.Itoa(code),
+		Message: msg,
+	})
+}
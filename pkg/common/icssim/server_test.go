@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icssim
+
+import (
+	"context"
+	"testing"
+
+	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+	dc "github.com/inspur-ics/ics-go-sdk/datacenter"
+	"github.com/inspur-ics/ics-go-sdk/tag"
+	"github.com/inspur-ics/ics-go-sdk/vm"
+)
+
+func newConnection(s *Server, password string) *icsgo.ICSConnection {
+	return &icsgo.ICSConnection{
+		Username: DefaultUsername,
+		Password: password,
+		Hostname: s.Host(),
+		Port:     s.Port(),
+		Insecure: true,
+	}
+}
+
+func newVPX(datacenters int) *Model {
+	m := VPX()
+	m.Datacenter = datacenters
+	m.Create()
+	return m
+}
+
+func TestLogin(t *testing.T) {
+	s := NewServer(newVPX(1))
+	defer s.Close()
+	ctx := context.Background()
+
+	conn := newConnection(s, "wrong")
+	if err := conn.Connect(ctx); err == nil {
+		t.Fatal("Connect should fail with invalid credentials")
+	}
+
+	conn = newConnection(s, DefaultPassword)
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+
+	// Re-connecting with a valid session keeps the session.
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+
+	// An expired session is transparently replaced.
+	s.Model.ExpireSessions()
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect after expiry err=%v", err)
+	}
+
+	if err := conn.Logout(ctx); err != nil {
+		t.Fatalf("Logout err=%v", err)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	s := NewServer(newVPX(2))
+	defer s.Close()
+	ctx := context.Background()
+
+	conn := newConnection(s, DefaultPassword)
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+	defer conn.Logout(ctx)
+
+	dcs, err := dc.NewDatacenterService(conn.Client).GetAllDatacenters(ctx)
+	if err != nil {
+		t.Fatalf("GetAllDatacenters err=%v", err)
+	}
+	if len(dcs) != 2 {
+		t.Fatalf("expected 2 datacenters, got %d", len(dcs))
+	}
+
+	expected := s.Model.VMs()[0]
+	vms := vm.NewVirtualMachineService(conn.Client)
+
+	byName, err := vms.GetVMByName(ctx, expected.Name)
+	if err != nil {
+		t.Fatalf("GetVMByName err=%v", err)
+	}
+	if byName.ID != expected.ID {
+		t.Errorf("GetVMByName returned %q, expected %q", byName.ID, expected.ID)
+	}
+
+	byIP, err := vms.GetVMByIP(ctx, expected.Nics[0].IP)
+	if err != nil {
+		t.Fatalf("GetVMByIP err=%v", err)
+	}
+	if byIP.ID != expected.ID {
+		t.Errorf("GetVMByIP returned %q, expected %q", byIP.ID, expected.ID)
+	}
+
+	byUUID, err := vms.GetVMByUUID(ctx, expected.UUID)
+	if err != nil {
+		t.Fatalf("GetVMByUUID err=%v", err)
+	}
+	if byUUID == nil || byUUID.ID != expected.ID {
+		t.Errorf("GetVMByUUID returned %+v, expected %q", byUUID, expected.ID)
+	}
+
+	missing, err := vms.GetVMByName(ctx, "missing")
+	if err != nil {
+		t.Fatalf("GetVMByName err=%v", err)
+	}
+	if missing.ID != "" {
+		t.Errorf("GetVMByName returned %q for a missing VM", missing.ID)
+	}
+}
+
+func TestDatacenterVMListPaging(t *testing.T) {
+	m := VPX()
+	m.Machine = 3
+	m.PageSize = 2
+	m.Create()
+	s := NewServer(m)
+	defer s.Close()
+	ctx := context.Background()
+
+	conn := newConnection(s, DefaultPassword)
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+	defer conn.Logout(ctx)
+
+	// Without a page size only the first page is returned.
+	vms, err := dc.NewDatacenterService(conn.Client).GetDatacenterVMList(ctx, "DC0")
+	if err != nil {
+		t.Fatalf("GetDatacenterVMList err=%v", err)
+	}
+	if len(vms) != 2 {
+		t.Errorf("expected the first page of 2 VMs, got %d", len(vms))
+	}
+}
+
+func TestTags(t *testing.T) {
+	s := NewServer(newVPX(1))
+	defer s.Close()
+	ctx := context.Background()
+
+	host := s.Model.Hosts()[0]
+	zone := s.Model.AddTag(types.Tag{Name: "zone-a", Description: "k8s-zone"})
+	s.Model.AddTag(types.Tag{Name: "zone-b", Description: "k8s-zone"})
+	if err := s.Model.AttachTag(zone, host.ID); err != nil {
+		t.Fatalf("AttachTag err=%v", err)
+	}
+
+	conn := newConnection(s, DefaultPassword)
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("Connect err=%v", err)
+	}
+	defer conn.Logout(ctx)
+
+	tags := tag.NewTagsService(conn.Client)
+	attached, err := tags.ListAttachedTags(ctx, "HOST", host.ID)
+	if err != nil {
+		t.Fatalf("ListAttachedTags err=%v", err)
+	}
+	if len(attached) != 1 || attached[0] != zone {
+		t.Fatalf("ListAttachedTags returned %v, expected [%s]", attached, zone)
+	}
+
+	zoneTag, err := tags.GetTag(ctx, zone)
+	if err != nil {
+		t.Fatalf("GetTag err=%v", err)
+	}
+	if zoneTag.Name != "zone-a" || zoneTag.Description != "k8s-zone" {
+		t.Errorf("GetTag returned %+v", zoneTag)
+	}
+
+	if err := s.Model.DetachTag(zone, host.ID); err != nil {
+		t.Fatalf("DetachTag err=%v", err)
+	}
+	attached, err = tags.ListAttachedTags(ctx, "HOST", host.ID)
+	if err != nil {
+		t.Fatalf("ListAttachedTags err=%v", err)
+	}
+	if len(attached) != 0 {
+		t.Errorf("ListAttachedTags returned %v after detach", attached)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package icssim
+
+import (
+	"fmt"
+
+	"github.com/inspur-ics/ics-go-sdk/client/types"
+)
+
+const (
+	// DefaultNetworkName is the network every generated VM NIC is attached to.
+	DefaultNetworkName = "VM Network"
+	// DefaultGuestOS is the guest OS label of every generated VM.
+	DefaultGuestOS = "CentOS 7.6 64bit"
+)
+
+// VPX returns a Model sized like a small iCenter: one datacenter with one
+// cluster, one host and two VMs. Adjust the counts before calling Create.
+func VPX() *Model {
+	m := NewModel()
+	m.Datacenter = 1
+	m.Host = 1
+	m.Machine = 2
+	return m
+}
+
+// Create populates the Model with Datacenter datacenters, each with one
+// cluster, one datastore, Host hosts and Machine powered on VMs per host.
+// Datacenter IDs equal their names, e.g. "DC0", so a config can list them
+// directly.
+//
+// Objects are named after their parents, e.g. "DC0_C0_H0_VM1". VM n of host h
+// in datacenter d has a single NIC on DefaultNetworkName with address
+// 10.d.h.n+10.
+func (m *Model) Create() {
+	for d := 0; d < m.Datacenter; d++ {
+		dcName := fmt.Sprintf("DC%d", d)
+		dcID := m.AddDatacenter(types.Datacenter{ID: dcName, Name: dcName})
+
+		clusterName := fmt.Sprintf("%s_C0", dcName)
+		clusterID := m.AddCluster(types.Cluster{Name: clusterName, HostNum: m.Host})
+
+		dsName := fmt.Sprintf("%s_LocalDS_0", dcName)
+		m.AddStorage(types.Storage{
+			Name:          dsName,
+			MountPath:     "/mnt/" + dsName,
+			DataStoreType: "LOCAL",
+			DataCenterID:  dcID,
+		})
+
+		m.AddNetwork(types.Network{
+			Name: DefaultNetworkName,
+			VswitchDto: types.Switch{
+				Name:          fmt.Sprintf("%s_vSwitch0", dcName),
+				DataCenterDto: types.Datacenter{ID: dcID, Name: dcName},
+			},
+		})
+
+		for h := 0; h < m.Host; h++ {
+			hostName := fmt.Sprintf("%s_H%d", clusterName, h)
+			hostIP := fmt.Sprintf("10.%d.255.%d", d, h+1)
+			hostID := m.AddHost(types.Host{
+				Name:           hostName,
+				HostName:       hostName,
+				IP:             hostIP,
+				DataCenterID:   dcID,
+				DataCenterName: dcName,
+				ClusterID:      clusterID,
+				ClusterName:    clusterName,
+			})
+
+			for n := 0; n < m.Machine; n++ {
+				vmName := fmt.Sprintf("%s_VM%d", hostName, n)
+				m.AddVM(types.VirtualMachine{
+					Name:         vmName,
+					HostID:       hostID,
+					HostName:     hostName,
+					HostIP:       hostIP,
+					DataCenterID: dcID,
+					GuestosLabel: DefaultGuestOS,
+					CPUNum:       2,
+					Memory:       4096,
+					Nics: []types.Nic{
+						{
+							Name:        DefaultNetworkName,
+							NetworkName: DefaultNetworkName,
+							IP:          fmt.Sprintf("10.%d.%d.%d", d, h, n+10),
+							Mac:         fmt.Sprintf("00:50:56:%02x:%02x:%02x", d, h, n),
+							Status:      "UP",
+						},
+					},
+				})
+			}
+		}
+	}
+}
@@ -78,3 +78,19 @@ func (im *InformerManager) AddNodeListener(add, remove func(obj interface{}), up
 func (im *InformerManager) Listen() {
 	go im.informerFactory.Start(im.stopCh)
 }
+
+// WaitForCacheSync starts the Informers and waits up to timeout for their caches
+// to be synced. It returns false if any cache was not synced in time.
+func (im *InformerManager) WaitForCacheSync(timeout time.Duration) bool {
+	stopCh := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stopCh) })
+	defer timer.Stop()
+
+	im.informerFactory.Start(im.stopCh)
+	for _, synced := range im.informerFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return false
+		}
+	}
+	return true
+}
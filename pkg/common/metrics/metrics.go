@@ -0,0 +1,313 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus metrics of the inCloud Sphere cloud
+// provider. They are registered with the default registry, which the
+// controller-manager serves on /metrics.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	icsNamespace = "cloudprovider"
+	icsSubsystem = "ics"
+)
+
+// Values of the result label.
+const (
+	ResultSuccess  = "success"
+	ResultError    = "error"
+	ResultFound    = "found"
+	ResultNotFound = "not_found"
+	ResultHit      = "hit"
+	ResultMiss     = "miss"
+)
+
+var (
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "api_request_duration_seconds",
+			Help:      "Latency of iCenter API calls",
+		},
+		[]string{"operation", "tenant_ref", "result"},
+	)
+
+	apiRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "api_requests_total",
+			Help:      "Cumulative number of iCenter API calls",
+		},
+		[]string{"operation", "tenant_ref", "result"},
+	)
+
+	connects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "connections_total",
+			Help:      "Cumulative number of connections to iCenter",
+		},
+		[]string{"tenant_ref", "result"},
+	)
+
+	relogins = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "relogins_total",
+			Help:      "Cumulative number of new iCenter sessions replacing an expired one",
+		},
+		[]string{"tenant_ref"},
+	)
+
+	circuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker of the connections to iCenter: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"tenant_ref"},
+	)
+
+	activeEndpoint = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "active_endpoint",
+			Help:      "1 for the endpoint the connection to iCenter uses, 0 for the endpoints it used before",
+		},
+		[]string{"tenant_ref", "endpoint"},
+	)
+
+	endpointChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "endpoint_changes_total",
+			Help:      "Cumulative number of failovers and failbacks between the endpoints of iCenter",
+		},
+		[]string{"tenant_ref"},
+	)
+
+	discoverNode = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "discover_node_total",
+			Help:      "Cumulative number of node discoveries by search type and outcome",
+		},
+		[]string{"by", "result"},
+	)
+
+	nodeCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "node_cache_lookups_total",
+			Help:      "Cumulative number of node cache lookups by hit or miss",
+		},
+		[]string{"result"},
+	)
+
+	nodeCacheSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: icsNamespace,
+			Subsystem: icsSubsystem,
+			Name:      "node_cache_size",
+			Help:      "Number of nodes in the node cache",
+		},
+	)
+)
+
+var registerOnce sync.Once
+
+// Register registers the metrics with the default registry.
+func Register() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(apiRequestDuration)
+		prometheus.MustRegister(apiRequests)
+		prometheus.MustRegister(connects)
+		prometheus.MustRegister(relogins)
+		prometheus.MustRegister(circuitBreakerState)
+		prometheus.MustRegister(activeEndpoint)
+		prometheus.MustRegister(endpointChanges)
+		prometheus.MustRegister(discoverNode)
+		prometheus.MustRegister(nodeCacheLookups)
+		prometheus.MustRegister(nodeCacheSize)
+	})
+}
+
+// tenant is what is recorded of the iCenter of a tenantRef, so that its
+// series can be deleted once the iCenter is removed.
+type tenant struct {
+	connection *icsgo.ICSConnection
+	// The operations of the API calls and the endpoints recorded.
+	operations map[string]bool
+	endpoints  map[string]bool
+}
+
+var (
+	tenantsLock sync.Mutex
+	// Maps tenantRef to its iCenter.
+	tenants = make(map[string]*tenant)
+)
+
+// tenantLocked returns the iCenter of tenantRef, adding it if unknown. The
+// caller holds tenantsLock.
+func tenantLocked(tenantRef string) *tenant {
+	t, ok := tenants[tenantRef]
+	if !ok {
+		t = &tenant{operations: make(map[string]bool), endpoints: make(map[string]bool)}
+		tenants[tenantRef] = t
+	}
+	return t
+}
+
+// SetTenantRef sets the tenantRef the calls made on connection are recorded
+// under, replacing the previous connection of tenantRef.
+func SetTenantRef(connection *icsgo.ICSConnection, tenantRef string) {
+	tenantsLock.Lock()
+	defer tenantsLock.Unlock()
+	tenantLocked(tenantRef).connection = connection
+}
+
+// ForgetTenant deletes the series of the iCenter of tenantRef, once it is
+// removed from the configuration.
+func ForgetTenant(tenantRef string) {
+	tenantsLock.Lock()
+	defer tenantsLock.Unlock()
+
+	t, ok := tenants[tenantRef]
+	if !ok {
+		return
+	}
+	for _, res := range []string{ResultSuccess, ResultError} {
+		for operation := range t.operations {
+			apiRequestDuration.DeleteLabelValues(operation, tenantRef, res)
+			apiRequests.DeleteLabelValues(operation, tenantRef, res)
+		}
+		connects.DeleteLabelValues(tenantRef, res)
+	}
+	for endpoint := range t.endpoints {
+		activeEndpoint.DeleteLabelValues(tenantRef, endpoint)
+	}
+	relogins.DeleteLabelValues(tenantRef)
+	circuitBreakerState.DeleteLabelValues(tenantRef)
+	endpointChanges.DeleteLabelValues(tenantRef)
+	delete(tenants, tenantRef)
+}
+
+// connectionTenantRef returns the tenantRef of connection, its hostname if
+// unknown.
+func connectionTenantRef(connection *icsgo.ICSConnection) string {
+	tenantsLock.Lock()
+	defer tenantsLock.Unlock()
+
+	for tenantRef, t := range tenants {
+		if t.connection == connection {
+			return tenantRef
+		}
+	}
+	return connection.Hostname
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+// RecordAPICall records an iCenter API call made on connection, started at
+// start and failed if err is not nil.
+func RecordAPICall(operation string, connection *icsgo.ICSConnection, start time.Time, err error) {
+	RecordTenantAPICall(operation, connectionTenantRef(connection), start, err)
+}
+
+// RecordTenantAPICall is the same as RecordAPICall for a call made on the
+// iCenter of tenantRef.
+func RecordTenantAPICall(operation string, tenantRef string, start time.Time, err error) {
+	tenantsLock.Lock()
+	tenantLocked(tenantRef).operations[operation] = true
+	tenantsLock.Unlock()
+
+	res := result(err)
+	apiRequestDuration.WithLabelValues(operation, tenantRef, res).Observe(time.Since(start).Seconds())
+	apiRequests.WithLabelValues(operation, tenantRef, res).Inc()
+}
+
+// RecordConnect records a connection to the iCenter of tenantRef, failed if
+// err is not nil.
+func RecordConnect(tenantRef string, err error) {
+	connects.WithLabelValues(tenantRef, result(err)).Inc()
+}
+
+// RecordRelogin records a new session to the iCenter of tenantRef replacing
+// an expired one.
+func RecordRelogin(tenantRef string) {
+	relogins.WithLabelValues(tenantRef).Inc()
+}
+
+// SetCircuitBreakerState sets the state of the circuit breaker of the
+// connections to the iCenter of tenantRef: 0 closed, 1 open, 2 half-open.
+func SetCircuitBreakerState(tenantRef string, state int) {
+	circuitBreakerState.WithLabelValues(tenantRef).Set(float64(state))
+}
+
+// SetActiveEndpoint records that the connection to the iCenter of tenantRef
+// moved from the previous endpoint, empty if none, to the current one.
+func SetActiveEndpoint(tenantRef string, previous string, current string) {
+	tenantsLock.Lock()
+	tenantLocked(tenantRef).endpoints[current] = true
+	tenantsLock.Unlock()
+
+	if previous != "" {
+		activeEndpoint.WithLabelValues(tenantRef, previous).Set(0)
+		endpointChanges.WithLabelValues(tenantRef).Inc()
+	}
+	activeEndpoint.WithLabelValues(tenantRef, current).Set(1)
+}
+
+// RecordDiscoverNode records the outcome of a node discovery searched by
+// uuid, name or ip.
+func RecordDiscoverNode(by string, result string) {
+	discoverNode.WithLabelValues(by, result).Inc()
+}
+
+// RecordNodeCacheLookup records a hit or a miss of the node cache.
+func RecordNodeCacheLookup(hit bool) {
+	if hit {
+		nodeCacheLookups.WithLabelValues(ResultHit).Inc()
+	} else {
+		nodeCacheLookups.WithLabelValues(ResultMiss).Inc()
+	}
+}
+
+// SetNodeCacheSize sets the number of nodes in the node cache.
+func SetNodeCacheSize(size int) {
+	nodeCacheSize.Set(float64(size))
+}
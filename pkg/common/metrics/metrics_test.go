@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	icsgo "github.com/inspur-ics/ics-go-sdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordAPICall(t *testing.T) {
+	connection := &icsgo.ICSConnection{Hostname: "10.0.0.1"}
+	other := &icsgo.ICSConnection{Hostname: "10.0.0.2"}
+	SetTenantRef(connection, "tenant")
+
+	RecordAPICall("get_vm", connection, time.Now(), nil)
+	RecordAPICall("get_vm", connection, time.Now(), errors.New("failed"))
+	RecordAPICall("get_vm", other, time.Now(), nil)
+
+	for _, test := range []struct {
+		tenantRef string
+		result    string
+	}{
+		{"tenant", ResultSuccess},
+		{"tenant", ResultError},
+		// Connections without a tenantRef are recorded under their hostname.
+		{"10.0.0.2", ResultSuccess},
+	} {
+		if count := testutil.ToFloat64(apiRequests.WithLabelValues("get_vm", test.tenantRef, test.result)); count != 1 {
+			t.Errorf("api_requests_total{tenant_ref=%s,result=%s} = %v, expected 1", test.tenantRef, test.result, count)
+		}
+	}
+}
+
+func TestRecordConnect(t *testing.T) {
+	connection := &icsgo.ICSConnection{Hostname: "10.0.0.3"}
+	SetTenantRef(connection, "relogin")
+
+	RecordConnect("relogin", nil)
+	RecordRelogin("relogin")
+	RecordConnect("relogin", errors.New("failed"))
+	if count := testutil.ToFloat64(relogins.WithLabelValues("relogin")); count != 1 {
+		t.Errorf("relogins_total = %v, expected 1", count)
+	}
+	if count := testutil.ToFloat64(connects.WithLabelValues("relogin", ResultError)); count != 1 {
+		t.Errorf("connections_total{result=error} = %v, expected 1", count)
+	}
+}
+
+func TestForgetTenant(t *testing.T) {
+	connection := &icsgo.ICSConnection{Hostname: "10.0.0.4"}
+	SetTenantRef(connection, "removed")
+	SetActiveEndpoint("removed", "", "10.0.0.4:443")
+	SetCircuitBreakerState("removed", 1)
+	RecordAPICall("get_vm", connection, time.Now(), nil)
+	RecordConnect("removed", nil)
+
+	ForgetTenant("removed")
+
+	for name, collector := range map[string]prometheus.Collector{
+		"api_requests_total":    apiRequests,
+		"connections_total":     connects,
+		"active_endpoint":       activeEndpoint,
+		"circuit_breaker_state": circuitBreakerState,
+	} {
+		if hasTenantSeries(t, collector, "removed") {
+			t.Errorf("%s still has series of the removed iCenter", name)
+		}
+	}
+	if _, ok := tenants["removed"]; ok {
+		t.Error("the removed iCenter is still tracked")
+	}
+
+	// The connection is no longer mapped to the tenantRef.
+	if tenantRef := connectionTenantRef(connection); tenantRef != "10.0.0.4" {
+		t.Errorf("connectionTenantRef() = %s after ForgetTenant, expected the hostname", tenantRef)
+	}
+}
+
+// hasTenantSeries returns true if collector has a series labeled with
+// tenantRef.
+func hasTenantSeries(t *testing.T, collector prometheus.Collector, tenantRef string) bool {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "tenant_ref" && label.GetValue() == tenantRef {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestRegister(t *testing.T) {
+	Register()
+	Register()
+
+	SetCircuitBreakerState("tenant", 1)
+	SetActiveEndpoint("tenant", "", "10.0.0.1:443")
+	RecordDiscoverNode("byUUID", ResultFound)
+	RecordNodeCacheLookup(true)
+	SetNodeCacheSize(3)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := make(map[string]bool)
+	for _, family := range families {
+		registered[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"cloudprovider_ics_active_endpoint",
+		"cloudprovider_ics_circuit_breaker_state",
+		"cloudprovider_ics_discover_node_total",
+		"cloudprovider_ics_node_cache_lookups_total",
+		"cloudprovider_ics_node_cache_size",
+	} {
+		if !registered[name] {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+}